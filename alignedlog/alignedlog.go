@@ -0,0 +1,319 @@
+// Package alignedlog implements a console formatter that lays each record out
+// in fixed columns: level, time, message, and then attributes starting at a
+// fixed column.
+package alignedlog
+
+import (
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/fatih/color"
+)
+
+// Formatter implements easyslog.Formatter and renders records in aligned
+// columns. Widths are measured in terminal cells, so wide (e.g. CJK) runes
+// count as two cells and ANSI escape sequences count as none.
+type Formatter struct {
+	// Determines if color is used or not
+	NoColor bool
+	// TimeFormat is the layout used for the time column. Defaults to
+	// DefaultTimeFormat. Times are rendered in their own location, see
+	// easyslog.Options.TimeZone to pick one.
+	TimeFormat string
+	// MessageWidth truncates the message to at most this many cells. When
+	// attributes follow, the message is padded to this width. Zero disables it.
+	MessageWidth int
+	// AttrColumn is the column attributes start at. If the line is already past
+	// it, attributes are separated from the message by a single space.
+	AttrColumn int
+	// MaxWidth truncates the whole line, with `…`, to at most this many cells.
+	// Zero disables it. It's ignored in Expanded mode.
+	MaxWidth int
+	// Expanded renders each attribute on its own indented line instead of
+	// keeping the record on a single line.
+	Expanded bool
+	// LevelNames overrides the rendered level names. Levels not in the map
+	// render as Record.LevelString().
+	LevelNames easyslog.LevelNamer
+}
+
+// DefaultTimeFormat is the layout used when Formatter.TimeFormat is empty.
+const DefaultTimeFormat = "15:04:05.000"
+
+// levelWidth is the width of the level column, wide enough for ERROR and DEBUG.
+const levelWidth = 5
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("aligned", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+// LevelColors maps log levels to colors when color is enabled. Levels not in
+// this list will render as cyan.
+var LevelColors = map[slog.Level]color.Attribute{
+	slog.LevelDebug: color.FgGreen,
+	slog.LevelInfo:  color.FgBlue,
+	slog.LevelWarn:  color.FgYellow,
+	slog.LevelError: color.FgRed,
+}
+
+// escape replaces control characters in s with Go escape sequences, e.g. `\n`
+// or `\x1b`, keeping values on a single physical line, their width
+// measurable, and terminal escape sequences out of the output.
+func escape(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for _, r := range s {
+		if !unicode.IsControl(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		quoted := strconv.QuoteRune(r)
+		b.WriteString(quoted[1 : len(quoted)-1])
+	}
+
+	return b.String()
+}
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	colorAttr := color.FgCyan
+	if attr, ok := LevelColors[record.Level]; ok {
+		colorAttr = attr
+	}
+	c := color.New(colorAttr)
+	bold := color.New(colorAttr, color.Bold)
+	faint := color.New(color.Faint)
+
+	if f.NoColor {
+		c.DisableColor()
+		bold.DisableColor()
+		faint.DisableColor()
+	}
+
+	var line lineWriter
+	line.write(bold, pad(f.LevelNames.RecordName(record), levelWidth))
+	line.write(nil, " ")
+
+	if !record.Time.IsZero() {
+		timeFormat := f.TimeFormat
+		if timeFormat == "" {
+			timeFormat = DefaultTimeFormat
+		}
+
+		line.write(faint, record.Time.Format(timeFormat))
+		line.write(nil, " ")
+	}
+
+	message := escape(record.Message)
+	if f.MessageWidth > 0 {
+		message = truncate(message, f.MessageWidth)
+	}
+	line.write(nil, message)
+
+	attrs := make([]keyValue, 0, len(record.Attrs))
+	for _, attr := range record.Attrs {
+		attrs = collect(attrs, attr, "")
+	}
+
+	if f.Expanded {
+		indent := strings.Repeat(" ", max(f.AttrColumn, 2))
+		for _, kv := range attrs {
+			line.write(nil, "\n"+indent)
+			line.write(c, kv.key)
+			line.write(nil, "="+kv.value)
+		}
+
+		_, err := io.WriteString(w, line.String())
+		return err
+	}
+
+	if len(attrs) > 0 {
+		if f.MessageWidth > 0 {
+			line.write(nil, strings.Repeat(" ", f.MessageWidth-displayWidth(message)))
+		}
+
+		line.write(nil, strings.Repeat(" ", max(f.AttrColumn-line.col, 1)))
+
+		for i, kv := range attrs {
+			if i > 0 {
+				line.write(nil, " ")
+			}
+			line.write(c, kv.key)
+			line.write(nil, "="+kv.value)
+		}
+	}
+
+	out := line.String()
+	if f.MaxWidth > 0 {
+		out = truncate(out, f.MaxWidth)
+	}
+
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+type keyValue struct {
+	key   string
+	value string
+}
+
+// collect appends the leaves of attr to dst with dotted keys.
+func collect(dst []keyValue, attr *easyslog.Attr, prefix string) []keyValue {
+	key := attr.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+
+	if attr.IsGroup() {
+		for _, child := range attr.Children {
+			dst = collect(dst, child, key)
+		}
+		return dst
+	}
+
+	return append(dst, keyValue{key: escape(key), value: escape(attr.Value.String())})
+}
+
+// lineWriter builds a line while tracking the visible column.
+type lineWriter struct {
+	strings.Builder
+	col int
+}
+
+func (lw *lineWriter) write(c *color.Color, s string) {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		lw.col = displayWidth(s[i+1:])
+	} else {
+		lw.col += displayWidth(s)
+	}
+
+	if c != nil {
+		s = c.Sprint(s)
+	}
+
+	lw.WriteString(s)
+}
+
+func pad(s string, width int) string {
+	if n := displayWidth(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+
+	return s
+}
+
+// truncate shortens s to at most width cells, replacing the tail with `…`.
+// Escape sequences are kept intact and don't count towards the width, and a
+// reset sequence is appended if s contained any.
+func truncate(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+
+	var b strings.Builder
+	col := 0
+	hasEscape := false
+
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			hasEscape = true
+			b.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		rw := runeWidth(r)
+		if col+rw > width-1 {
+			break
+		}
+
+		b.WriteString(s[i : i+size])
+		col += rw
+		i += size
+	}
+
+	b.WriteString("…")
+	if hasEscape {
+		b.WriteString("\x1b[0m")
+	}
+
+	return b.String()
+}
+
+// displayWidth returns the number of terminal cells s occupies.
+func displayWidth(s string) int {
+	width := 0
+
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		width += runeWidth(r)
+		i += size
+	}
+
+	return width
+}
+
+// escapeLen returns the length of the CSI escape sequence s starts with, or 0.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' || s[1] != '[' {
+		return 0
+	}
+
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+
+	return len(s)
+}
+
+// runeWidth approximates the East Asian Width of r: combining marks are zero
+// width and wide/fullwidth runes take two cells.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0x303e,
+		r >= 0x3041 && r <= 0x33ff,
+		r >= 0x3400 && r <= 0x4dbf,
+		r >= 0x4e00 && r <= 0x9fff,
+		r >= 0xa000 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+
+	return 1
+}
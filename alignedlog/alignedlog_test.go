@@ -0,0 +1,123 @@
+package alignedlog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func format(t *testing.T, f Formatter, level slog.Level, msg string, attrs ...*easyslog.Attr) string {
+	var buf bytes.Buffer
+	err := f.Format(&buf, easyslog.Record{Level: level, Message: msg, Attrs: attrs})
+	require.NoError(t, err)
+
+	return buf.String()
+}
+
+func leaf(key string, value slog.Value) *easyslog.Attr {
+	return &easyslog.Attr{Key: key, Value: value}
+}
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{NoColor: true, TimeFormat: "15:04"}, nil)
+
+	record := slog.NewRecord(time.Date(2023, 9, 9, 13, 37, 0, 0, time.UTC), slog.LevelInfo, "hello", 0)
+	record.AddAttrs(slog.String("foo", "bar"), slog.Group("req", slog.Int("id", 2)))
+	require.NoError(t, handler.Handle(context.Background(), record))
+
+	require.Equal(t, "INFO  13:37 hello foo=bar req.id=2\n", buf.String())
+}
+
+func TestAlignment(t *testing.T) {
+	f := Formatter{NoColor: true, MessageWidth: 10, AttrColumn: 20}
+
+	require.Equal(t, "INFO  hi            a=1", format(t, f, slog.LevelInfo, "hi", leaf("a", slog.IntValue(1))))
+	require.Equal(t, "ERROR something…    a=1", format(t, f, slog.LevelError, "something long that is cut", leaf("a", slog.IntValue(1))))
+	require.Equal(t, "DEBUG no attrs", format(t, f, slog.LevelDebug, "no attrs"))
+
+	f.AttrColumn = 24
+	require.Equal(t, "INFO  hi                a=1", format(t, f, slog.LevelInfo, "hi", leaf("a", slog.IntValue(1))))
+}
+
+func TestColor(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+	color.NoColor = false
+
+	f := Formatter{MessageWidth: 6, AttrColumn: 14}
+	attr := leaf("foo", slog.StringValue("bar"))
+
+	require.Equal(t, "\x1b[34;1mINFO \x1b[0m hello…  \x1b[34mfoo\x1b[0m=bar", format(t, f, slog.LevelInfo, "hello world", attr))
+
+	f.NoColor = true
+	require.Equal(t, "INFO  hello…  foo=bar", format(t, f, slog.LevelInfo, "hello world", attr))
+}
+
+func TestCJKMessage(t *testing.T) {
+	f := Formatter{NoColor: true, MessageWidth: 8, AttrColumn: 15}
+	attr := leaf("k", slog.StringValue("v"))
+
+	// Each CJK rune is two cells wide, so only three fit before the ellipsis
+	require.Equal(t, "INFO  日本語…  k=v", format(t, f, slog.LevelInfo, "日本語のログ", attr))
+	require.Equal(t, "INFO  日本     k=v", format(t, f, slog.LevelInfo, "日本", attr))
+}
+
+func TestMaxWidth(t *testing.T) {
+	f := Formatter{NoColor: true, MaxWidth: 20}
+
+	out := format(t, f, slog.LevelInfo, "a message that is far too long for the terminal", leaf("k", slog.StringValue("v")))
+	require.Equal(t, "INFO  a message tha…", out)
+	require.Equal(t, 20, displayWidth(out))
+}
+
+func TestMaxWidthWithColor(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+	color.NoColor = false
+
+	f := Formatter{MaxWidth: 12}
+	out := format(t, f, slog.LevelInfo, "hello world", leaf("k", slog.StringValue("v")))
+
+	require.Equal(t, "\x1b[34;1mINFO \x1b[0m hello…\x1b[0m", out)
+	require.Equal(t, 12, displayWidth(out))
+}
+
+func TestNewlinesEscaped(t *testing.T) {
+	f := Formatter{NoColor: true}
+
+	require.Equal(t, `INFO  line one\nline two k=a\nb`, format(t, f, slog.LevelInfo, "line one\nline two", leaf("k", slog.StringValue("a\nb"))))
+}
+
+func TestControlCharactersEscaped(t *testing.T) {
+	f := Formatter{NoColor: true, MessageWidth: 10}
+
+	// Escaped before measuring, so padding counts the escapes' width
+	out := format(t, f, slog.LevelInfo, "a\x1b[2Jb", leaf("k\x00", slog.StringValue("tab\there\x7f\u0085")))
+	require.Equal(t, `INFO  a\x1b[2Jb  k\x00=tab\there\x7f\u0085`, out)
+
+	f = Formatter{NoColor: true, MaxWidth: 12}
+	require.Equal(t, `INFO  \x1b[…`, format(t, f, slog.LevelInfo, "\x1b[31mred"))
+}
+
+func TestExpanded(t *testing.T) {
+	f := Formatter{NoColor: true, Expanded: true, AttrColumn: 4, MaxWidth: 5}
+	group := &easyslog.Attr{Key: "req", Children: []*easyslog.Attr{leaf("id", slog.IntValue(2))}}
+
+	require.Equal(t, "INFO  hello\n    a=1\n    req.id=2", format(t, f, slog.LevelInfo, "hello", leaf("a", slog.IntValue(1)), group))
+}
+
+func TestLevelNames(t *testing.T) {
+	f := Formatter{NoColor: true, LevelNames: easyslog.LevelNamer{slog.LevelInfo: "info"}}
+
+	require.Equal(t, "info  hello", format(t, f, slog.LevelInfo, "hello"))
+	require.Equal(t, "WARN  hello", format(t, f, slog.LevelWarn, "hello"))
+}
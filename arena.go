@@ -0,0 +1,111 @@
+package easyslog
+
+import "sync"
+
+const (
+	// arenaChunkSize is the number of nodes and child pointers allocated at a
+	// time by an attrArena.
+	arenaChunkSize = 64
+	// arenaMaxChunks bounds the memory an attrArena keeps when it's returned
+	// to the pool, so one huge record doesn't pin memory forever.
+	arenaMaxChunks = 16
+)
+
+// attrArena hands out the Attr nodes and child slices of a single record's
+// tree when Options.ReuseAttrs is set, so building the tree takes a few
+// allocations instead of one per node. Arenas are pooled and reused once the
+// record is written. A nil arena allocates from the heap.
+type attrArena struct {
+	nodes    slab[Attr]
+	children slab[*Attr]
+}
+
+var arenaPool = sync.Pool{
+	New: func() any { return new(attrArena) },
+}
+
+// newArena returns a pooled arena if Options.ReuseAttrs is set, and nil
+// otherwise.
+func (handler *EasySlog) newArena() *attrArena {
+	if !handler.opts.ReuseAttrs {
+		return nil
+	}
+
+	return arenaPool.Get().(*attrArena)
+}
+
+// release clears the arena and returns it to the pool. Nothing it handed out
+// may be used afterwards.
+func (arena *attrArena) release() {
+	if arena == nil {
+		return
+	}
+
+	if len(arena.nodes.chunks) > arenaMaxChunks || len(arena.children.chunks) > arenaMaxChunks {
+		return
+	}
+
+	arena.nodes.reset()
+	arena.children.reset()
+	arenaPool.Put(arena)
+}
+
+func (arena *attrArena) newAttr() *Attr {
+	if arena == nil {
+		return &Attr{}
+	}
+
+	return &arena.nodes.alloc(1)[0]
+}
+
+// newChildren returns a slice for n children with room for capacity of them.
+// Appending beyond capacity moves the slice to the heap, so it never
+// overwrites other nodes' children.
+func (arena *attrArena) newChildren(n int, capacity int) []*Attr {
+	if arena == nil {
+		return make([]*Attr, n, capacity)
+	}
+
+	return arena.children.alloc(capacity)[:n]
+}
+
+// slab allocates slices out of fixed size chunks that are kept across resets.
+// Chunks are never grown in place, so slices already handed out stay valid.
+type slab[T any] struct {
+	chunks [][]T
+	// chunk is the index of the chunk being allocated from and next the
+	// index of its first free element.
+	chunk int
+	next  int
+}
+
+// alloc returns a zeroed slice of n elements with no spare capacity.
+func (s *slab[T]) alloc(n int) []T {
+	for s.chunk < len(s.chunks) {
+		c := s.chunks[s.chunk]
+		if s.next+n <= len(c) {
+			out := c[s.next : s.next+n : s.next+n]
+			s.next += n
+			return out
+		}
+
+		s.chunk++
+		s.next = 0
+	}
+
+	s.chunks = append(s.chunks, make([]T, max(arenaChunkSize, n)))
+	s.next = n
+
+	return s.chunks[s.chunk][:n:n]
+}
+
+// reset zeroes the chunks used so far, dropping their references, and makes
+// them available again.
+func (s *slab[T]) reset() {
+	for i := 0; i < len(s.chunks) && i <= s.chunk; i++ {
+		clear(s.chunks[i])
+	}
+
+	s.chunk = 0
+	s.next = 0
+}
@@ -0,0 +1,147 @@
+package easyslog
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// deepAttrs returns 30 leaves spread over groups nested three levels deep.
+func deepAttrs() []any {
+	leaves := func(prefix string, n int) []any {
+		attrs := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			attrs = append(attrs, slog.Int(prefix+strconv.Itoa(i), i))
+		}
+		return attrs
+	}
+
+	inner := slog.Group("inner", leaves("c", 10)...)
+	middle := slog.Group("middle", append(leaves("b", 10), inner)...)
+	return append(leaves("a", 10), slog.Group("outer", middle))
+}
+
+func TestReuseAttrsOutput(t *testing.T) {
+	// Drop the time so both handlers' output can be compared
+	formatter := FormatterFunc(func(w io.Writer, r Record) error {
+		r.Time = time.Time{}
+		return JSONFormatter{}.Format(w, r)
+	})
+
+	for name, opts := range map[string]Options{"tree": {}, "flat": {FlattenGroups: "."}, "max attrs": {MaxAttrs: 12}} {
+		t.Run(name, func(t *testing.T) {
+			var want, got bytes.Buffer
+			reuse := opts
+			reuse.ReuseAttrs = true
+
+			for _, c := range []struct {
+				buf  *bytes.Buffer
+				opts Options
+			}{{&want, opts}, {&got, reuse}} {
+				l := slog.New(New(c.buf, formatter, &c.opts)).With("base", 1).WithGroup("req").With("id", 2)
+				for i := 0; i < 3; i++ {
+					l.Info("deep", deepAttrs()...)
+					l.Info("small", "k", i)
+				}
+			}
+
+			require.Equal(t, want.String(), got.String())
+		})
+	}
+}
+
+func TestReuseAttrsConcurrent(t *testing.T) {
+	var b bytes.Buffer
+	l := slog.New(New(&b, JSONFormatter{}, &Options{ReuseAttrs: true}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(l *slog.Logger) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.Info("msg", "n", j, slog.Group("g", "a", 1, slog.Group("h", "b", 2)))
+			}
+		}(l.With("worker", i).WithGroup("w"))
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	require.Len(t, lines, 400)
+	for _, line := range lines {
+		require.Contains(t, line, `"g":{"a":"1","h":{"b":"2"}}`)
+	}
+}
+
+func TestReuseAttrsRecent(t *testing.T) {
+	handler := New(io.Discard, JSONFormatter{}, &Options{ReuseAttrs: true, Ring: 2})
+	l := slog.New(handler)
+
+	l.Info("first", "a", 1)
+	l.Info("second", "a", 2)
+
+	recent := handler.Recent()
+	require.Len(t, recent, 2)
+	v, ok := recent[0].Get("a")
+	require.True(t, ok)
+	require.Equal(t, int64(1), v.Int64())
+}
+
+func TestReuseAttrsAllocs(t *testing.T) {
+	attrs := deepAttrs()
+	allocs := func(opts *Options) float64 {
+		l := slog.New(New(io.Discard, FormatterFunc(func(w io.Writer, r Record) error { return nil }), opts))
+		return testing.AllocsPerRun(100, func() {
+			l.Info("deep", attrs...)
+		})
+	}
+
+	require.Less(t, allocs(&Options{ReuseAttrs: true})+30, allocs(nil))
+}
+
+func TestSlab(t *testing.T) {
+	var s slab[int]
+
+	a := s.alloc(60)
+	b := s.alloc(10)
+	require.Len(t, s.chunks, 2)
+	require.Equal(t, 10, cap(b))
+
+	b = append(b, 1)
+	require.Zero(t, a[0])
+
+	big := s.alloc(200)
+	require.Len(t, big, 200)
+
+	big[0] = 1
+	s.reset()
+	require.Zero(t, s.chunks[2][0])
+	require.Len(t, s.alloc(64), 64)
+	require.Len(t, s.chunks, 3)
+}
+
+func BenchmarkDeepRecord(b *testing.B) {
+	benchmarkDeepRecord(b, nil)
+}
+
+func BenchmarkDeepRecordReuseAttrs(b *testing.B) {
+	benchmarkDeepRecord(b, &Options{ReuseAttrs: true})
+}
+
+func benchmarkDeepRecord(b *testing.B, opts *Options) {
+	l := slog.New(New(io.Discard, FastJSONFormatter{}, opts)).With("base", 1).WithGroup("req")
+	attrs := deepAttrs()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.Info("deep", attrs...)
+	}
+}
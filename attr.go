@@ -2,6 +2,7 @@ package easyslog
 
 import (
 	"log/slog"
+	"slices"
 )
 
 type (
@@ -16,31 +17,211 @@ type (
 		Value slog.Value
 		// Children holds pointers to each of the nested attributes if they exist.
 		Children []*Attr
+		// Persistent is true for attributes bound to the handler rather than
+		// passed with the record: those added via WithAttrs, BaseAttrs or
+		// Prelude, including their children, and groups opened via WithGroup.
+		// Formatters can use it to e.g. dim the request-scoped context so the
+		// record's own attributes stand out.
+		Persistent bool
+
+		// group marks the Attr as a group even when it has no children.
+		group bool
+		// withGroup marks groups opened via WithGroup, which are kept when empty
+		// if Options.KeepEmptyGroups is set.
+		withGroup bool
 	}
 )
 
 // Clone the existing tree for use in the formatter
 func (a *Attr) clone() *Attr {
-	attr := &Attr{
-		Key:      a.Key,
-		Value:    a.Value,
-		Children: make([]*Attr, len(a.Children)),
-	}
+	return a.cloneIn(nil)
+}
+
+// cloneIn clones the tree like clone, allocating from arena.
+func (a *Attr) cloneIn(arena *attrArena) *Attr {
+	attr := arena.newAttr()
+	attr.Key = a.Key
+	attr.Value = a.Value
+	attr.Children = arena.newChildren(len(a.Children), len(a.Children))
+	attr.Persistent = a.Persistent
+	attr.group = a.group
+	attr.withGroup = a.withGroup
 
 	for i, child := range a.Children {
-		attr.Children[i] = child.clone()
+		attr.Children[i] = child.cloneIn(arena)
 	}
 
 	return attr
 }
 
+// shallowClone copies a but not its children, which are shared.
+func (a *Attr) shallowClone() *Attr {
+	attr := *a
+	attr.Children = slices.Clone(a.Children)
+
+	return &attr
+}
+
+// markPersistent sets Persistent on attrs and all of their descendants.
+func markPersistent(attrs []*Attr) {
+	for _, attr := range attrs {
+		attr.Persistent = true
+		markPersistent(attr.Children)
+	}
+}
+
 // Returns true if this is a dead-end node and should not be rendered
 func (a *Attr) empty() bool {
-	return a.Value.Any() == nil && (a.Children == nil || len(a.Children) == 0)
+	return isNil(a.Value) && (a.Children == nil || len(a.Children) == 0)
+}
+
+// isNil reports whether v holds a nil value. Unlike comparing Value.Any to
+// nil, it doesn't allocate for strings and other non-pointer kinds.
+func isNil(v slog.Value) bool {
+	return v.Kind() == slog.KindAny && v.Any() == nil
 }
 
 // Returns true if this Attr represents a group and its Value field should be
-// ignored.
+// ignored. Groups are usually non-empty, but empty groups opened via WithGroup
+// are kept when Options.KeepEmptyGroups is set.
 func (a *Attr) IsGroup() bool {
-	return len(a.Children) > 0
+	return a.group || len(a.Children) > 0
+}
+
+// Get returns the value of the leaf attribute at the given path of keys below
+// this group, e.g. `Get("method")` on a `request` group. The first attribute
+// matching each key is used.
+func (a *Attr) Get(path ...string) (slog.Value, bool) {
+	attr := findAttr(a.Children, path)
+	if attr == nil || attr.IsGroup() {
+		return slog.Value{}, false
+	}
+
+	return attr.Value, true
+}
+
+// findAttr walks attrs following path, returning nil if any key is missing or
+// a leaf is hit before the end of the path.
+func findAttr(attrs []*Attr, path []string) *Attr {
+	if len(path) == 0 {
+		return nil
+	}
+
+	for _, attr := range attrs {
+		if attr.Key != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			return attr
+		}
+
+		if !attr.IsGroup() {
+			return nil
+		}
+
+		return findAttr(attr.Children, path[1:])
+	}
+
+	return nil
+}
+
+// deleteAttr removes the first attribute matching path from attrs, removing
+// any groups left empty along the way.
+func deleteAttr(attrs []*Attr, path []string) ([]*Attr, bool) {
+	if len(path) == 0 {
+		return attrs, false
+	}
+
+	for i, attr := range attrs {
+		if attr.Key != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			return slices.Delete(attrs, i, i+1), true
+		}
+
+		if !attr.IsGroup() {
+			return attrs, false
+		}
+
+		children, ok := deleteAttr(attr.Children, path[1:])
+		if !ok {
+			return attrs, false
+		}
+
+		attr.Children = children
+		if len(children) == 0 {
+			return slices.Delete(attrs, i, i+1), true
+		}
+
+		return attrs, true
+	}
+
+	return attrs, false
+}
+
+// withoutAttr returns attrs without the first attribute matching path,
+// removing groups left empty. Only the slices along the path are copied, so
+// attrs and the groups in it aren't modified.
+func withoutAttr(attrs []*Attr, path []string) []*Attr {
+	if len(path) == 0 {
+		return attrs
+	}
+
+	for i, attr := range attrs {
+		if attr.Key != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			return slices.Delete(slices.Clone(attrs), i, i+1)
+		}
+
+		if !attr.IsGroup() {
+			return attrs
+		}
+
+		result := slices.Clone(attrs)
+		children := withoutAttr(attr.Children, path[1:])
+		if len(children) == 0 {
+			return slices.Delete(result, i, i+1)
+		}
+
+		group := *attr
+		group.Children = children
+		result[i] = &group
+
+		return result
+	}
+
+	return attrs
+}
+
+// rawJSON marks bytes logged via RawJSON. String returns the bytes as text so
+// formatters that don't know about it still render something readable.
+type rawJSON []byte
+
+func (r rawJSON) String() string {
+	return string(r)
+}
+
+// RawJSON returns an Attr whose value is an already encoded JSON document, so
+// JSON formatters can embed it as-is instead of as a quoted string. Formatters
+// detect it with (*Attr).RawJSON. data is not copied or validated.
+func RawJSON(key string, data []byte) slog.Attr {
+	return slog.Any(key, rawJSON(data))
+}
+
+// RawJSON returns the bytes of a leaf attribute created with RawJSON, and
+// whether it was one. The bytes may not be valid JSON, so formatters should
+// check before embedding them.
+func (a *Attr) RawJSON() ([]byte, bool) {
+	if a.IsGroup() || a.Value.Kind() != slog.KindAny {
+		return nil, false
+	}
+
+	data, ok := a.Value.Any().(rawJSON)
+	return data, ok
 }
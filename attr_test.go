@@ -0,0 +1,211 @@
+package easyslog
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord() Record {
+	return Record{
+		Attrs: []*Attr{
+			{Key: "id", Value: slog.StringValue("first")},
+			{Key: "id", Value: slog.StringValue("second")},
+			{Key: "http", Children: []*Attr{
+				{Key: "method", Value: slog.StringValue("GET")},
+				{Key: "request", Children: []*Attr{
+					{Key: "path", Value: slog.StringValue("/")},
+				}},
+			}},
+		},
+	}
+}
+
+func TestRecordGet(t *testing.T) {
+	record := testRecord()
+
+	value, ok := record.Get("http", "request", "path")
+	require.True(t, ok)
+	require.Equal(t, "/", value.String())
+
+	// Duplicate keys resolve to the first match
+	value, ok = record.Get("id")
+	require.True(t, ok)
+	require.Equal(t, "first", value.String())
+
+	_, ok = record.Get("http", "missing")
+	require.False(t, ok)
+
+	// Groups aren't values
+	_, ok = record.Get("http")
+	require.False(t, ok)
+
+	// A leaf hit before the end of the path
+	_, ok = record.Get("http", "method", "verb")
+	require.False(t, ok)
+
+	_, ok = record.Get()
+	require.False(t, ok)
+}
+
+func TestAttrGet(t *testing.T) {
+	record := testRecord()
+	http := record.Attrs[2]
+
+	value, ok := http.Get("request", "path")
+	require.True(t, ok)
+	require.Equal(t, "/", value.String())
+
+	_, ok = http.Get("http")
+	require.False(t, ok)
+}
+
+func TestRecordDelete(t *testing.T) {
+	record := testRecord()
+
+	require.True(t, record.Delete("id"))
+	value, ok := record.Get("id")
+	require.True(t, ok)
+	require.Equal(t, "second", value.String())
+
+	require.True(t, record.Delete("http", "method"))
+	_, ok = record.Get("http", "method")
+	require.False(t, ok)
+
+	require.False(t, record.Delete("http", "missing"))
+	require.False(t, record.Delete("id", "nested"))
+	require.False(t, record.Delete())
+
+	// Removing the last leaf removes the now-empty groups above it
+	require.True(t, record.Delete("http", "request", "path"))
+	require.Len(t, record.Attrs, 1)
+	require.Equal(t, "id", record.Attrs[0].Key)
+}
+
+func TestRecordWithout(t *testing.T) {
+	record := testRecord()
+
+	without := record.Without("http", "request", "path")
+	require.Equal(t, []string{"id", "id", "http.method"}, keys(without.Flatten(".")))
+
+	without = record.Without("id")
+	value, ok := without.Get("id")
+	require.True(t, ok)
+	require.Equal(t, "second", value.String())
+
+	// Missing paths and leaves in the middle of a path leave the record as-is
+	require.Len(t, record.Without("http", "missing").Attrs, 3)
+	require.Len(t, record.Without("id", "nested").Attrs, 3)
+	require.Len(t, record.Without().Attrs, 3)
+
+	// The original tree is left alone
+	require.Equal(t, testRecord(), record)
+}
+
+func TestRecordFlatten(t *testing.T) {
+	record := testRecord()
+	record.Attrs = append(record.Attrs, &Attr{Key: "empty", group: true})
+
+	flat := record.Flatten("_")
+	require.Equal(t, []string{"id", "id", "http_method", "http_request_path"}, keys(flat))
+	require.Equal(t, "GET", flat[2].Value.String())
+	require.Equal(t, "/", flat[3].Value.String())
+
+	// The original tree is left alone
+	flat[3].Key = "changed"
+	require.Equal(t, "path", record.Attrs[2].Children[1].Children[0].Key)
+	require.Equal(t, []string{"http.method", "http.request.path"}, keys(record.Flatten(".")[2:]))
+
+	require.Empty(t, Record{}.Flatten("."))
+}
+
+func TestRawJSON(t *testing.T) {
+	formatter := &recordingFormatter{}
+	slog.New(New(io.Discard, formatter, nil)).Info("msg",
+		RawJSON("payload", []byte(`{"a":1}`)),
+		slog.Group("g", RawJSON("nested", []byte(`[1,2]`))),
+		"plain", []byte(`{"a":1}`),
+	)
+
+	attrs := formatter.records[0].Attrs
+	data, ok := attrs[0].RawJSON()
+	require.True(t, ok)
+	require.Equal(t, `{"a":1}`, string(data))
+	require.Equal(t, `{"a":1}`, attrs[0].Value.String())
+
+	data, ok = attrs[1].Children[0].RawJSON()
+	require.True(t, ok)
+	require.Equal(t, `[1,2]`, string(data))
+
+	_, ok = attrs[1].RawJSON()
+	require.False(t, ok)
+	_, ok = attrs[2].RawJSON()
+	require.False(t, ok)
+}
+
+// persistence maps the dotted path of every attribute in attrs to its
+// Persistent flag.
+func persistence(attrs []*Attr, prefix string, dst map[string]bool) map[string]bool {
+	for _, attr := range attrs {
+		dst[prefix+attr.Key] = attr.Persistent
+		persistence(attr.Children, prefix+attr.Key+".", dst)
+	}
+
+	return dst
+}
+
+func TestPersistentAttrs(t *testing.T) {
+	for _, reuse := range []bool{false, true} {
+		var records []Record
+		l := slog.New(New(io.Discard, &recordingFormatter{}, &Options{
+			ReuseAttrs: reuse,
+			Prelude:    []slog.Attr{slog.String("host", "web1")},
+			BaseAttrs:  []slog.Attr{slog.String("app", "shop")},
+			// Clones keep the flag
+			Tap: func(r Record) { records = append(records, r.Clone()) },
+		}))
+
+		http := l.With("request_id", "abc", slog.Group("user", "id", 1)).WithGroup("http").With("method", "GET")
+		http.Info("msg", "status", 200, slog.Group("timing", "took", 3))
+		// The empty group is pruned and doesn't affect its parent's flag
+		http.WithGroup("empty").Info("msg")
+
+		require.Equal(t, map[string]bool{
+			"host":             true,
+			"app":              true,
+			"request_id":       true,
+			"user":             true,
+			"user.id":          true,
+			"http":             true,
+			"http.method":      true,
+			"http.status":      false,
+			"http.timing":      false,
+			"http.timing.took": false,
+		}, persistence(records[0].Attrs, "", map[string]bool{}), reuse)
+
+		require.Equal(t, map[string]bool{
+			"host":        true,
+			"app":         true,
+			"request_id":  true,
+			"user":        true,
+			"user.id":     true,
+			"http":        true,
+			"http.method": true,
+		}, persistence(records[1].Attrs, "", map[string]bool{}), reuse)
+
+		flat := records[0].Flatten(".")
+		require.Equal(t, []string{"host", "app", "request_id", "user.id", "http.method", "http.status", "http.timing.took"}, keys(flat))
+		require.True(t, flat[4].Persistent)
+		require.False(t, flat[5].Persistent)
+	}
+}
+
+func TestPersistentFlatAttrs(t *testing.T) {
+	formatter := &recordingFormatter{}
+	slog.New(New(io.Discard, formatter, &Options{FlattenGroups: ".", BaseAttrs: []slog.Attr{slog.Int("base", 1)}})).
+		With("a", 1).WithGroup("g").With("b", 2).Info("msg", "c", 3)
+
+	require.Equal(t, map[string]bool{"base": true, "a": true, "g.b": true, "g.c": false}, persistence(formatter.records[0].Attrs, "", map[string]bool{}))
+}
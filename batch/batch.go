@@ -0,0 +1,226 @@
+// Package batch provides an io.Writer that coalesces log lines and writes them
+// to an underlying writer in batches.
+package batch
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrClosed is returned by Write after Close has been called.
+	ErrClosed = errors.New("batch: writer closed")
+	// ErrBufferFull is returned by Write for lines dropped because
+	// Options.MaxBufferedBytes are already buffered.
+	ErrBufferFull = errors.New("batch: buffer full")
+)
+
+const (
+	// DefaultMaxBytes is used when Options.MaxBytes is zero.
+	DefaultMaxBytes = 64 * 1024
+	// DefaultFlushInterval is used when Options.FlushInterval is zero.
+	DefaultFlushInterval = time.Second
+	// DefaultMaxBufferedBytes is used when Options.MaxBufferedBytes is zero.
+	DefaultMaxBufferedBytes = 4 * 1024 * 1024
+)
+
+// Options to configure a Writer.
+type Options struct {
+	// MaxBytes triggers a flush once the buffered lines reach this size.
+	// Defaults to DefaultMaxBytes.
+	MaxBytes int
+	// MaxLines triggers a flush once this many lines are buffered. Zero means
+	// no line limit.
+	MaxLines int
+	// FlushInterval is how often buffered lines are flushed regardless of
+	// size. Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+	// MaxBufferedBytes caps the lines buffered while the underlying writer
+	// is busy, so a stalled writer can't grow the buffer without limit.
+	// Lines that don't fit are dropped, counted by Dropped, and Write
+	// returns ErrBufferFull for them. Defaults to DefaultMaxBufferedBytes,
+	// and is raised to MaxBytes if it's lower.
+	MaxBufferedBytes int
+}
+
+// Writer buffers lines in memory and writes them to the underlying writer from
+// its own goroutine. Each call to Write is treated as one whole line, which
+// matches how easyslog.EasySlog writes records, so a batch never contains a
+// partial line.
+//
+// Write only appends to a buffer, so it's cheap to call while the handler
+// holds its writer mutex even when the underlying writer is slow.
+type Writer struct {
+	w    io.Writer
+	opts Options
+
+	mu     sync.Mutex
+	buf    []byte
+	spare  []byte
+	lines  int
+	closed bool
+
+	dropped atomic.Uint64
+
+	// flushMu serializes writes to w
+	flushMu sync.Mutex
+	err     error
+
+	flushCh chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// New returns a Writer that batches writes to w. Close must be called to stop
+// the flushing goroutine and flush any remaining lines.
+func New(w io.Writer, opts *Options) *Writer {
+	var options Options
+	if opts != nil {
+		options = *opts
+	}
+
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultMaxBytes
+	}
+
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+
+	if options.MaxBufferedBytes <= 0 {
+		options.MaxBufferedBytes = DefaultMaxBufferedBytes
+	}
+	options.MaxBufferedBytes = max(options.MaxBufferedBytes, options.MaxBytes)
+
+	bw := &Writer{
+		w:       w,
+		opts:    options,
+		flushCh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	bw.wg.Add(1)
+	go bw.run()
+
+	return bw
+}
+
+// Write appends p to the current batch as a single line and signals the
+// flushing goroutine if a size limit has been reached. Lines that would take
+// the batch past Options.MaxBufferedBytes are dropped, unless the batch is
+// empty.
+func (bw *Writer) Write(p []byte) (int, error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if bw.closed {
+		return 0, ErrClosed
+	}
+
+	if len(bw.buf) > 0 && len(bw.buf)+len(p) > bw.opts.MaxBufferedBytes {
+		bw.dropped.Add(1)
+		return 0, ErrBufferFull
+	}
+
+	bw.buf = append(bw.buf, p...)
+	bw.lines++
+
+	if len(bw.buf) >= bw.opts.MaxBytes || (bw.opts.MaxLines > 0 && bw.lines >= bw.opts.MaxLines) {
+		select {
+		case bw.flushCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of lines dropped because the buffer was full.
+func (bw *Writer) Dropped() uint64 {
+	return bw.dropped.Load()
+}
+
+// Flush synchronously writes all buffered lines to the underlying writer. It
+// returns the error from that write, if any.
+func (bw *Writer) Flush() error {
+	return bw.flush()
+}
+
+// Close stops the flushing goroutine and flushes any buffered lines. The
+// underlying writer is not closed. Calling Close more than once is safe.
+func (bw *Writer) Close() error {
+	bw.mu.Lock()
+	if bw.closed {
+		bw.mu.Unlock()
+		return nil
+	}
+	bw.closed = true
+	bw.mu.Unlock()
+
+	close(bw.done)
+	bw.wg.Wait()
+
+	return bw.flush()
+}
+
+func (bw *Writer) run() {
+	defer bw.wg.Done()
+
+	ticker := time.NewTicker(bw.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-bw.flushCh:
+		case <-bw.done:
+			return
+		}
+
+		// Errors are kept for the next call to Flush or Close
+		bw.flushMu.Lock()
+		if err := bw.flushLocked(); err != nil {
+			bw.err = err
+		}
+		bw.flushMu.Unlock()
+	}
+}
+
+func (bw *Writer) flush() error {
+	bw.flushMu.Lock()
+	defer bw.flushMu.Unlock()
+
+	err := bw.flushLocked()
+	if err == nil {
+		err = bw.err
+	}
+	bw.err = nil
+
+	return err
+}
+
+// flushLocked swaps out the current batch and writes it. flushMu must be held.
+func (bw *Writer) flushLocked() error {
+	bw.mu.Lock()
+	data := bw.buf
+	bw.buf = bw.spare[:0]
+	bw.lines = 0
+	bw.mu.Unlock()
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := bw.w.Write(data)
+
+	bw.mu.Lock()
+	bw.spare = data[:0]
+	bw.mu.Unlock()
+
+	return err
+}
@@ -0,0 +1,191 @@
+package batch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/jsonlog"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter keeps each Write call separately and can be made slow
+type recordingWriter struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	writes [][]byte
+	err    error
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.writes = append(w.writes, bytes.Clone(p))
+	return len(p), nil
+}
+
+func (w *recordingWriter) Writes() [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.writes
+}
+
+func TestFlushOnClose(t *testing.T) {
+	downstream := &recordingWriter{}
+	w := New(downstream, &Options{FlushInterval: time.Hour})
+
+	l := slog.New(easyslog.New(w, jsonlog.Formatter{}, nil))
+	l.Info("one")
+	l.Info("two")
+
+	require.Empty(t, downstream.Writes())
+	require.NoError(t, w.Close())
+
+	writes := downstream.Writes()
+	require.Len(t, writes, 1)
+	require.Equal(t, 2, bytes.Count(writes[0], []byte{'\n'}))
+
+	_, err := w.Write([]byte("late\n"))
+	require.ErrorIs(t, err, ErrClosed)
+	require.NoError(t, w.Close())
+}
+
+func TestFlushInterval(t *testing.T) {
+	downstream := &recordingWriter{}
+	w := New(downstream, &Options{FlushInterval: 10 * time.Millisecond})
+	defer w.Close()
+
+	_, _ = w.Write([]byte("line\n"))
+
+	require.Eventually(t, func() bool {
+		return len(downstream.Writes()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMaxLines(t *testing.T) {
+	downstream := &recordingWriter{}
+	w := New(downstream, &Options{MaxLines: 3, FlushInterval: time.Hour})
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		_, _ = fmt.Fprintf(w, "line %d\n", i)
+	}
+
+	require.Eventually(t, func() bool {
+		writes := downstream.Writes()
+		return len(writes) == 1 && string(writes[0]) == "line 0\nline 1\nline 2\n"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMaxBytes(t *testing.T) {
+	downstream := &recordingWriter{}
+	w := New(downstream, &Options{MaxBytes: 10, FlushInterval: time.Hour})
+	defer w.Close()
+
+	_, _ = w.Write([]byte("0123456789\n"))
+
+	require.Eventually(t, func() bool {
+		return len(downstream.Writes()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestConcurrentSlowDownstream(t *testing.T) {
+	downstream := &recordingWriter{delay: 5 * time.Millisecond}
+	w := New(downstream, &Options{MaxLines: 10, FlushInterval: time.Millisecond})
+
+	l := slog.New(easyslog.New(w, jsonlog.Formatter{}, nil))
+
+	const goroutines, lines = 20, 50
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				l.Info("message", "goroutine", i, "line", j)
+			}
+		}(i)
+	}
+
+	start := time.Now()
+	wg.Wait()
+	// Writes only append to a buffer, so logging shouldn't wait on downstream
+	require.Less(t, time.Since(start), time.Second)
+	require.NoError(t, w.Close())
+
+	total := 0
+	for _, write := range downstream.Writes() {
+		require.Equal(t, byte('\n'), write[len(write)-1], "batches must only contain whole lines")
+		total += bytes.Count(write, []byte{'\n'})
+	}
+	require.Equal(t, goroutines*lines, total)
+}
+
+// stalledWriter blocks every Write until release is closed
+type stalledWriter struct {
+	recordingWriter
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+
+	return w.recordingWriter.Write(p)
+}
+
+func TestMaxBufferedBytes(t *testing.T) {
+	downstream := &stalledWriter{started: make(chan struct{}), release: make(chan struct{})}
+	w := New(downstream, &Options{MaxBytes: 10, MaxBufferedBytes: 20, FlushInterval: time.Hour})
+
+	// The first batch is stuck in the downstream Write
+	_, _ = w.Write([]byte("0123456789\n"))
+	<-downstream.started
+
+	written := 0
+	for i := 0; i < 10; i++ {
+		if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+			require.ErrorIs(t, err, ErrBufferFull)
+			continue
+		}
+		written++
+	}
+
+	// Only what fits in MaxBufferedBytes is kept
+	require.Equal(t, 2, written)
+	require.Equal(t, uint64(8), w.Dropped())
+
+	close(downstream.release)
+	require.NoError(t, w.Close())
+
+	var out []byte
+	for _, write := range downstream.Writes() {
+		out = append(out, write...)
+	}
+	require.Equal(t, "0123456789\nline 0\nline 1\n", string(out))
+}
+
+func TestFlushError(t *testing.T) {
+	boom := errors.New("boom")
+	downstream := &recordingWriter{err: boom}
+	w := New(downstream, &Options{FlushInterval: time.Hour})
+
+	_, _ = w.Write([]byte("line\n"))
+	require.ErrorIs(t, w.Flush(), boom)
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,94 @@
+package easyslog
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWriteRetryInterval is used when Options.WriteFailureThreshold is set
+// without a WriteRetryInterval.
+const DefaultWriteRetryInterval = time.Second
+
+// ErrWriteSuppressed is returned by Handle for records dropped because writes
+// are suppressed after Options.WriteFailureThreshold consecutive write errors.
+var ErrWriteSuppressed = errors.New("easyslog: writes suppressed after repeated write errors")
+
+// writeBreaker stops writing lines after repeated write errors, see
+// Options.WriteFailureThreshold. It's shared by every handler writing to the
+// same output and only uses atomics, since lines may be written concurrently
+// under the read lock.
+type writeBreaker struct {
+	// failures counts consecutive write errors.
+	failures atomic.Int64
+	// until is when the next probe may be made, in Unix nanoseconds, or zero
+	// while lines are written as usual.
+	until atomic.Int64
+	// dropped counts the records dropped while suppressed.
+	dropped atomic.Uint64
+}
+
+// allow reports whether a line may be written. While suppressed only the
+// first call after each retry interval is let through, to probe the writer.
+func (b *writeBreaker) allow(interval time.Duration) bool {
+	until := b.until.Load()
+	if until == 0 {
+		return true
+	}
+
+	now := time.Now().UnixNano()
+	if now >= until && b.until.CompareAndSwap(until, now+int64(interval)) {
+		return true
+	}
+
+	b.dropped.Add(1)
+	return false
+}
+
+// failed records a write error, suppressing writes for interval once
+// threshold consecutive writes have failed, including failed probes.
+func (b *writeBreaker) failed(threshold int, interval time.Duration) {
+	if b.failures.Add(1) >= int64(threshold) {
+		b.until.Store(time.Now().Add(interval).UnixNano())
+	}
+}
+
+// succeeded records a successful write, returning the number of records
+// dropped since writes were suppressed.
+func (b *writeBreaker) succeeded() uint64 {
+	if b.failures.Load() != 0 {
+		b.failures.Store(0)
+	}
+
+	if b.until.Load() == 0 && b.dropped.Load() == 0 {
+		return 0
+	}
+	b.until.Store(0)
+
+	return b.dropped.Swap(0)
+}
+
+// reset clears the failures of a previous writer. Records it dropped are
+// still reported after the next successful write.
+func (b *writeBreaker) reset() {
+	b.failures.Store(0)
+	b.until.Store(0)
+}
+
+// writeLine writes line to w, retrying the rest of a short write once. Writes
+// that fail without writing anything aren't retried.
+func writeLine(w io.Writer, line []byte) (int, error) {
+	n, err := w.Write(line)
+	if n >= len(line) || (n <= 0 && err != nil) {
+		return n, err
+	}
+
+	m, err := w.Write(line[n:])
+	n += m
+	if err == nil && n < len(line) {
+		err = io.ErrShortWrite
+	}
+
+	return n, err
+}
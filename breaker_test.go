@@ -0,0 +1,148 @@
+package easyslog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flakyWriter fails its first failures writes with ENOSPC.
+type flakyWriter struct {
+	bytes.Buffer
+	failures int
+	writes   int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes <= w.failures {
+		return 0, syscall.ENOSPC
+	}
+
+	return w.Buffer.Write(p)
+}
+
+// shortWriter writes at most max bytes per call.
+type shortWriter struct {
+	bytes.Buffer
+	max    int
+	writes int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.max {
+		p = p[:w.max]
+	}
+
+	return w.Buffer.Write(p)
+}
+
+func TestWriteFailureThreshold(t *testing.T) {
+	w := &flakyWriter{failures: 3}
+	formatted := 0
+	formatter := FormatterFunc(func(w io.Writer, r Record) error {
+		formatted++
+		_, err := io.WriteString(w, r.Message)
+		return err
+	})
+
+	const interval = 50 * time.Millisecond
+	handler := New(w, formatter, &Options{WriteFailureThreshold: 2, WriteRetryInterval: interval})
+	log := func(msg string) error {
+		return handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0))
+	}
+
+	require.ErrorIs(t, log("a"), syscall.ENOSPC)
+	require.ErrorIs(t, log("b"), syscall.ENOSPC)
+
+	// Suppressed records are dropped before they're formatted
+	require.ErrorIs(t, log("c"), ErrWriteSuppressed)
+	require.ErrorIs(t, log("d"), ErrWriteSuppressed)
+	require.ErrorIs(t, log("e"), ErrWriteSuppressed)
+	require.Equal(t, 2, formatted)
+	require.Equal(t, 2, w.writes)
+
+	// A failed probe suppresses writes for another interval
+	time.Sleep(interval + 10*time.Millisecond)
+	require.ErrorIs(t, log("f"), syscall.ENOSPC)
+	require.ErrorIs(t, log("g"), ErrWriteSuppressed)
+	require.Equal(t, 3, formatted)
+
+	time.Sleep(interval + 10*time.Millisecond)
+	require.NoError(t, log("h"))
+	require.NoError(t, log("i"))
+
+	require.Equal(t, "h\neasyslog: dropped 4 records after write errors\ni\n", w.String())
+	require.Equal(t, Stats{Info: 2, Bytes: 4, WriteErrors: 3, Suppressed: 4}, handler.Stats())
+}
+
+func TestWriteFailureThresholdAfterFilters(t *testing.T) {
+	w := &flakyWriter{failures: 1}
+	const interval = 50 * time.Millisecond
+	handler := New(w, FormatterFunc(func(w io.Writer, r Record) error {
+		_, err := io.WriteString(w, r.Message)
+		return err
+	}), &Options{
+		WriteFailureThreshold: 1,
+		WriteRetryInterval:    interval,
+		Sampler:               func(_ context.Context, r slog.Record) bool { return r.Message != "sampled" },
+	})
+	log := func(msg string) error {
+		return handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0))
+	}
+
+	require.ErrorIs(t, log("a"), syscall.ENOSPC)
+	require.ErrorIs(t, log("b"), ErrWriteSuppressed)
+
+	// Filtered records are dropped as usual and don't take the probe
+	time.Sleep(interval + 10*time.Millisecond)
+	require.NoError(t, log("sampled"))
+	require.NoError(t, log("c"))
+
+	require.Equal(t, "c\neasyslog: dropped 1 records after write errors\n", w.String())
+	require.Equal(t, Stats{Info: 1, Bytes: 2, WriteErrors: 1, Sampled: 1, Suppressed: 1}, handler.Stats())
+}
+
+func TestWriteFailureThresholdResetsOnSuccess(t *testing.T) {
+	w := &flakyWriter{failures: 1}
+	handler := New(w, FormatterFunc(func(w io.Writer, r Record) error {
+		_, err := io.WriteString(w, r.Message)
+		return err
+	}), &Options{WriteFailureThreshold: 2})
+	l := slog.New(handler)
+
+	l.Info("lost")
+	l.Info("a")
+	w.failures, w.writes = 1, 0
+	l.Info("lost")
+	l.Info("b")
+
+	// Failures only count while consecutive
+	require.Equal(t, "a\nb\n", w.String())
+	require.Zero(t, handler.Stats().Suppressed)
+}
+
+func TestShortWrites(t *testing.T) {
+	w := &shortWriter{max: 8}
+	handler := New(w, FormatterFunc(func(w io.Writer, r Record) error {
+		_, err := io.WriteString(w, r.Message)
+		return err
+	}), nil)
+
+	// The rest of a short write is retried once
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "0123456789", 0)))
+	require.Equal(t, "0123456789\n", w.String())
+	require.Equal(t, 2, w.writes)
+
+	w.Reset()
+	err := handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "0123456789abcdef", 0))
+	require.ErrorIs(t, err, io.ErrShortWrite)
+	require.Equal(t, uint64(1), handler.Stats().WriteErrors)
+}
@@ -0,0 +1,159 @@
+// Package canonicalformat implements a deterministic formatter intended for
+// snapshot tests. Every record is rendered on a single line with keys sorted
+// recursively and timestamps normalized, so output can be compared verbatim.
+package canonicalformat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// TimePlaceholder replaces time values unless Formatter.KeepTime is set.
+const TimePlaceholder = "<time>"
+
+// Formatter implements easyslog.Formatter and renders records as
+// `time=<time> level=INFO msg="x" a=1 b.c=2`, with attribute keys sorted
+// within each group and nested groups joined with dots.
+type Formatter struct {
+	// KeepTime renders times as RFC3339 in UTC instead of TimePlaceholder.
+	KeepTime bool
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("canonical", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	var buf bytes.Buffer
+
+	if !record.Time.IsZero() {
+		buf.WriteString("time=")
+		buf.WriteString(f.time(record.Time))
+		buf.WriteByte(' ')
+	}
+
+	buf.WriteString("level=")
+	buf.WriteString(record.LevelString())
+	buf.WriteString(" msg=")
+	buf.WriteString(strconv.Quote(record.Message))
+
+	f.writeAttrs(&buf, record.Attrs, "")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (f Formatter) writeAttrs(buf *bytes.Buffer, attrs []*easyslog.Attr, prefix string) {
+	// Sort a copy so the record's own tree is left untouched
+	sorted := slices.Clone(attrs)
+	slices.SortStableFunc(sorted, func(a, b *easyslog.Attr) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	for _, attr := range sorted {
+		key := attr.Key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if attr.IsGroup() {
+			f.writeAttrs(buf, attr.Children, key)
+			continue
+		}
+
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(f.value(attr.Value))
+	}
+}
+
+// value renders v deterministically for each slog.Kind. Strings and values of
+// unknown types are always quoted.
+func (f Formatter) value(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindString:
+		return strconv.Quote(v.String())
+	case slog.KindInt64:
+		return strconv.FormatInt(v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.FormatUint(v.Uint64(), 10)
+	case slog.KindFloat64:
+		return strconv.FormatFloat(v.Float64(), 'g', -1, 64)
+	case slog.KindBool:
+		return strconv.FormatBool(v.Bool())
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return f.time(v.Time())
+	case slog.KindLogValuer:
+		return f.value(v.Resolve())
+	}
+
+	switch value := v.Any().(type) {
+	case error:
+		return strconv.Quote(value.Error())
+	case []byte:
+		return strconv.Quote(fmt.Sprintf("%x", value))
+	default:
+		return strconv.Quote(fmt.Sprintf("%+v", value))
+	}
+}
+
+func (f Formatter) time(t time.Time) string {
+	if !f.KeepTime {
+		return TimePlaceholder
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// AssertLog compares the lines written to buf against expected, reporting each
+// differing, missing, or unexpected line. It returns true if they match.
+func AssertLog(t testing.TB, buf *bytes.Buffer, expected []string) bool {
+	t.Helper()
+
+	actual := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if buf.Len() == 0 {
+		actual = nil
+	}
+
+	if slices.Equal(actual, expected) {
+		return true
+	}
+
+	var msg strings.Builder
+	msg.WriteString("log output differs:\n")
+
+	for i := 0; i < max(len(actual), len(expected)); i++ {
+		switch {
+		case i >= len(actual):
+			fmt.Fprintf(&msg, "line %d missing:\n  expected: %s\n", i+1, expected[i])
+		case i >= len(expected):
+			fmt.Fprintf(&msg, "line %d unexpected:\n  actual:   %s\n", i+1, actual[i])
+		case actual[i] != expected[i]:
+			fmt.Fprintf(&msg, "line %d:\n  expected: %s\n  actual:   %s\n", i+1, expected[i], actual[i])
+		}
+	}
+
+	t.Error(msg.String())
+	return false
+}
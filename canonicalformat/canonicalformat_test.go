@@ -0,0 +1,117 @@
+package canonicalformat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+type lazy struct{}
+
+func (lazy) LogValue() slog.Value {
+	return slog.StringValue("resolved")
+}
+
+func TestKinds(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+	ts := time.Date(2023, 9, 9, 13, 37, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	l.Info("kinds",
+		slog.String("string", "a \"quoted\" value"),
+		slog.Int64("int", -42),
+		slog.Uint64("uint", 42),
+		slog.Float64("float", 1e21),
+		slog.Float64("small", 0.1),
+		slog.Bool("bool", true),
+		slog.Duration("duration", 1500*time.Millisecond),
+		slog.Time("time", ts),
+		slog.Any("valuer", lazy{}),
+		slog.Any("err", errors.New("boom")),
+		slog.Any("bytes", []byte("hi")),
+		slog.Any("struct", user{ID: 1, Name: "fox"}),
+	)
+
+	AssertLog(t, &buf, []string{
+		`time=<time> level=INFO msg="kinds" bool=true bytes="6869" duration=1.5s err="boom" float=1e+21 int=-42 small=0.1 string="a \"quoted\" value" struct="{ID:1 Name:fox}" time=<time> uint=42 valuer="resolved"`,
+	})
+}
+
+func TestKeepTime(t *testing.T) {
+	var buf bytes.Buffer
+	ts := time.Date(2023, 9, 9, 13, 37, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	err := Formatter{KeepTime: true}.Format(&buf, easyslog.Record{
+		Time:    ts,
+		Level:   slog.LevelWarn,
+		Message: "hi",
+		Attrs:   []*easyslog.Attr{{Key: "at", Value: slog.TimeValue(ts)}},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, `time=2023-09-09T18:37:00Z level=WARN msg="hi" at=2023-09-09T18:37:00Z`, buf.String())
+}
+
+func TestSortedRecursively(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.With("z", 1).WithGroup("b").Info("sorted", "y", 2, "a", 3, slog.Group("c", "k", 4, "d", 5))
+	l.Info("no attrs")
+
+	AssertLog(t, &buf, []string{
+		`time=<time> level=INFO msg="sorted" b.a=3 b.c.d=5 b.c.k=4 b.y=2 z=1`,
+		`time=<time> level=INFO msg="no attrs"`,
+	})
+}
+
+func TestDoesNotMutateRecord(t *testing.T) {
+	attrs := []*easyslog.Attr{
+		{Key: "b", Value: slog.IntValue(1)},
+		{Key: "a", Value: slog.IntValue(2)},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Formatter{}.Format(&buf, easyslog.Record{Message: "m", Attrs: attrs}))
+
+	require.Equal(t, `level=INFO msg="m" a=2 b=1`, buf.String())
+	require.Equal(t, "b", attrs[0].Key)
+}
+
+type fakeT struct {
+	testing.TB
+	messages []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Error(args ...any) {
+	f.messages = append(f.messages, fmt.Sprint(args...))
+}
+
+func TestAssertLogFailureMessage(t *testing.T) {
+	buf := bytes.NewBufferString("level=INFO msg=\"a\"\nlevel=INFO msg=\"b\"\n")
+	ft := &fakeT{}
+
+	require.False(t, AssertLog(ft, buf, []string{`level=INFO msg="a"`, `level=INFO msg="c"`, `level=INFO msg="d"`}))
+	require.Len(t, ft.messages, 1)
+
+	msg := ft.messages[0]
+	require.True(t, strings.Contains(msg, "line 2:\n  expected: level=INFO msg=\"c\"\n  actual:   level=INFO msg=\"b\"\n"), msg)
+	require.True(t, strings.Contains(msg, "line 3 missing:\n  expected: level=INFO msg=\"d\"\n"), msg)
+	require.False(t, strings.Contains(msg, "line 1"), msg)
+
+	require.True(t, AssertLog(ft, &bytes.Buffer{}, nil))
+}
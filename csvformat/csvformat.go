@@ -0,0 +1,223 @@
+// Package csvformat implements an easyslog.Formatter that renders records as
+// delimiter-separated rows with a fixed column schema, e.g. for loading into
+// DuckDB or a spreadsheet.
+package csvformat
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// Pseudo-columns that render the record's own fields rather than attributes.
+const (
+	TimeColumn    = "time"
+	LevelColumn   = "level"
+	MessageColumn = "msg"
+	// ExtraColumn is the header of the column holding attributes not listed
+	// in Columns when Formatter.Extra is set.
+	ExtraColumn = "extra"
+)
+
+// Formatter implements easyslog.Formatter and renders one row per record.
+// Values are quoted per RFC 4180.
+type Formatter struct {
+	// Columns lists the columns to render, in order. Each is either a
+	// pseudo-column (time, level, msg) or a dot-separated path into the
+	// attribute tree, e.g. `request.method`. Missing attributes render empty.
+	Columns []string
+	// Delimiter separates fields. Defaults to ','; use '\t' for TSV.
+	Delimiter rune
+	// WriteHeader emits a header row before the first row written to the
+	// handler's writer, see easyslog.HeaderFormatter. Rows formatted by
+	// calling Format directly don't get one.
+	WriteHeader bool
+	// Extra gathers every attribute not listed in Columns into a final
+	// `extra` column as a JSON object. When false, they're dropped.
+	Extra bool
+}
+
+var _ easyslog.HeaderFormatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("csv", func(opts map[string]any) (easyslog.Formatter, error) {
+		// Accept the delimiter as a one character string, e.g. "\t", since
+		// configs can't easily express a rune
+		decoded := make(map[string]any, len(opts))
+		for key, value := range opts {
+			if s, ok := value.(string); ok && strings.EqualFold(key, "delimiter") {
+				if utf8.RuneCountInString(s) != 1 {
+					return nil, fmt.Errorf("csvformat: delimiter %q must be a single character", s)
+				}
+
+				value, _ = utf8.DecodeRuneInString(s)
+			}
+
+			decoded[key] = value
+		}
+
+		f := &Formatter{}
+		if err := easyslog.DecodeOptions(decoded, f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+// Header implements easyslog.HeaderFormatter and writes the header row when
+// WriteHeader is set.
+func (f *Formatter) Header(w io.Writer) error {
+	if !f.WriteHeader {
+		return nil
+	}
+
+	header := f.Columns
+	if f.Extra {
+		header = append(header[:len(header):len(header)], ExtraColumn)
+	}
+
+	return f.writeRow(w, header)
+}
+
+func (f *Formatter) Format(w io.Writer, record easyslog.Record) error {
+	row := make([]string, 0, len(f.Columns)+1)
+	for _, column := range f.Columns {
+		row = append(row, columnValue(record, column))
+	}
+
+	if f.Extra {
+		extra, err := f.extra(record)
+		if err != nil {
+			return err
+		}
+		row = append(row, extra)
+	}
+
+	return f.writeRow(w, row)
+}
+
+// writeRow writes row to w as a single write, without the row terminator
+// since Handle adds the newline.
+func (f *Formatter) writeRow(w io.Writer, row []string) error {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if f.Delimiter != 0 {
+		cw.Comma = f.Delimiter
+	}
+
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+	return err
+}
+
+func columnValue(record easyslog.Record, column string) string {
+	switch column {
+	case TimeColumn:
+		if record.Time.IsZero() {
+			return ""
+		}
+		return record.Time.Format(time.RFC3339Nano)
+	case LevelColumn:
+		return record.LevelString()
+	case MessageColumn:
+		return record.Message
+	}
+
+	value, ok := record.Get(strings.Split(column, ".")...)
+	if !ok {
+		return ""
+	}
+
+	return value.String()
+}
+
+// extra renders every leaf not listed in Columns as a nested JSON object.
+func (f *Formatter) extra(record easyslog.Record) (string, error) {
+	listed := make(map[string]bool, len(f.Columns))
+	for _, column := range f.Columns {
+		listed[column] = true
+	}
+
+	result := make(map[string]any)
+	collectExtra(result, record.Attrs, "", listed)
+
+	if len(result) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(result)
+	return string(b), err
+}
+
+func collectExtra(dst map[string]any, attrs []*easyslog.Attr, prefix string, listed map[string]bool) {
+	for _, attr := range attrs {
+		path := attr.Key
+		if prefix != "" {
+			path = prefix + "." + attr.Key
+		}
+
+		if attr.IsGroup() {
+			group, ok := dst[attr.Key].(map[string]any)
+			if !ok {
+				group = make(map[string]any)
+			}
+
+			collectExtra(group, attr.Children, path, listed)
+			if len(group) > 0 {
+				dst[attr.Key] = group
+			}
+			continue
+		}
+
+		if listed[path] {
+			continue
+		}
+
+		dst[attr.Key] = jsonValue(attr.Value)
+	}
+}
+
+// jsonValue returns v as a value json.Marshal can encode. Like jsonlog, NaN
+// and infinities, which JSON can't represent, become the strings "NaN", "+Inf"
+// and "-Inf".
+func jsonValue(v slog.Value) any {
+	switch value := v.Any().(type) {
+	case error:
+		return value.Error()
+	case float64:
+		switch {
+		case math.IsNaN(value):
+			return "NaN"
+		case math.IsInf(value, 1):
+			return "+Inf"
+		case math.IsInf(value, -1):
+			return "-Inf"
+		}
+		return value
+	case json.Marshaler, string, bool, int64, uint64, time.Time:
+		return value
+	default:
+		if v.Kind() == slog.KindDuration {
+			return v.Duration().String()
+		}
+		return v.String()
+	}
+}
@@ -0,0 +1,155 @@
+package csvformat
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"level", "msg", "user", "request.method", "missing"}}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("hello", "user", "fox", slog.Group("request", "method", "GET"), "ignored", 1)
+
+	require.Equal(t, "INFO,hello,fox,GET,\n", buf.String())
+}
+
+func TestQuoting(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"msg", "value"}}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("a, b", "value", "line one\nline \"two\"")
+
+	require.Equal(t, "\"a, b\",\"line one\nline \"\"two\"\"\"\n", buf.String())
+}
+
+func TestTSV(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"msg", "value"}, Delimiter: '\t', WriteHeader: true}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("a,b", "value", "tab\there")
+
+	require.Equal(t, "msg\tvalue\na,b\t\"tab\there\"\n", buf.String())
+}
+
+func TestNestedGroupPaths(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"http.request.method", "http.status", "http"}}
+	l := slog.New(easyslog.New(&buf, formatter, nil)).WithGroup("http")
+
+	l.Info("request", slog.Group("request", "method", "POST"), "status", 201)
+
+	// Groups aren't leaves, so a column naming one renders empty
+	require.Equal(t, "POST,201,\n", buf.String())
+}
+
+func TestExtra(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"msg", "request.method"}, Extra: true, WriteHeader: true}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("hello", slog.Group("request", "method", "GET", "path", "/"), "n", 2, "err", errors.New("boom"))
+	l.Info("bare")
+
+	require.Equal(t, ""+
+		"msg,request.method,extra\n"+
+		`hello,GET,"{""err"":""boom"",""n"":2,""request"":{""path"":""/""}}"`+"\n"+
+		"bare,,\n",
+		buf.String(),
+	)
+}
+
+func TestHeaderOnceConcurrently(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"msg", "n"}, WriteHeader: true}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l.Info("row", "n", i)
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 51)
+	require.Equal(t, "msg,n", lines[0])
+
+	headers := 0
+	for _, line := range lines {
+		if line == "msg,n" {
+			headers++
+		}
+	}
+	require.Equal(t, 1, headers)
+}
+
+// failingMarshaler fails to encode, failing the row it's logged in.
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) { return nil, errors.New("boom") }
+
+func TestHeaderAfterFailedRow(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"msg"}, Extra: true, WriteHeader: true}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("lost", "value", failingMarshaler{})
+	l.Info("kept")
+
+	require.Equal(t, "msg,extra\nkept,\n", buf.String())
+}
+
+func TestExtraNonFinite(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"msg"}, Extra: true}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("floats", "nan", math.NaN(), "inf", math.Inf(1), "ninf", math.Inf(-1))
+
+	require.Equal(t, `floats,"{""inf"":""+Inf"",""nan"":""NaN"",""ninf"":""-Inf""}"`+"\n", buf.String())
+}
+
+func TestTimeColumn(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"time", "msg"}}
+	require.NoError(t, formatter.Format(&buf, easyslog.Record{Message: "no time"}))
+
+	require.Equal(t, ",no time", buf.String())
+}
+
+func TestRawJSON(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := &Formatter{Columns: []string{"msg", "payload"}}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("raw", easyslog.RawJSON("payload", []byte(`{"a":1}`)))
+
+	require.Equal(t, "raw,\"{\"\"a\"\":1}\"\n", buf.String())
+}
+
+func TestNewFormatter(t *testing.T) {
+	formatter, err := easyslog.NewFormatter("csv", map[string]any{"columns": []any{"msg", "value"}, "delimiter": "\t"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, formatter, nil)).Info("hello", "value", 1)
+	require.Equal(t, "hello\t1\n", buf.String())
+
+	_, err = easyslog.NewFormatter("csv", map[string]any{"delimiter": "::"})
+	require.EqualError(t, err, `csvformat: delimiter "::" must be a single character`)
+}
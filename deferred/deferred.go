@@ -0,0 +1,294 @@
+// Package deferred buffers the records of a unit of work, like a request, and
+// only passes them to a handler once it's known they're wanted, e.g. because
+// the request failed or was slow (tail-based sampling).
+package deferred
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+const (
+	// DefaultMaxRecords is used when Options.MaxRecords is zero.
+	DefaultMaxRecords = 1000
+	// DefaultMaxBytes is used when Options.MaxBytes is zero.
+	DefaultMaxBytes = 1 << 20
+)
+
+// Options to configure a Collector.
+type Options struct {
+	// MaxRecords and MaxBytes bound the records a Collector buffers. Bytes
+	// are estimated from the length of the message and each attribute's key
+	// and value. Once either limit would be exceeded the buffered records are
+	// flushed and later records pass straight through, so a runaway request
+	// can't exhaust memory and its records aren't lost. Default to
+	// DefaultMaxRecords and DefaultMaxBytes.
+	MaxRecords int
+	MaxBytes   int
+}
+
+// Collector holds the records logged through the handler returned with it by
+// NewCollector until FlushIf or Discard is called. A Collector is intended
+// for a single unit of work and is safe for concurrent use.
+type Collector struct {
+	maxRecords int
+	maxBytes   int
+
+	mu      sync.Mutex
+	entries []entry
+	bytes   int
+	// passthrough is set once the limits are exceeded or collection ended, so
+	// records go straight to their handler.
+	passthrough bool
+}
+
+// entry is a buffered record along with the handler it was logged to.
+type entry struct {
+	handler slog.Handler
+	raw     slog.Record
+	record  easyslog.Record
+}
+
+// handler buffers records into a Collector. inner is the handler records are
+// eventually passed to, with the same WithAttrs and WithGroup calls applied,
+// and scopes mirrors those calls to build each easyslog.Record.
+type handler struct {
+	collector *Collector
+	inner     slog.Handler
+	scopes    []scope
+}
+
+// scope holds the attributes added via WithAttrs to a group opened via
+// WithGroup, or to the top level for the first scope.
+type scope struct {
+	group string
+	attrs []slog.Attr
+}
+
+var _ slog.Handler = (*handler)(nil)
+
+// NewCollector returns a handler that buffers the records inner is enabled for
+// instead of handling them, and the Collector that decides what happens to
+// them. opts may be nil.
+func NewCollector(inner slog.Handler, opts *Options) (slog.Handler, *Collector) {
+	var options Options
+	if opts != nil {
+		options = *opts
+	}
+
+	if options.MaxRecords <= 0 {
+		options.MaxRecords = DefaultMaxRecords
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultMaxBytes
+	}
+
+	c := &Collector{maxRecords: options.MaxRecords, maxBytes: options.MaxBytes}
+
+	return &handler{collector: c, inner: inner, scopes: []scope{{}}}, c
+}
+
+// Enabled reports whether inner is enabled for level, so only records that
+// would be handled are buffered.
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	resolved := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		resolved[i] = resolve(attr)
+	}
+
+	scopes := slices.Clone(h.scopes)
+	last := &scopes[len(scopes)-1]
+	last.attrs = append(slices.Clip(last.attrs), resolved...)
+
+	return &handler{collector: h.collector, inner: h.inner.WithAttrs(attrs), scopes: scopes}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	scopes := append(slices.Clip(h.scopes), scope{group: name})
+
+	return &handler{collector: h.collector, inner: h.inner.WithGroup(name), scopes: scopes}
+}
+
+// Handle buffers r, resolving its values so they're logged as they were at
+// the time of the call. Once the Collector's limits are exceeded, or after
+// FlushIf or Discard, r is passed to inner instead.
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	c := h.collector
+
+	c.mu.Lock()
+	if c.passthrough {
+		c.mu.Unlock()
+		return h.inner.Handle(ctx, r)
+	}
+	defer c.mu.Unlock()
+
+	raw := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		raw.AddAttrs(resolve(a))
+		return true
+	})
+
+	e := entry{handler: h.inner, raw: raw, record: h.record(raw)}
+	size := recordSize(e.record)
+
+	if len(c.entries)+1 > c.maxRecords || c.bytes+size > c.maxBytes {
+		c.passthrough = true
+		return c.flush(ctx, append(c.take(), e))
+	}
+
+	c.entries = append(c.entries, e)
+	c.bytes += size
+
+	return nil
+}
+
+// record builds the easyslog.Record for raw, nesting its attributes in the
+// groups opened via WithGroup along with the attributes added via WithAttrs.
+func (h *handler) record(raw slog.Record) easyslog.Record {
+	attrs := make([]slog.Attr, 0, raw.NumAttrs())
+	raw.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	record := easyslog.Record{Time: raw.Time, Level: raw.Level, PC: raw.PC, Message: raw.Message}
+
+	for i := len(h.scopes) - 1; i >= 0; i-- {
+		s := h.scopes[i]
+		attrs = append(slices.Clip(s.attrs), attrs...)
+		if i > 0 {
+			record.Groups = append(record.Groups, s.group)
+			attrs = []slog.Attr{{Key: s.group, Value: slog.GroupValue(attrs...)}}
+		}
+	}
+	slices.Reverse(record.Groups)
+
+	for _, attr := range attrs {
+		record.Add(attr)
+	}
+
+	return record
+}
+
+// FlushIf ends collection and passes the buffered records to their handlers
+// with ctx, keeping their original times, if predicate returns true for them.
+// Otherwise they're dropped. Records logged afterwards are passed through
+// directly. predicate is called with the Collector locked, so it must not log
+// through the Collector's handler. Errors returned by the handlers are joined.
+func (c *Collector) FlushIf(ctx context.Context, predicate func(records []easyslog.Record) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.passthrough = true
+	entries := c.take()
+
+	records := make([]easyslog.Record, len(entries))
+	for i, e := range entries {
+		records[i] = e.record
+	}
+
+	if !predicate(records) {
+		return nil
+	}
+
+	return c.flush(ctx, entries)
+}
+
+// Flush is FlushIf with a predicate that always returns true.
+func (c *Collector) Flush(ctx context.Context) error {
+	return c.FlushIf(ctx, func([]easyslog.Record) bool { return true })
+}
+
+// Discard ends collection and drops the buffered records. Records logged
+// afterwards are passed through directly.
+func (c *Collector) Discard() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.passthrough = true
+	c.take()
+}
+
+// Len returns the number of buffered records.
+func (c *Collector) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// take returns the buffered entries and empties the buffer. c.mu must be held.
+func (c *Collector) take() []entry {
+	entries := c.entries
+	c.entries = nil
+	c.bytes = 0
+
+	return entries
+}
+
+// flush passes entries to their handlers. c.mu must be held so records logged
+// concurrently can't pass through ahead of them.
+func (c *Collector) flush(ctx context.Context, entries []entry) error {
+	var errs []error
+	for _, e := range entries {
+		if err := e.handler.Handle(ctx, e.raw); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolve resolves a's value and those of any groups nested in it, so later
+// changes to a LogValuer don't affect the buffered record.
+func resolve(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		return a
+	}
+
+	group := a.Value.Group()
+	resolved := make([]slog.Attr, len(group))
+	for i, attr := range group {
+		resolved[i] = resolve(attr)
+	}
+	a.Value = slog.GroupValue(resolved...)
+
+	return a
+}
+
+// recordSize estimates the memory held by record.
+func recordSize(record easyslog.Record) int {
+	return len(record.Message) + attrsSize(record.Attrs)
+}
+
+func attrsSize(attrs []*easyslog.Attr) int {
+	size := 0
+	for _, attr := range attrs {
+		size += len(attr.Key)
+		if attr.IsGroup() {
+			size += attrsSize(attr.Children)
+		} else {
+			size += len(attr.Value.String())
+		}
+	}
+
+	return size
+}
@@ -0,0 +1,237 @@
+package deferred
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/slogtesting"
+	"github.com/stretchr/testify/require"
+)
+
+type loggerKey struct{}
+
+// deferredLogging is an example middleware that buffers the records of each
+// request and only writes them if the response status is 500 or above.
+func deferredLogging(inner slog.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, collector := NewCollector(inner, nil)
+		logger := slog.New(handler).With("path", r.URL.Path)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger)))
+
+		_ = collector.FlushIf(r.Context(), func([]easyslog.Record) bool {
+			return rec.status >= http.StatusInternalServerError
+		})
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder, inner := slogtesting.New(nil)
+
+	app := deferredLogging(inner, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := r.Context().Value(loggerKey{}).(*slog.Logger)
+		logger.Info("loading user")
+
+		if r.URL.Path == "/fail" {
+			logger.Error("query failed", "err", errors.New("timeout"))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	for _, path := range []string{"/ok", "/fail", "/ok"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	records := recorder.Records()
+	require.Len(t, records, 2)
+	require.Equal(t, "loading user", records[0].Message)
+	require.Equal(t, "query failed", records[1].Message)
+
+	path, ok := records[1].Get("path")
+	require.True(t, ok)
+	require.Equal(t, "/fail", path.String())
+}
+
+func TestFlushIf(t *testing.T) {
+	recorder, inner := slogtesting.New(nil)
+	handler, collector := NewCollector(inner, nil)
+
+	logged := time.Now().Add(-time.Minute)
+	r := slog.NewRecord(logged, slog.LevelInfo, "first", 0)
+	require.NoError(t, handler.Handle(context.Background(), r))
+
+	l := slog.New(handler).With("a", 1).WithGroup("g").With("b", 2)
+	l.Warn("second", "c", 3)
+	require.Equal(t, 2, collector.Len())
+	require.Empty(t, recorder.Records())
+
+	var seen []easyslog.Record
+	require.NoError(t, collector.FlushIf(context.Background(), func(records []easyslog.Record) bool {
+		seen = records
+		return true
+	}))
+
+	records := recorder.Records()
+	require.Len(t, records, 2)
+	require.True(t, records[0].Time.Equal(logged))
+
+	// The records seen by the predicate match those built by the inner handler
+	require.Len(t, seen, 2)
+	require.Equal(t, []string{"g"}, seen[1].Groups)
+	for i, path := range [][]string{{"a"}, {"g", "b"}, {"g", "c"}} {
+		want, ok := records[1].Get(path...)
+		require.True(t, ok, path)
+
+		got, ok := seen[1].Get(path...)
+		require.True(t, ok, path)
+		require.Equal(t, int64(i+1), got.Int64())
+		require.True(t, want.Equal(got))
+	}
+
+	// Records logged after the flush pass straight through
+	l.Info("third")
+	require.Len(t, recorder.Records(), 3)
+	require.Zero(t, collector.Len())
+}
+
+func TestFlushIfFalseAndDiscard(t *testing.T) {
+	recorder, inner := slogtesting.New(nil)
+
+	handler, collector := NewCollector(inner, nil)
+	slog.New(handler).Info("dropped")
+	require.NoError(t, collector.FlushIf(context.Background(), func([]easyslog.Record) bool { return false }))
+	require.Empty(t, recorder.Records())
+
+	handler, collector = NewCollector(inner, nil)
+	slog.New(handler).Info("dropped")
+	collector.Discard()
+	require.Empty(t, recorder.Records())
+
+	slog.New(handler).Info("after")
+	require.Len(t, recorder.Records(), 1)
+}
+
+func TestEnabled(t *testing.T) {
+	recorder, inner := slogtesting.New(&easyslog.Options{Level: slog.LevelWarn})
+	handler, collector := NewCollector(inner, nil)
+
+	slog.New(handler).Info("ignored")
+	slog.New(handler).Warn("kept")
+	require.Equal(t, 1, collector.Len())
+
+	require.NoError(t, collector.Flush(context.Background()))
+	require.Len(t, recorder.Records(), 1)
+}
+
+type counter struct{ n int }
+
+func (c *counter) LogValue() slog.Value {
+	return slog.IntValue(c.n)
+}
+
+func TestResolvesValuesWhenLogged(t *testing.T) {
+	recorder, inner := slogtesting.New(nil)
+	handler, collector := NewCollector(inner, nil)
+
+	c := &counter{n: 1}
+	slog.New(handler).With("with", c).Info("msg", slog.Group("g", "nested", c))
+	c.n = 2
+
+	require.NoError(t, collector.Flush(context.Background()))
+
+	record := recorder.Records()[0]
+	for _, path := range [][]string{{"with"}, {"g", "nested"}} {
+		v, ok := record.Get(path...)
+		require.True(t, ok)
+		require.Equal(t, int64(1), v.Int64())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for name, opts := range map[string]*Options{
+		"records": {MaxRecords: 3},
+		"bytes":   {MaxBytes: 3 * len("msg=x")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder, inner := slogtesting.New(nil)
+			handler, collector := NewCollector(inner, opts)
+			l := slog.New(handler)
+
+			for i := 0; i < 3; i++ {
+				l.Info("msg", "k", "x")
+			}
+			require.Empty(t, recorder.Records())
+
+			// Exceeding the limit flushes everything, then passes through
+			l.Info("msg", "k", "x")
+			require.Len(t, recorder.Records(), 4)
+			require.Zero(t, collector.Len())
+
+			l.Info("msg", "k", "x")
+			require.Len(t, recorder.Records(), 5)
+
+			require.NoError(t, collector.FlushIf(context.Background(), func([]easyslog.Record) bool { return false }))
+			require.Len(t, recorder.Records(), 5)
+		})
+	}
+}
+
+func TestFlushErrors(t *testing.T) {
+	var buf bytes.Buffer
+	failing := easyslog.New(&buf, easyslog.FormatterFunc(func(w io.Writer, r easyslog.Record) error {
+		return errors.New("format " + r.Message)
+	}), nil)
+
+	handler, collector := NewCollector(failing, nil)
+	slog.New(handler).Info("one")
+	slog.New(handler).Info("two")
+
+	require.EqualError(t, collector.Flush(context.Background()), "format one\nformat two")
+}
+
+func TestConcurrent(t *testing.T) {
+	recorder, inner := slogtesting.New(nil)
+	handler, collector := NewCollector(inner, &Options{MaxRecords: 150})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(l *slog.Logger) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.Info("msg", "j", j)
+			}
+		}(slog.New(handler).With("worker", i))
+	}
+	wg.Wait()
+
+	require.NoError(t, collector.Flush(context.Background()))
+
+	counts := map[int64]int{}
+	for _, record := range recorder.Records() {
+		worker, ok := record.Get("worker")
+		require.True(t, ok)
+		counts[worker.Int64()]++
+	}
+	require.Equal(t, map[int64]int{0: 50, 1: 50, 2: 50, 3: 50}, counts)
+}
@@ -0,0 +1,200 @@
+package easyslog
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Equal reports whether a and other have the same key and, for leaves, equal
+// values of the same kind, or, for groups, equal children in the same order.
+// KindAny values are compared with reflect.DeepEqual, so values that can't be
+// compared with == don't panic. Persistent is ignored. A nil Attr only equals
+// another nil Attr.
+func (a *Attr) Equal(other *Attr) bool {
+	if a == nil || other == nil {
+		return a == other
+	}
+
+	if a.Key != other.Key || a.IsGroup() != other.IsGroup() {
+		return false
+	}
+
+	if !a.IsGroup() {
+		return valuesEqual(a.Value, other.Value)
+	}
+
+	return attrsEqual(a.Children, other.Children)
+}
+
+// Equal reports whether r and other have the same time, level, message,
+// groups, sequence number, PID, handler name and attributes, compared with
+// (*Attr).Equal in order. PC is ignored since it differs between call sites.
+func (r Record) Equal(other Record) bool {
+	return r.Time.Equal(other.Time) &&
+		r.Level == other.Level &&
+		r.LevelName == other.LevelName &&
+		r.Message == other.Message &&
+		slices.Equal(r.Groups, other.Groups) &&
+		r.Seq == other.Seq &&
+		r.PID == other.PID &&
+		r.HandlerName == other.HandlerName &&
+		attrsEqual(r.Attrs, other.Attrs)
+}
+
+// EqualSorted is like Equal, but ignores the order of attributes by comparing
+// them sorted by key, as with Options.SortAttrs. Neither record is modified.
+func (r Record) EqualSorted(other Record) bool {
+	r, other = r.Clone(), other.Clone()
+	sortAttrs(r.Attrs)
+	sortAttrs(other.Attrs)
+
+	return r.Equal(other)
+}
+
+func attrsEqual(a []*Attr, b []*Attr) bool {
+	return slices.EqualFunc(a, b, (*Attr).Equal)
+}
+
+func valuesEqual(a slog.Value, b slog.Value) bool {
+	if a.Kind() != b.Kind() {
+		return false
+	}
+
+	if a.Kind() == slog.KindAny {
+		return reflect.DeepEqual(a.Any(), b.Any())
+	}
+
+	return a.Equal(b)
+}
+
+// DiffRecords returns a readable description of how b differs from a, or an
+// empty string if they're Equal. Record fields that differ come first, e.g.
+// `Message: "a" → "b"`, followed by one line per attribute path, sorted:
+// `+ path: value` for attributes only in b, `- path: value` for those only in
+// a, and `~ path: old → new` for changed ones, including leaves that became
+// groups. A repeated key is told apart by its index, e.g. `id[1]`. Records
+// whose attributes only differ in order get a single `Attrs:` line instead.
+func DiffRecords(a Record, b Record) string {
+	var lines []string
+	field := func(name string, x any, y any) {
+		lines = append(lines, fmt.Sprintf("%s: %v → %v", name, x, y))
+	}
+
+	if !a.Time.Equal(b.Time) {
+		field("Time", a.Time, b.Time)
+	}
+	if a.Level != b.Level {
+		field("Level", a.Level, b.Level)
+	}
+	if a.LevelName != b.LevelName {
+		field("LevelName", strconv.Quote(a.LevelName), strconv.Quote(b.LevelName))
+	}
+	if a.Message != b.Message {
+		field("Message", strconv.Quote(a.Message), strconv.Quote(b.Message))
+	}
+	if !slices.Equal(a.Groups, b.Groups) {
+		field("Groups", a.Groups, b.Groups)
+	}
+	if a.Seq != b.Seq {
+		field("Seq", a.Seq, b.Seq)
+	}
+	if a.PID != b.PID {
+		field("PID", a.PID, b.PID)
+	}
+	if a.HandlerName != b.HandlerName {
+		field("HandlerName", strconv.Quote(a.HandlerName), strconv.Quote(b.HandlerName))
+	}
+
+	if attrLines := diffAttrs(nil, a.Attrs, b.Attrs, ""); len(attrLines) > 0 {
+		lines = append(lines, attrLines...)
+	} else if !attrsEqual(a.Attrs, b.Attrs) {
+		lines = append(lines, "Attrs: same attributes in a different order")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// diffAttrs appends the differences between the attributes of a and b, both
+// below the dot-separated prefix, to lines.
+func diffAttrs(lines []string, a []*Attr, b []*Attr, prefix string) []string {
+	before, after := indexAttrs(a), indexAttrs(b)
+
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		x, y := before[key], after[key]
+		switch {
+		case y == nil && x.IsGroup() && len(x.Children) > 0:
+			lines = diffAttrs(lines, x.Children, nil, path)
+		case y == nil:
+			lines = append(lines, "- "+path+": "+describe(x))
+		case x == nil && y.IsGroup() && len(y.Children) > 0:
+			lines = diffAttrs(lines, nil, y.Children, path)
+		case x == nil:
+			lines = append(lines, "+ "+path+": "+describe(y))
+		case x.IsGroup() && y.IsGroup():
+			lines = diffAttrs(lines, x.Children, y.Children, path)
+		case x.IsGroup() != y.IsGroup() || !valuesEqual(x.Value, y.Value):
+			lines = append(lines, "~ "+path+": "+describe(x)+" → "+describe(y))
+		}
+	}
+
+	return lines
+}
+
+// indexAttrs maps attrs by key, suffixing repeated keys with the number of
+// earlier attributes using them, e.g. `id[1]` for the second `id`.
+func indexAttrs(attrs []*Attr) map[string]*Attr {
+	index := make(map[string]*Attr, len(attrs))
+	seen := make(map[string]int, len(attrs))
+
+	for _, attr := range attrs {
+		key := attr.Key
+		if n := seen[attr.Key]; n > 0 {
+			key += "[" + strconv.Itoa(n) + "]"
+		}
+		seen[attr.Key]++
+
+		index[key] = attr
+	}
+
+	return index
+}
+
+// describe renders a for DiffRecords: `{}` for an empty group, `{…}` for
+// other groups, and the value followed by its kind for leaves, e.g.
+// `"42" (String)`.
+func describe(a *Attr) string {
+	if a.IsGroup() {
+		if len(a.Children) == 0 {
+			return "{}"
+		}
+
+		return "{…}"
+	}
+
+	value := a.Value.String()
+	if a.Value.Kind() == slog.KindString {
+		value = strconv.Quote(value)
+	}
+
+	return value + " (" + a.Value.Kind().String() + ")"
+}
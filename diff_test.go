@@ -0,0 +1,78 @@
+package easyslog
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func record(attrs ...slog.Attr) Record {
+	r := Record{Message: "msg", Level: slog.LevelInfo}
+	for _, attr := range attrs {
+		r.Add(attr)
+	}
+
+	return r
+}
+
+func TestRecordEqual(t *testing.T) {
+	a := record(slog.Int("id", 1), slog.Group("user", slog.String("name", "fox"), slog.Any("tags", []string{"x"})))
+	b := record(slog.Int("id", 1), slog.Group("user", slog.String("name", "fox"), slog.Any("tags", []string{"x"})))
+	b.PC = 42
+
+	require.True(t, a.Equal(b))
+	require.True(t, a.Attrs[1].Equal(b.Attrs[1]))
+	require.Empty(t, DiffRecords(a, b))
+
+	require.False(t, a.Equal(record(slog.String("id", "1"), slog.Group("user", slog.String("name", "fox"), slog.Any("tags", []string{"x"})))))
+	require.False(t, a.Attrs[0].Equal(nil))
+	require.True(t, (*Attr)(nil).Equal(nil))
+
+	b.Time = time.Unix(1, 0)
+	require.False(t, a.Equal(b))
+}
+
+func TestRecordEqualSorted(t *testing.T) {
+	a := record(slog.Int("a", 1), slog.Group("g", slog.Int("x", 1), slog.Int("y", 2)))
+	b := record(slog.Group("g", slog.Int("y", 2), slog.Int("x", 1)), slog.Int("a", 1))
+
+	require.False(t, a.Equal(b))
+	require.True(t, a.EqualSorted(b))
+	// Neither record is sorted in place
+	require.Equal(t, "g", b.Attrs[0].Key)
+
+	require.Equal(t, "Attrs: same attributes in a different order", DiffRecords(a, b))
+}
+
+func TestDiffRecords(t *testing.T) {
+	a := record(
+		slog.Int("id", 1),
+		slog.Int("id", 2),
+		slog.String("removed", "x"),
+		slog.Group("user", slog.String("name", "fox"), slog.Int("age", 40)),
+		slog.Int("became_group", 1),
+		slog.Group("old", slog.Bool("a", true)),
+	)
+	b := record(
+		slog.String("id", "1"),
+		slog.Int("id", 3),
+		slog.Group("user", slog.String("name", "dana"), slog.Int("age", 40), slog.Group("address", slog.String("city", "DC"))),
+		slog.Group("became_group", slog.Int("x", 1)),
+		slog.Float64("added", 1.5),
+	)
+	b.Message = "other"
+	b.Level = slog.LevelWarn
+
+	require.Equal(t, `Level: INFO → WARN
+Message: "msg" → "other"
++ added: 1.5 (Float64)
+~ became_group: 1 (Int64) → {…}
+~ id: 1 (Int64) → "1" (String)
+~ id[1]: 2 (Int64) → 3 (Int64)
+- old.a: true (Bool)
+- removed: "x" (String)
++ user.address.city: "DC" (String)
+~ user.name: "fox" (String) → "dana" (String)`, DiffRecords(a, b))
+}
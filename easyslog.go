@@ -3,10 +3,17 @@ package easyslog
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"slices"
 )
@@ -14,14 +21,38 @@ import (
 type (
 	// EasySlog is a slog handler that reduces the boilerplate of implementing the
 	// slog.Handler boilerplate.
+	//
+	// EasySlog is safe for concurrent use, including handlers derived from it via
+	// WithAttrs and WithGroup. Derived handlers never modify their parent's
+	// state: each one owns its own copy of the attribute tree and Handle works
+	// on a per-call clone, so the Record passed to the formatter is never shared
+	// between goroutines. With Options.FlattenGroups set, attributes added via
+	// WithAttrs are shared between calls instead and must not be modified by
+	// formatters. All handlers derived from the same call to New share a
+	// single mutex around the writer, so each log line is written atomically
+	// with respect to the others. Formatters may be called concurrently and must
+	// be safe for concurrent use.
 	EasySlog struct {
 		formatter    Formatter
 		leveler      slog.Leveler
-		mu           *sync.Mutex
+		opts         Options
+		out          *output
 		attrs        []Attr
-		writer       io.Writer
 		groupIndices []int
 		root         *Attr
+		groups       []string
+		// prefix and flatAttrs replace groupIndices and root when
+		// Options.FlattenGroups is set.
+		prefix    string
+		flatAttrs []*Attr
+		// prelude holds the parsed Options.Prelude, shared by every derived
+		// handler and record.
+		prelude []*Attr
+		// name is Options.Name followed by the names added via WithName.
+		name string
+		// stats is shared by every handler derived from the same call to
+		// New, including via WithWriter.
+		stats *handlerStats
 	}
 
 	// Record is passed to the formatter associated with an EasySlog handler. It
@@ -35,6 +66,10 @@ type (
 		Time time.Time
 		// The level of the current log line.
 		Level slog.Level
+		// LevelName is the name of Level from Options.LevelNames, falling back
+		// to slog.Level.String(), or empty if Options.LevelNames isn't set.
+		// Formatters should prefer it to names of their own.
+		LevelName string
 		// The program counter provided by slog.Record. See slog.Record for more
 		// details.
 		PC uintptr
@@ -42,31 +77,389 @@ type (
 		Message string
 		// The attributes being logged.
 		Attrs []*Attr
+		// Groups holds the names of the groups opened via WithGroup, outermost
+		// first, as transformed by Options.KeyTransformer. Groups introduced by
+		// slog.Group attributes are not included.
+		Groups []string
+		// Seq is the sequence number of the record when Options.AddSequence is
+		// set, starting at 1, and zero otherwise.
+		Seq uint64
+		// PID is the process id when Options.IncludePID is set, and zero
+		// otherwise, for formatters with a dedicated field for it.
+		PID int
+		// HandlerName is the name of the handler that logged the record, from
+		// Options.Name and WithName, e.g. `server.http`, or empty if it has
+		// none.
+		HandlerName string
 	}
 
 	// Formatter is provided the io.Writer of the handler and the Record for the
 	// current log line. Each call to `Format` is provided its own buffer which
-	// can be written to immediately. If an error is returned the value is not
-	// written to the handlers io.Writer.
+	// can be written to immediately. If an error is returned nothing written to
+	// the buffer reaches the handlers io.Writer, and Handle returns the error.
+	// Handle ends the output with a newline unless it already ends with one,
+	// so a formatter can write a record as several lines, e.g. via Fprintlns.
 	Formatter interface {
 		Format(w io.Writer, r Record) error
 	}
 
+	// RawFormatter is an optional interface for Formatters that need the
+	// slog.Record the Record was built from, e.g. for NumAttrs or the
+	// attributes in their original order before groups were merged. When the
+	// formatter implements it, FormatRaw is called instead of Format with a
+	// clone of the slog.Record, which is safe to retain. Attributes added via
+	// WithAttrs and BaseAttrs are only in the Record.
+	RawFormatter interface {
+		Formatter
+		FormatRaw(w io.Writer, r Record, raw slog.Record) error
+	}
+
+	// HeaderFormatter is an optional interface for Formatters whose output
+	// starts with a header, like a CSV header row. Header is called before
+	// the first line is written to each writer, including after SetOutput,
+	// and what it writes is written on its own under the same lock as lines,
+	// so it precedes all of them even when records are formatted
+	// concurrently. If Header or writing the header fails, the line isn't
+	// written and the header is tried again with the next one. Only the
+	// handler's own formatter is asked, not those from FormatterFor.
+	HeaderFormatter interface {
+		Formatter
+		Header(w io.Writer) error
+	}
+
+	// RecordWriter is implemented by writers that encode records themselves,
+	// e.g. into a binary protocol. When the handler's writer implements it,
+	// Handle passes each Record to WriteRecord instead of formatting it. The
+	// Record's attributes are only valid during the call.
+	RecordWriter interface {
+		WriteRecord(r Record) error
+	}
+
+	// FormatterFunc is an adapter to allow the use of ordinary functions as
+	// Formatters.
+	FormatterFunc func(w io.Writer, r Record) error
+
 	// Options to configure EasySlog
 	Options struct {
 		Level slog.Leveler
+		// MaxLevel, when set, is the highest level handled, inclusive, so a
+		// handler can take a range of levels, e.g. Info through Warn for an
+		// application log while Error goes to an alert log. It's consulted on
+		// every call, so a *slog.LevelVar can change it at runtime, and it
+		// applies to records forced by ForceLevelAttr too.
+		MaxLevel slog.Leveler
+		// MinLevelFromContext, when set, is consulted on every call with the
+		// context being logged with. If it returns true, the returned level
+		// replaces Level for that call only, e.g. to log at Debug for a request
+		// carrying a debug flag. ContextMinLevel reads the level set by
+		// WithMinLevel.
+		MinLevelFromContext func(ctx context.Context) (slog.Level, bool)
+		// Name identifies the subsystem logging through the handler, e.g.
+		// `server`, so lines can be attributed when several share a writer.
+		// It's passed to formatters as Record.HandlerName and extended by
+		// WithName.
+		Name string
+		// DisablePanicRecovery lets panics in the formatter or a
+		// RecordWriter propagate to the caller. By default they're recovered
+		// and returned by Handle as a *FormatterPanicError, so logging can't
+		// crash the program.
+		DisablePanicRecovery bool
+		// NoPanicFallback drops records whose formatter or RecordWriter
+		// panicked. By default a plain-text line containing the panic value
+		// and the original message is written in their place, so the log line
+		// isn't silently lost.
+		NoPanicFallback bool
+		// BaseAttrs are added to the root of every log line, before any
+		// attributes added via WithAttrs or nested via WithGroup.
+		BaseAttrs []slog.Attr
+		// Prelude holds process-level attributes like the host, pid or
+		// service, added to the start of every log line before BaseAttrs.
+		// They're parsed once by New and shared by every derived handler and
+		// record instead of being copied for each, so they cost next to
+		// nothing per line. Their keys aren't deduplicated against other
+		// attributes, and they don't count against MaxAttrs.
+		Prelude []slog.Attr
+		// SyncOnWrite calls Sync or Flush on the writer, if it implements
+		// either, after each log line is written. Errors are returned from
+		// Handle like write errors.
+		SyncOnWrite bool
+		// WriteFailureThreshold, when positive, suppresses writes after that
+		// many consecutive write errors, e.g. on a full disk or a broken
+		// pipe. While suppressed, Handle drops records that pass the level
+		// and Sampler filters before formatting them, returns
+		// ErrWriteSuppressed and reports them as DropSuppressed, letting one
+		// record through per WriteRetryInterval to probe the writer. Once a
+		// line is written again, it's followed by a plain-text line with the
+		// number of records dropped while suppressed. Short writes are
+		// retried once before counting as errors. Records passed to a
+		// RecordWriter are not affected.
+		WriteFailureThreshold int
+		// WriteRetryInterval is how long writes are suppressed before each
+		// probe. Defaults to DefaultWriteRetryInterval.
+		WriteRetryInterval time.Duration
+		// MaxValueBytes, when positive, truncates string and KindAny leaf
+		// values whose rendered length exceeds it, appending a
+		// `…(truncated N bytes)` suffix. Groups are not affected.
+		MaxValueBytes int
+		// ReplaceMessage, when set, is applied to each record's message before
+		// it's passed to the formatter, e.g. to strip embedded newlines.
+		ReplaceMessage func(msg string) string
+		// MessageTemplate, when set, returns the message to format given the
+		// message and the Record it's on, after TransformRecord. It's intended
+		// for interpolating attribute values into messages, see Interpolate.
+		MessageTemplate func(msg string, r Record) string
+		// KeepEmptyGroups keeps groups opened via WithGroup in the tree even
+		// when no attributes are logged in them, so formatters can render them
+		// as e.g. `{}`. Empty groups from slog.Group attributes are still
+		// dropped. It has no effect with FlattenGroups.
+		KeepEmptyGroups bool
+		// MaxAttrs, when positive, limits the number of leaf attributes on a
+		// single line, counted across nested groups and including attributes
+		// added via WithAttrs. Record attributes beyond the limit are dropped
+		// and a final `_truncated` attribute holds how many were dropped.
+		MaxAttrs int
+		// Observer, when set, is notified of every line written or dropped so
+		// log volume can be exported as metrics without parsing output.
+		Observer Observer
+		// FlattenGroups, when non-empty, is the separator used to join group
+		// names and keys (e.g. `request.method`). Record.Attrs is then a flat
+		// list of leaf attributes with no Children and no tree is built.
+		FlattenGroups string
+		// Tap, when set, is called with each Record before it's formatted. It's
+		// intended for tests that want to assert on structured data rather than
+		// parse formatted output. Clone Records it retains if ReuseAttrs is
+		// set.
+		Tap func(Record)
+		// FormatterFor, when set, picks the formatter for each record by level,
+		// e.g. JSON for warnings and errors and pretty output for the rest. The
+		// formatter passed to New is used when it's nil or returns nil. The
+		// handler ends the output of either with a newline, so framing is
+		// identical.
+		FormatterFor func(level slog.Level) Formatter
+		// AddSequence sets Record.Seq to a counter incremented by each call to
+		// Handle, so lines with identical timestamps can still be ordered. The
+		// counter is shared by every handler derived from the same call to New.
+		AddSequence bool
+		// LevelNames, when set, names levels for every formatter through
+		// Record.LevelName, e.g. to render LevelTrace as `TRACE` instead of
+		// `DEBUG-4`. Levels missing from it fall back to slog.Level.String().
+		LevelNames LevelNamer
+		// StackTraceLevel, when set, adds a `stack` group with the caller's
+		// stack to records at or above the level, one `frame.N` attribute per
+		// frame formatted as `pkg.Func file.go:12`. Records below the level
+		// don't capture anything.
+		StackTraceLevel *slog.Level
+		// StackTraceDepth limits the number of frames captured for
+		// StackTraceLevel. Defaults to DefaultStackTraceDepth.
+		StackTraceDepth int
+		// StackTraceString renders the stack as a single multi-line string
+		// attribute instead of a group.
+		StackTraceString bool
+		// TransformRecord, when set, is called with each Record after its
+		// attribute tree is built and before Tap and the formatter see it, for
+		// rewrites that depend on other attributes. The Record is owned by the
+		// call, so its attributes can be rewritten in place or changed with
+		// Record.Add and Record.Delete without affecting later calls.
+		TransformRecord func(r *Record)
+		// KeyTransformer, when set, is applied to every attribute key while
+		// it's parsed, including group names and names passed to WithGroup,
+		// so keys are normalized once for all formatters, e.g. to snake_case.
+		// path holds the already transformed names of the enclosing groups
+		// and must not be retained. Returning an empty string drops the
+		// attribute, or for WithGroup leaves attributes un-nested. Keys the
+		// handler adds itself, like TruncatedKey, aren't transformed.
+		KeyTransformer func(path []string, key string) string
+		// NoLock lets lines be written concurrently instead of one at a time,
+		// for writers that keep concurrent writes from interleaving
+		// themselves, like an *os.File opened with O_APPEND or a datagram
+		// socket. Each line is still a single Write call, and a RecordWriter's
+		// WriteRecord is called concurrently too. Writers can opt in on their
+		// own by implementing ConcurrentWriter.
+		NoLock bool
+		// Ring, when positive, keeps the last Ring Records in memory so they
+		// can be served with Recent, e.g. from a health endpoint.
+		Ring int
+		// ForceLevelAttr, when set, is the key of a top-level record attribute
+		// that emits the record regardless of its level when it's true, e.g.
+		// `force_log` for security events. True means a bool true or a string
+		// strconv.ParseBool accepts as true. Enabled then reports true for
+		// every level so the attribute can be checked, and the level is
+		// enforced by Handle instead, so disabled calls are no longer free.
+		// Only the attribute itself is resolved to check it, so the other
+		// LogValuers of records that aren't forced are never resolved.
+		ForceLevelAttr string
+		// ReuseAttrs allocates each record's attribute tree from pooled memory
+		// that's reused once the record is written, instead of a heap
+		// allocation per attribute. Record.Attrs must then not be retained
+		// past Format, Tap or WriteRecord; use Record.Clone to keep a copy.
+		ReuseAttrs bool
+		// MaxDepth limits how deeply groups may be nested, counting groups
+		// opened via WithGroup, so deeply nested or self-referencing values
+		// can't exhaust the stack while the tree is built or formatted. A
+		// group that would be nested deeper is replaced by a leaf with its key
+		// holding DepthExceededValue. Defaults to DefaultMaxDepth.
+		MaxDepth int
+		// KeySanitizer, when set, is applied to every attribute key after
+		// KeyTransformer, including group names and names passed to WithGroup,
+		// so keys meet the restrictions of downstream systems without each
+		// formatter handling them, e.g. SnakeCaseKeys or IdentifierKeys.
+		// Returning an empty string drops the attribute. A key that collides
+		// with an earlier one in the same group, e.g. `a-b` and `a_b` both
+		// sanitized to `a_b`, gets the first free `_2`, `_3`, ... suffix.
+		// Keys the handler adds itself aren't sanitized.
+		KeySanitizer func(key string) string
+		// ValueStringer, when set, is called with the value of every KindAny
+		// leaf attribute, and returning true replaces it with the returned
+		// string, so every formatter renders types like *url.URL the same
+		// way. It's applied before MaxValueBytes. DefaultValueStringer
+		// handles common types, and ChainStringers and StringerFor combine
+		// it with custom ones.
+		ValueStringer func(v any) (string, bool)
+		// OmitTime passes records to the formatter with a zero Time, so every
+		// formatter skips the time field, e.g. when the log collector stamps
+		// lines itself.
+		OmitTime bool
+		// TimeZone, when set, converts each record's Time into the location
+		// before it's passed to the formatter, e.g. time.UTC, so timestamps
+		// are rendered alike regardless of the machine's zone. Time attribute
+		// values are left as-is.
+		TimeZone *time.Location
+		// Sampler, when set, is called with every record that passes the
+		// level checks, and returning false drops it. It runs before the
+		// record's attributes are built, so the LogValuers of dropped records
+		// are never resolved. Attributes added via WithAttrs are resolved once
+		// when they're added instead.
+		Sampler func(ctx context.Context, r slog.Record) bool
+		// ValidateLine, when set, is called with each formatted line before
+		// its newline is added, e.g. NoInteriorNewlines or ValidJSON. A line
+		// it returns an error for isn't written, and Handle returns the error.
+		// It isn't called for records passed to a RecordWriter, and must not
+		// retain line, whose buffer is reused.
+		ValidateLine func(line []byte) error
+		// SanitizeInvalidLines writes lines rejected by ValidateLine with
+		// their newlines and carriage returns escaped as `\n` and `\r`,
+		// instead of dropping them. Handle still returns the error.
+		SanitizeInvalidLines bool
+		// IncludePID adds a PIDKey attribute with the process id to the top
+		// level of every record, and sets Record.PID.
+		IncludePID bool
+		// IncludeGoroutineID adds a GoroutineKey attribute with the id of the
+		// logging goroutine to the top level of every record, to diagnose
+		// interleaved lines. It captures the start of the goroutine's stack
+		// for every record, so it's best kept to debug builds.
+		IncludeGoroutineID bool
+		// SortAttrs sorts the attributes of the record, and of each group in
+		// it, by key before Tap and the formatter see them, so output doesn't
+		// depend on the order attributes were added in, e.g. for golden files.
+		// Groups and leaves sort together, and the sort is stable, so
+		// attributes with the same key keep their order.
+		SortAttrs bool
+		// EmptyKeys determines what happens to leaf attributes with an empty
+		// key. Groups with an empty key are always inlined into their parent,
+		// and zero attributes, with an empty key and value, are always
+		// ignored, as slog.Handler requires. Defaults to KeepEmptyKeys.
+		EmptyKeys EmptyKeyMode
 	}
+
+	// ConcurrentWriter is implemented by writers that can be written to from
+	// several goroutines at once without interleaving each Write, as if
+	// Options.NoLock was set.
+	ConcurrentWriter interface {
+		ConcurrentSafe() bool
+	}
+
+	// output holds the writer shared by a handler and every handler derived
+	// from it, so swapping it with SetOutput affects all of them. Lines hold
+	// the read lock instead of the lock when they can be written
+	// concurrently, so SetOutput and Close still wait for them.
+	output struct {
+		mu     sync.RWMutex
+		writer io.Writer
+		closed bool
+		seq    atomic.Uint64
+		// concurrent caches whether writer is a ConcurrentWriter. It's only
+		// changed while mu is held.
+		concurrent atomic.Bool
+		// records caches whether writer is a RecordWriter, so lines for
+		// other writers don't take the lock to find out. It's only changed
+		// while mu is held.
+		records atomic.Bool
+		// headerDone is set once the formatter's header, if it has one, was
+		// written to writer. headerMu serializes writing it under the read
+		// lock.
+		headerDone atomic.Bool
+		headerMu   sync.Mutex
+		// recent is nil unless Options.Ring is set.
+		recent *recentRecords
+		// breaker is only used when Options.WriteFailureThreshold is set.
+		breaker writeBreaker
+	}
+
+	// FormatterPanicError is returned by Handle when the formatter, or the
+	// WriteRecord method of a RecordWriter, panics. It holds the recovered
+	// value and the stack of the panicking goroutine.
+	FormatterPanicError struct {
+		// The value passed to panic.
+		Value any
+		// The stack trace captured when the panic was recovered.
+		Stack []byte
+	}
+)
+
+// EmptyKeyMode determines how leaf attributes with an empty key are handled.
+type EmptyKeyMode int
+
+const (
+	// KeepEmptyKeys keeps leaves with an empty key, like slog.JSONHandler
+	// and slog.TextHandler, so formatters render them e.g. as `=value`.
+	// KeyTransformer can still rename or drop them.
+	KeepEmptyKeys EmptyKeyMode = iota
+	// DropEmptyKeys drops leaves with an empty key.
+	DropEmptyKeys
+	// NameEmptyKeys renames leaves with an empty key to EmptyKeyName.
+	NameEmptyKeys
 )
 
+// EmptyKeyName is the key given to leaves with an empty key by NameEmptyKeys.
+// Like other keys the handler adds itself, it isn't transformed.
+const EmptyKeyName = "_"
+
+// ErrClosed is returned by Handle after Close has been called on the handler
+// or any handler sharing its writer.
+var ErrClosed = errors.New("easyslog: handler closed")
+
 var _ slog.Handler = (*EasySlog)(nil)
+var _ Formatter = FormatterFunc(nil)
+
+// Format calls f(w, r).
+func (f FormatterFunc) Format(w io.Writer, r Record) error {
+	return f(w, r)
+}
+
+// Fprintlns writes each of lines to w followed by a newline, for formatters
+// that write a record as several lines. Since the output then ends with a
+// newline, Handle doesn't add another.
+func Fprintlns(w io.Writer, lines ...string) error {
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
 // New returns a new EasySlog that delegates the formatting of log lines to the
 // provided Formatter.
 func New(w io.Writer, formatter Formatter, opts *Options) *EasySlog {
 	if opts == nil {
-		opts = &Options{
-			Level: slog.LevelInfo,
-		}
+		opts = &Options{}
+	}
+
+	// Copy the options so defaults don't leak back into the caller's struct
+	options := *opts
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
 	}
 
 	root := &Attr{
@@ -75,19 +468,235 @@ func New(w io.Writer, formatter Formatter, opts *Options) *EasySlog {
 		Children: make([]*Attr, 0),
 	}
 
-	return &EasySlog{
+	handler := &EasySlog{
 		root:         root,
-		writer:       w,
 		formatter:    formatter,
-		leveler:      opts.Level,
+		leveler:      options.Level,
+		opts:         options,
 		groupIndices: []int{},
-		mu:           &sync.Mutex{},
+		out:          newOutput(w),
+		stats:        &handlerStats{},
+		name:         options.Name,
+	}
+
+	if options.Ring > 0 {
+		handler.out.recent = newRecentRecords(options.Ring)
+	}
+
+	prelude := &Attr{group: true}
+	for _, attr := range options.Prelude {
+		if options.FlattenGroups != "" {
+			prelude.Children = handler.parseFlatValue(attr, "", nil, 0, prelude.Children, nil, nil)
+			continue
+		}
+
+		handler.parseValue(attr, prelude, nil, 0, nil, nil)
+	}
+	handler.prelude = slices.Clip(prelude.Children)
+	markPersistent(handler.prelude)
+
+	for _, attr := range options.BaseAttrs {
+		if options.FlattenGroups != "" {
+			handler.flatAttrs = handler.parseFlatValue(attr, "", nil, 0, handler.flatAttrs, nil, nil)
+			continue
+		}
+
+		handler.parseValue(attr, root, nil, 0, nil, nil)
+	}
+	markPersistent(root.Children)
+	markPersistent(handler.flatAttrs)
+
+	return handler
+}
+
+// SetOutput replaces the io.Writer that log lines are written to. The writer is
+// shared by every handler derived from the same call to New, so the change
+// applies to all of them. Lines already being written finish on the previous
+// writer.
+func (handler *EasySlog) SetOutput(w io.Writer) {
+	handler.out.mu.Lock()
+	defer handler.out.mu.Unlock()
+
+	handler.out.writer = w
+	handler.out.concurrent.Store(isConcurrentSafe(w))
+	handler.out.records.Store(isRecordWriter(w))
+	handler.out.headerDone.Store(false)
+	handler.out.breaker.reset()
+}
+
+// WithWriter returns a copy of handler that writes to w, e.g. a buffer per
+// parallel test. The copy keeps the formatter, options, and the attributes
+// and groups added via WithAttrs and WithGroup, but has its own lock,
+// sequence, Ring and closed state, so it neither races with nor is closed by
+// the handlers writing to the original writer. Handlers derived from the copy
+// write to w as well.
+func (handler *EasySlog) WithWriter(w io.Writer) *EasySlog {
+	clone := *handler
+	clone.out = newOutput(w)
+	if handler.opts.Ring > 0 {
+		clone.out.recent = newRecentRecords(handler.opts.Ring)
+	}
+
+	return &clone
+}
+
+// WithName returns a copy of handler whose records have name appended to its
+// own, separated by a dot, as Record.HandlerName, e.g. `server` becomes
+// `server.http`. Handlers derived from the copy inherit the name. An empty name
+// returns handler.
+func (handler *EasySlog) WithName(name string) *EasySlog {
+	if name == "" {
+		return handler
+	}
+
+	clone := *handler
+	if handler.name != "" {
+		name = handler.name + "." + name
+	}
+	clone.name = name
+
+	return &clone
+}
+
+// WithFormatter returns a copy of handler that formats records with f. It
+// shares everything else with handler, including the writer and its lock.
+func (handler *EasySlog) WithFormatter(f Formatter) *EasySlog {
+	clone := *handler
+	clone.formatter = f
+
+	return &clone
+}
+
+func newOutput(w io.Writer) *output {
+	out := &output{writer: w}
+	out.concurrent.Store(isConcurrentSafe(w))
+	out.records.Store(isRecordWriter(w))
+
+	return out
+}
+
+func isRecordWriter(w io.Writer) bool {
+	_, ok := w.(RecordWriter)
+	return ok
+}
+
+func isConcurrentSafe(w io.Writer) bool {
+	cw, ok := w.(ConcurrentWriter)
+	return ok && cw.ConcurrentSafe()
+}
+
+// lock locks the writer for a single line, reporting whether it only took the
+// read lock because lines can be written concurrently. Pass the result to
+// unlock, e.g. `defer out.unlock(out.lock(noLock))`, which unlike returning
+// the unlock method doesn't allocate.
+func (out *output) lock(noLock bool) bool {
+	if noLock || out.concurrent.Load() {
+		out.mu.RLock()
+		// Recheck now that SetOutput can't swap the writer
+		if noLock || out.concurrent.Load() {
+			return true
+		}
+		out.mu.RUnlock()
+	}
+
+	out.mu.Lock()
+	return false
+}
+
+// unlock releases the lock taken by lock.
+func (out *output) unlock(shared bool) {
+	if shared {
+		out.mu.RUnlock()
+	} else {
+		out.mu.Unlock()
+	}
+}
+
+// Sync flushes the writer if it implements `Sync() error` or `Flush() error`,
+// e.g. an *os.File or a *bufio.Writer. It's a no-op after Close. Like the
+// writer itself, it's shared by every handler derived from the same call to
+// New.
+func (handler *EasySlog) Sync() error {
+	handler.out.mu.Lock()
+	defer handler.out.mu.Unlock()
+
+	if handler.out.closed {
+		return nil
 	}
+
+	return syncWriter(handler.out.writer)
+}
+
+// Close flushes the writer like Sync and then closes it if it's an io.Closer.
+// Handle returns ErrClosed afterwards, for this handler and every handler
+// sharing its writer. Calling Close again is a no-op.
+func (handler *EasySlog) Close() error {
+	handler.out.mu.Lock()
+	defer handler.out.mu.Unlock()
+
+	if handler.out.closed {
+		return nil
+	}
+	handler.out.closed = true
+
+	err := syncWriter(handler.out.writer)
+	if closer, ok := handler.out.writer.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+
+	return err
 }
 
-// Enabled returns if EasySlog handles logs at the given level.
+// Enabled returns if EasySlog handles logs at the given level. It's always
+// true up to Options.MaxLevel when Options.ForceLevelAttr is set.
 func (handler *EasySlog) Enabled(ctx context.Context, level slog.Level) bool {
-	return level >= handler.leveler.Level()
+	if handler.aboveMax(level) {
+		return false
+	}
+
+	if handler.opts.ForceLevelAttr != "" {
+		return true
+	}
+
+	return level >= handler.minLevel(ctx)
+}
+
+// aboveMax reports whether level is above Options.MaxLevel.
+func (handler *EasySlog) aboveMax(level slog.Level) bool {
+	return handler.opts.MaxLevel != nil && level > handler.opts.MaxLevel.Level()
+}
+
+// forced reports whether r has a true Options.ForceLevelAttr attribute.
+func (handler *EasySlog) forced(r slog.Record) bool {
+	forced := false
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != handler.opts.ForceLevelAttr {
+			return true
+		}
+
+		switch v := a.Value.Resolve(); v.Kind() {
+		case slog.KindBool:
+			forced = v.Bool()
+		case slog.KindString:
+			forced, _ = strconv.ParseBool(v.String())
+		}
+
+		return false
+	})
+
+	return forced
+}
+
+// minLevel returns the minimum level for ctx, preferring the level returned by
+// MinLevelFromContext over the handler's leveler.
+func (handler *EasySlog) minLevel(ctx context.Context) slog.Level {
+	if handler.opts.MinLevelFromContext != nil && ctx != nil {
+		if level, ok := handler.opts.MinLevelFromContext(ctx); ok {
+			return level
+		}
+	}
+
+	return handler.leveler.Level()
 }
 
 func (handler *EasySlog) getCurrentGroup(root *Attr) *Attr {
@@ -99,106 +708,844 @@ func (handler *EasySlog) getCurrentGroup(root *Attr) *Attr {
 	return group
 }
 
+// clonePath copies the root and the groups leading to the current group, and
+// returns the copies of the root and current group. Other nodes are shared with
+// the handler's tree: they're never modified once a handler is created, and
+// recordAttrs deep-clones the tree before anything can change it. This keeps
+// chained With calls from each copying the whole tree.
+func (handler *EasySlog) clonePath() (*Attr, *Attr) {
+	root := handler.root.shallowClone()
+	group := root
+	for _, i := range handler.groupIndices {
+		child := group.Children[i].shallowClone()
+		group.Children[i] = child
+		group = child
+	}
+
+	return root, group
+}
+
 // WithAttrs returns a new EasySlog whose attributes are always logged.
 func (handler *EasySlog) WithAttrs(slogAttrs []slog.Attr) slog.Handler {
-	root := handler.root.clone()
+	if handler.opts.FlattenGroups != "" {
+		flatAttrs := slices.Clip(handler.flatAttrs)
+		for _, attr := range slogAttrs {
+			flatAttrs = handler.parseFlatValue(attr, handler.prefix, handler.groups, len(handler.groups), flatAttrs, nil, nil)
+		}
+		markPersistent(flatAttrs[len(handler.flatAttrs):])
 
+		return &EasySlog{
+			formatter: handler.formatter,
+			leveler:   handler.leveler,
+			opts:      handler.opts,
+			out:       handler.out,
+			groups:    handler.groups,
+			prefix:    handler.prefix,
+			flatAttrs: flatAttrs,
+			prelude:   handler.prelude,
+			stats:     handler.stats,
+			name:      handler.name,
+		}
+	}
+
+	var root, currentGroup *Attr
+	if len(handler.groupIndices) == 0 {
+		// Without groups only the top level changes, so copy it with room
+		// for the new attributes instead of growing it while parsing them
+		root = &Attr{}
+		*root = *handler.root
+		root.Children = make([]*Attr, len(handler.root.Children), len(handler.root.Children)+len(slogAttrs))
+		copy(root.Children, handler.root.Children)
+		currentGroup = root
+	} else {
+		root, currentGroup = handler.clonePath()
+	}
+
+	existing := len(currentGroup.Children)
 	for _, attr := range slogAttrs {
-		if attr.Value.Any() == nil {
+		if isNil(attr.Value) {
 			continue
 		}
-		parseValue(attr, handler.getCurrentGroup(root))
+		handler.parseValue(attr, currentGroup, handler.groups, len(handler.groups), nil, nil)
 	}
+	markPersistent(currentGroup.Children[existing:])
 
 	return &EasySlog{
-		writer:       handler.writer,
 		formatter:    handler.formatter,
 		leveler:      handler.leveler,
-		mu:           handler.mu,
+		opts:         handler.opts,
+		out:          handler.out,
 		groupIndices: handler.groupIndices,
 		root:         root,
+		groups:       handler.groups,
+		prelude:      handler.prelude,
+		stats:        handler.stats,
+		name:         handler.name,
 	}
 }
 
 // WithGroup returns a new EasySlog that nests all attributes in the provided
 // group.
 func (handler *EasySlog) WithGroup(name string) slog.Handler {
+	if name != "" {
+		name = handler.transformKey(handler.groups, name)
+	}
+
 	if name == "" {
 		return handler
 	}
 
+	if handler.opts.FlattenGroups != "" {
+		return &EasySlog{
+			formatter: handler.formatter,
+			leveler:   handler.leveler,
+			opts:      handler.opts,
+			out:       handler.out,
+			groups:    append(slices.Clip(handler.groups), name),
+			prefix:    joinKey(handler.prefix, name, handler.opts.FlattenGroups),
+			flatAttrs: handler.flatAttrs,
+			prelude:   handler.prelude,
+			stats:     handler.stats,
+			name:      handler.name,
+		}
+	}
+
 	group := &Attr{
-		Key:      name,
-		Value:    slog.AnyValue(nil),
-		Children: make([]*Attr, 0),
+		Key:        name,
+		Value:      slog.AnyValue(nil),
+		Children:   make([]*Attr, 0),
+		Persistent: true,
+		group:      true,
+		withGroup:  true,
 	}
 
-	root := handler.root.clone()
-	currentGroup := handler.getCurrentGroup(root)
+	root, currentGroup := handler.clonePath()
 	currentGroup.Children = append(currentGroup.Children, group)
 
 	return &EasySlog{
-		writer:       handler.writer,
-		formatter:    handler.formatter,
-		leveler:      handler.leveler,
-		mu:           handler.mu,
-		attrs:        handler.attrs,
-		groupIndices: append(handler.groupIndices, len(currentGroup.Children)-1),
+		formatter: handler.formatter,
+		leveler:   handler.leveler,
+		opts:      handler.opts,
+		out:       handler.out,
+		attrs:     handler.attrs,
+		// Clip so sibling handlers never share, and race on, a backing array
+		groupIndices: append(slices.Clip(handler.groupIndices), len(currentGroup.Children)-1),
 		root:         root,
+		groups:       append(slices.Clip(handler.groups), name),
+		prelude:      handler.prelude,
+		stats:        handler.stats,
+		name:         handler.name,
 	}
 }
 
 // Handle converts the slog.Record data into an EasySlog.Record, provides it to
-// the formatter, and writes the output to the handlers io.Writer.
-func (handler *EasySlog) Handle(_ context.Context, r slog.Record) error {
-	root := handler.root.clone()
+// the formatter, and writes the output to the handlers io.Writer. Each line,
+// including its newline, is passed to a single Write call, so writers that
+// frame every Write as a message, like a UDP socket, get one per record. Only
+// the rest of a short write is retried with a second Write.
+func (handler *EasySlog) Handle(ctx context.Context, r slog.Record) error {
+	arena := handler.newArena()
+	defer arena.release()
+
+	record, ok := handler.buildRecord(ctx, r, arena)
+	if !ok {
+		return nil
+	}
+
+	if handler.out.recent != nil {
+		if arena != nil {
+			handler.out.recent.add(record.Clone())
+		} else {
+			handler.out.recent.add(record)
+		}
+	}
+
+	var start time.Time
+	if handler.opts.Observer != nil {
+		start = time.Now()
+	}
+
+	if written, err := handler.writeRecord(record, start); written {
+		return err
+	}
+
+	// Only records that would be written take a probe or count as dropped
+	if handler.opts.WriteFailureThreshold > 0 && !handler.out.breaker.allow(handler.writeRetryInterval()) {
+		handler.observeDrop(r.Level, DropSuppressed)
+		return ErrWriteSuppressed
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer putBuffer(buf)
+
+	err := handler.format(buf, record, r)
+
+	if err != nil {
+		handler.observeDrop(r.Level, DropFormatError)
+
+		var panicErr *FormatterPanicError
+		if !handler.opts.NoPanicFallback && errors.As(err, &panicErr) {
+			buf.Reset()
+			fmt.Fprintf(buf, "easyslog: formatter panic: %v msg=%q\n", panicErr.Value, r.Message)
+
+			defer handler.out.unlock(handler.out.lock(handler.opts.NoLock))
+
+			if !handler.out.closed {
+				_, _ = handler.out.writer.Write(buf.Bytes())
+			}
+		}
+
+		return err
+	}
+
+	line := buf.Bytes()
+	var invalidErr error
+	if handler.opts.ValidateLine != nil {
+		if invalidErr = handler.opts.ValidateLine(line); invalidErr != nil {
+			if !handler.opts.SanitizeInvalidLines {
+				handler.observeDrop(r.Level, DropInvalidLine)
+
+				return invalidErr
+			}
+
+			line = sanitizeLine(line)
+		}
+	}
+
+	// Formatters writing several lines may end the last with a newline
+	// already, which would otherwise be followed by a blank line
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+
+	// Lock to protect the writer
+	defer handler.out.unlock(handler.out.lock(handler.opts.NoLock))
+
+	if handler.out.closed {
+		handler.observeDrop(r.Level, DropClosed)
+
+		return ErrClosed
+	}
+
+	n, err := 0, handler.writeHeader()
+	if err == nil {
+		// A single Write so each line is one syscall for unbuffered writers
+		n, err = writeLine(handler.out.writer, line)
+	}
+	if err == nil && handler.opts.SyncOnWrite {
+		err = syncWriter(handler.out.writer)
+	}
+
+	if threshold := handler.opts.WriteFailureThreshold; threshold > 0 {
+		if err != nil {
+			handler.out.breaker.failed(threshold, handler.writeRetryInterval())
+		} else if dropped := handler.out.breaker.succeeded(); dropped > 0 {
+			_, _ = fmt.Fprintf(handler.out.writer, "easyslog: dropped %d records after write errors\n", dropped)
+		}
+	}
+
+	if err != nil {
+		handler.observeDrop(r.Level, DropWriteError)
+	} else {
+		handler.observeRecord(r.Level, n, start)
+	}
+
+	if err == nil {
+		err = invalidErr
+	}
+
+	return err
+}
+
+// writeHeader writes the formatter's header if it's a HeaderFormatter and the
+// header hasn't been written to the writer yet. It must be called with the
+// lock held.
+func (handler *EasySlog) writeHeader() error {
+	if handler.out.headerDone.Load() {
+		return nil
+	}
+
+	handler.out.headerMu.Lock()
+	defer handler.out.headerMu.Unlock()
+
+	if handler.out.headerDone.Load() {
+		return nil
+	}
+
+	if hf, ok := handler.formatter.(HeaderFormatter); ok {
+		var buf bytes.Buffer
+		if err := hf.Header(&buf); err != nil {
+			return err
+		}
+
+		if header := buf.Bytes(); len(header) > 0 {
+			if header[len(header)-1] != '\n' {
+				header = append(header, '\n')
+			}
+
+			if _, err := writeLine(handler.out.writer, header); err != nil {
+				return err
+			}
+		}
+	}
+
+	handler.out.headerDone.Store(true)
+	return nil
+}
+
+func (handler *EasySlog) writeRetryInterval() time.Duration {
+	if handler.opts.WriteRetryInterval > 0 {
+		return handler.opts.WriteRetryInterval
+	}
+
+	return DefaultWriteRetryInterval
+}
+
+// buildRecord converts r into a Record, running every Options hook up to and
+// including Tap. It returns false if the record is filtered out by level.
+// The tree is allocated from arena, which may be nil.
+func (handler *EasySlog) buildRecord(ctx context.Context, r slog.Record, arena *attrArena) (Record, bool) {
+	if handler.aboveMax(r.Level) {
+		return Record{}, false
+	}
+
+	// slog.Logger checks Enabled before calling Handle, but a context-carried
+	// level can only be honored if Handle checks it too when called directly.
+	// With ForceLevelAttr, Enabled lets every level through to be checked here.
+	if handler.opts.MinLevelFromContext != nil || handler.opts.ForceLevelAttr != "" {
+		if r.Level < handler.minLevel(ctx) && (handler.opts.ForceLevelAttr == "" || !handler.forced(r)) {
+			return Record{}, false
+		}
+	}
+
+	if handler.opts.Sampler != nil && !handler.opts.Sampler(ctx, r) {
+		handler.observeDrop(r.Level, DropSampled)
+
+		return Record{}, false
+	}
+
+	var attrs []*Attr
+	switch {
+	case handler.opts.FlattenGroups != "":
+		attrs = handler.flatRecordAttrs(r, arena)
+	case len(handler.root.Children) == 0 && r.NumAttrs() <= smallRecordMaxAttrs:
+		attrs = handler.smallRecordAttrs(r, arena)
+	default:
+		attrs = handler.recordAttrs(r, arena)
+	}
+
+	if len(handler.prelude) > 0 {
+		attrs = handler.withPrelude(attrs, arena)
+	}
+
+	if handler.opts.IncludePID || handler.opts.IncludeGoroutineID {
+		attrs = handler.appendProcessAttrs(attrs, arena)
+	}
+
+	if level := handler.opts.StackTraceLevel; level != nil && r.Level >= *level {
+		attrs = append(attrs, handler.stackAttrs(r.PC)...)
+	}
+
+	message := r.Message
+	if handler.opts.ReplaceMessage != nil {
+		message = handler.opts.ReplaceMessage(message)
+	}
+
+	record := Record{
+		Time:    r.Time,
+		PC:      r.PC,
+		Message: message,
+		Level:   r.Level,
+		Attrs:   attrs,
+		Groups:  handler.groups,
+	}
+
+	if handler.opts.OmitTime {
+		record.Time = time.Time{}
+	} else if handler.opts.TimeZone != nil && !record.Time.IsZero() {
+		record.Time = record.Time.In(handler.opts.TimeZone)
+	}
+
+	if handler.opts.LevelNames != nil {
+		record.LevelName = handler.opts.LevelNames.Name(r.Level)
+	}
+
+	if handler.opts.AddSequence {
+		record.Seq = handler.out.seq.Add(1)
+	}
+
+	if handler.opts.IncludePID {
+		record.PID = pid
+	}
+
+	record.HandlerName = handler.name
+
+	if handler.opts.TransformRecord != nil {
+		if handler.opts.FlattenGroups != "" {
+			// Flat attributes from WithAttrs are shared between calls
+			for i, attr := range record.Attrs {
+				copied := *attr
+				record.Attrs[i] = &copied
+			}
+		}
+		record.Groups = slices.Clip(record.Groups)
+
+		// Transform a copy so record itself stays on the stack when
+		// TransformRecord is unset
+		transformed := record
+		handler.opts.TransformRecord(&transformed)
+		record = transformed
+	}
+
+	if handler.opts.MessageTemplate != nil {
+		record.Message = handler.opts.MessageTemplate(record.Message, record)
+	}
+
+	if handler.opts.SortAttrs {
+		sortAttrs(record.Attrs)
+	}
+
+	if handler.opts.Tap != nil {
+		handler.opts.Tap(record)
+	}
+
+	return record, true
+}
+
+// writeRecord passes record to the writer if it's a RecordWriter, reporting
+// whether it was one.
+func (handler *EasySlog) writeRecord(record Record, start time.Time) (bool, error) {
+	if !handler.out.records.Load() {
+		return false, nil
+	}
+
+	defer handler.out.unlock(handler.out.lock(handler.opts.NoLock))
+
+	// Recheck now that SetOutput can't swap the writer
+	rw, ok := handler.out.writer.(RecordWriter)
+	if !ok {
+		return false, nil
+	}
+
+	if handler.out.closed {
+		handler.observeDrop(record.Level, DropClosed)
+
+		return true, ErrClosed
+	}
+
+	err := handler.callWriteRecord(rw, record)
+
+	var panicErr *FormatterPanicError
+	if errors.As(err, &panicErr) {
+		handler.observeDrop(record.Level, DropFormatError)
+
+		if !handler.opts.NoPanicFallback {
+			_, _ = fmt.Fprintf(handler.out.writer, "easyslog: formatter panic: %v msg=%q\n", panicErr.Value, record.Message)
+		}
+
+		return true, err
+	}
+
+	if err != nil {
+		handler.observeDrop(record.Level, DropWriteError)
+	} else {
+		handler.observeRecord(record.Level, 0, start)
+	}
+
+	return true, err
+}
+
+// callWriteRecord calls rw.WriteRecord, converting a panic into a
+// FormatterPanicError like format.
+func (handler *EasySlog) callWriteRecord(rw RecordWriter, record Record) (err error) {
+	if !handler.opts.DisablePanicRecovery {
+		defer recoverFormatterPanic(&err)
+	}
+
+	return rw.WriteRecord(record)
+}
+
+// syncWriter calls Sync or Flush on w if it implements either.
+func syncWriter(w io.Writer) error {
+	switch w := w.(type) {
+	case interface{ Sync() error }:
+		return w.Sync()
+	case interface{ Flush() error }:
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// Get returns the value of the leaf attribute at the given path of keys, e.g.
+// `Get("user", "id")`. The first attribute matching each key is used.
+func (r Record) Get(path ...string) (slog.Value, bool) {
+	attr := findAttr(r.Attrs, path)
+	if attr == nil || attr.IsGroup() {
+		return slog.Value{}, false
+	}
+
+	return attr.Value, true
+}
+
+// Clone returns a copy of r with its own copy of the attribute tree, so it can
+// be retained or modified independently of r, e.g. with Options.ReuseAttrs.
+func (r Record) Clone() Record {
+	attrs := make([]*Attr, len(r.Attrs))
+	for i, attr := range r.Attrs {
+		attrs[i] = attr.clone()
+	}
+
+	r.Attrs = attrs
+	r.Groups = slices.Clip(r.Groups)
+
+	return r
+}
+
+// Delete removes the attribute at the given path of keys, returning false if
+// the path doesn't exist. Groups left empty by the removal are removed too.
+// The first attribute matching each key is used.
+func (r *Record) Delete(path ...string) bool {
+	attrs, ok := deleteAttr(r.Attrs, path)
+	if ok {
+		r.Attrs = attrs
+	}
+
+	return ok
+}
+
+// Without returns a copy of r without the attribute at the given path of keys,
+// removing groups left empty, like Delete. Unlike Delete it doesn't modify
+// r's attribute tree, so formatters can use it on the record they're passed.
+// The first attribute matching each key is used.
+func (r Record) Without(path ...string) Record {
+	r.Attrs = withoutAttr(r.Attrs, path)
+
+	return r
+}
+
+// Add adds a to the group at the given path of keys, or to the top level if no
+// path is given, returning false if the group doesn't exist. Group values are
+// expanded like attributes passed to a Logger, but Options like MaxValueBytes
+// don't apply. With FlattenGroups, call it without a path and with a joined
+// key instead.
+func (r *Record) Add(a slog.Attr, groupPath ...string) bool {
+	parent := &Attr{Children: r.Attrs, group: true}
+	if len(groupPath) > 0 {
+		parent = findAttr(r.Attrs, groupPath)
+		if parent == nil || !parent.IsGroup() {
+			return false
+		}
+	}
+
+	// A zero handler parses without any limits besides DefaultMaxDepth
+	new(EasySlog).parseValue(a, parent, groupPath, len(groupPath), nil, nil)
+
+	if len(groupPath) == 0 {
+		r.Attrs = parent.Children
+	}
+
+	return true
+}
+
+// Flatten returns the leaf attributes of r in order, with the keys of their
+// enclosing groups joined by sep, e.g. `request.method` for a `.` sep, for
+// formatters that can't represent nesting. The leaves are copies, so the
+// record's tree isn't modified, and empty groups are left out.
+func (r Record) Flatten(sep string) []*Attr {
+	return flattenAttrs(make([]*Attr, 0, countLeaves(r.Attrs)), r.Attrs, "", sep)
+}
+
+func flattenAttrs(dst []*Attr, attrs []*Attr, prefix string, sep string) []*Attr {
+	for _, attr := range attrs {
+		key := attr.Key
+		if prefix != "" {
+			key = prefix + sep + key
+		}
+
+		if attr.IsGroup() {
+			dst = flattenAttrs(dst, attr.Children, key, sep)
+			continue
+		}
+
+		dst = append(dst, &Attr{Key: key, Value: attr.Value, Persistent: attr.Persistent})
+	}
+
+	return dst
+}
+
+// withPrelude returns attrs preceded by the handler's prelude. The prelude is
+// only cloned when TransformRecord or SortAttrs could modify it.
+func (handler *EasySlog) withPrelude(attrs []*Attr, arena *attrArena) []*Attr {
+	all := arena.newChildren(0, len(handler.prelude)+len(attrs))
+	if (handler.opts.TransformRecord != nil || handler.opts.SortAttrs) && handler.opts.FlattenGroups == "" {
+		for _, attr := range handler.prelude {
+			all = append(all, attr.cloneIn(arena))
+		}
+	} else {
+		all = append(all, handler.prelude...)
+	}
+
+	return append(all, attrs...)
+}
+
+// recordAttrs merges the record's attributes into a clone of the handler's
+// tree and returns the pruned top-level attributes.
+func (handler *EasySlog) recordAttrs(r slog.Record, arena *attrArena) []*Attr {
+	root := handler.root.cloneIn(arena)
 	currentGroup := handler.getCurrentGroup(root)
+	children := arena.newChildren(len(currentGroup.Children), len(currentGroup.Children)+r.NumAttrs())
+	copy(children, currentGroup.Children)
+	currentGroup.Children = children
 
+	budget := handler.newBudget(func() int { return countLeaves(root.Children) })
 	r.Attrs(func(a slog.Attr) bool {
-		parseValue(a, currentGroup)
+		handler.parseValue(a, currentGroup, handler.groups, len(handler.groups), budget, arena)
 		return true
 	})
 
-	prune(root)
+	prune(root, handler.opts.KeepEmptyGroups)
 
-	rootAttrs := make([]*Attr, 0, len(root.Children))
-	for _, attr := range root.Children {
-		rootAttrs = append(rootAttrs, attr)
+	if budget != nil && budget.dropped > 0 {
+		root.Children = append(root.Children, budget.truncatedAttr())
 	}
 
-	var buf bytes.Buffer
-	err := handler.formatter.Format(&buf, Record{
-		Time:    r.Time,
-		PC:      r.PC,
-		Message: r.Message,
-		Level:   r.Level,
-		Attrs:   rootAttrs,
+	// root is a per-call clone, so its children can be handed to the formatter
+	// directly without copying them into a new slice.
+	return root.Children
+}
+
+// smallRecordMaxAttrs is the most attributes a record may have to be built by
+// smallRecordAttrs.
+const smallRecordMaxAttrs = 4
+
+// smallRecordAttrs builds the attributes of a record with few of them for a
+// handler without WithAttrs or WithGroup. There's no tree to merge them into,
+// so it skips the clone and prune of recordAttrs, and when the attributes are
+// all leaves without an arena, their nodes share a single allocation.
+func (handler *EasySlog) smallRecordAttrs(r slog.Record, arena *attrArena) []*Attr {
+	children := arena.newChildren(0, r.NumAttrs())
+
+	if arena == nil && leavesOnly(r) {
+		// Leaves take one node each, plus one for parent, so the nodes never
+		// outgrow the chunk
+		arena = &attrArena{}
+		arena.nodes.chunks = [][]Attr{make([]Attr, r.NumAttrs()+1)}
+	}
+
+	parent := arena.newAttr()
+	parent.Children = children
+
+	budget := handler.newBudget(func() int { return 0 })
+	r.Attrs(func(a slog.Attr) bool {
+		handler.parseValue(a, parent, nil, 0, budget, arena)
+		return true
 	})
 
-	if err != nil {
-		return err
+	if budget != nil && budget.dropped > 0 {
+		parent.Children = append(parent.Children, budget.truncatedAttr())
 	}
 
-	buf.WriteByte('\n')
+	return parent.Children
+}
 
-	// Lock to protect the writer
-	handler.mu.Lock()
-	defer handler.mu.Unlock()
+// leavesOnly reports whether none of r's attributes are groups or LogValuers,
+// which may resolve to groups.
+func leavesOnly(r slog.Record) bool {
+	leaves := true
+	r.Attrs(func(a slog.Attr) bool {
+		kind := a.Value.Kind()
+		leaves = kind != slog.KindGroup && kind != slog.KindLogValuer
+		return leaves
+	})
 
-	_, err = io.Copy(handler.writer, &buf)
-	return err
+	return leaves
 }
 
-func parseValue(a slog.Attr, parent *Attr) {
-	if a.Value.Kind() != slog.KindGroup && a.Value.Any() == nil {
+// flatRecordAttrs appends the record's attributes, with their keys joined to the
+// current group prefix, to the handler's flat attributes.
+func (handler *EasySlog) flatRecordAttrs(r slog.Record, arena *attrArena) []*Attr {
+	attrs := arena.newChildren(len(handler.flatAttrs), len(handler.flatAttrs)+r.NumAttrs())
+	copy(attrs, handler.flatAttrs)
+
+	budget := handler.newBudget(func() int { return len(attrs) })
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = handler.parseFlatValue(a, handler.prefix, handler.groups, len(handler.groups), attrs, budget, arena)
+		return true
+	})
+
+	if budget != nil && budget.dropped > 0 {
+		attrs = append(attrs, budget.truncatedAttr())
+	}
+
+	return attrs
+}
+
+// attrBudget tracks how many more leaves a record may have under
+// Options.MaxAttrs, and how many were dropped once it ran out.
+type attrBudget struct {
+	remaining int
+	dropped   int
+}
+
+// TruncatedKey is the key of the attribute added when Options.MaxAttrs drops
+// attributes. Its value is the number of attributes dropped.
+const TruncatedKey = "_truncated"
+
+const (
+	// DefaultMaxDepth is the group nesting allowed when Options.MaxDepth is
+	// unset.
+	DefaultMaxDepth = 100
+	// DepthExceededValue replaces groups nested deeper than Options.MaxDepth.
+	DepthExceededValue = "…(max depth exceeded)"
+)
+
+// newBudget returns nil when MaxAttrs is unset. existing returns the number of
+// leaves the handler already contributes, which count against the budget.
+func (handler *EasySlog) newBudget(existing func() int) *attrBudget {
+	if handler.opts.MaxAttrs <= 0 {
+		return nil
+	}
+
+	return &attrBudget{remaining: handler.opts.MaxAttrs - existing()}
+}
+
+// take reports whether another leaf fits in the budget, counting it as dropped
+// if not. A nil budget is unlimited.
+func (budget *attrBudget) take() bool {
+	if budget == nil {
+		return true
+	}
+
+	if budget.remaining <= 0 {
+		budget.dropped++
+		return false
+	}
+
+	budget.remaining--
+	return true
+}
+
+// full reports whether no more leaves fit in the budget. A nil budget is
+// never full.
+func (budget *attrBudget) full() bool {
+	return budget != nil && budget.remaining <= 0
+}
+
+func (budget *attrBudget) truncatedAttr() *Attr {
+	return &Attr{Key: TruncatedKey, Value: slog.IntValue(budget.dropped)}
+}
+
+// sortAttrs stably sorts attrs and the children of every group in them by key.
+func sortAttrs(attrs []*Attr) {
+	slices.SortStableFunc(attrs, func(a, b *Attr) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	for _, attr := range attrs {
+		if attr.IsGroup() {
+			sortAttrs(attr.Children)
+		}
+	}
+}
+
+func countLeaves(attrs []*Attr) int {
+	count := 0
+	for _, attr := range attrs {
+		if attr.IsGroup() {
+			count += countLeaves(attr.Children)
+		} else {
+			count++
+		}
+	}
+
+	return count
+}
+
+// bufferPool holds the buffers Handle formats records into.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the capacity above which a buffer isn't returned to
+// bufferPool, so one huge line doesn't pin memory forever.
+const maxPooledBuffer = 64 * 1024
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
 		return
 	}
 
-	if a.Value.Kind() != slog.KindGroup {
-		parent.Children = append(parent.Children, &Attr{
-			Key:   a.Key,
-			Value: a.Value.Resolve(),
-		})
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// format calls the formatter, converting a panic into a FormatterPanicError so
+// a misbehaving formatter can't take down the calling goroutine, unless
+// Options.DisablePanicRecovery is set.
+func (handler *EasySlog) format(buf *bytes.Buffer, record Record, raw slog.Record) (err error) {
+	if !handler.opts.DisablePanicRecovery {
+		defer recoverFormatterPanic(&err)
+	}
+
+	formatter := handler.formatter
+	if handler.opts.FormatterFor != nil {
+		if f := handler.opts.FormatterFor(record.Level); f != nil {
+			formatter = f
+		}
+	}
+
+	if rf, ok := formatter.(RawFormatter); ok {
+		return rf.FormatRaw(buf, record, raw.Clone())
+	}
+
+	return formatter.Format(buf, record)
+}
+
+// recoverFormatterPanic stores a recovered panic in err as a
+// FormatterPanicError. It must be deferred directly to recover the panic.
+func recoverFormatterPanic(err *error) {
+	if v := recover(); v != nil {
+		*err = &FormatterPanicError{Value: v, Stack: debug.Stack()}
+	}
+}
+
+// Error returns the panic value followed by the captured stack.
+func (e *FormatterPanicError) Error() string {
+	return fmt.Sprintf("easyslog: formatter panic: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it was an error.
+func (e *FormatterPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// parseValue adds a to parent. path holds the keys of the groups enclosing
+// parent and is only used by Options.KeyTransformer, and depth is the number
+// of those groups. New nodes are allocated from arena, which may be nil.
+func (handler *EasySlog) parseValue(a slog.Attr, parent *Attr, path []string, depth int, budget *attrBudget, arena *attrArena) {
+	key, value, ok := handler.resolveAttr(a, path, budget)
+	if !ok {
+		return
+	}
+
+	if value.Kind() == slog.KindGroup && depth >= handler.maxDepth() {
+		value = slog.StringValue(DepthExceededValue)
+	}
+
+	if value.Kind() != slog.KindGroup {
+		if isNil(value) || !budget.take() {
+			return
+		}
+
+		leaf := arena.newAttr()
+		leaf.Key = handler.uniqueKey(parent.Children, key)
+		leaf.Value = handler.leafValue(value)
+		parent.Children = append(parent.Children, leaf)
 
 		return
 	}
@@ -207,15 +1554,16 @@ func parseValue(a slog.Attr, parent *Attr) {
 	isSubgroup := false
 	if a.Key != "" {
 		isSubgroup = true
-		groupAttr = &Attr{
-			Key:      a.Key,
-			Value:    slog.AnyValue(nil),
-			Children: make([]*Attr, 0, len(a.Value.Group())),
-		}
+		path = handler.childPath(path, key)
+		groupAttr = arena.newAttr()
+		groupAttr.Key = handler.uniqueKey(parent.Children, key)
+		groupAttr.Value = slog.AnyValue(nil)
+		groupAttr.Children = arena.newChildren(0, len(value.Group()))
+		groupAttr.group = true
 	}
 
-	for _, attr := range a.Value.Group() {
-		parseValue(attr, groupAttr)
+	for _, attr := range value.Group() {
+		handler.parseValue(attr, groupAttr, path, depth+1, budget, arena)
 	}
 
 	if isSubgroup && len(groupAttr.Children) != 0 {
@@ -223,13 +1571,190 @@ func parseValue(a slog.Attr, parent *Attr) {
 	}
 }
 
-func prune(a *Attr) {
-	for i, child := range a.Children {
-		if child.empty() {
-			a.Children = slices.Delete(a.Children, i, i+1)
-			continue
+// parseFlatValue appends the leaves of a to dst with keys joined to prefix by
+// the FlattenGroups separator. Groups never produce an Attr of their own, so
+// empty groups vanish.
+func (handler *EasySlog) parseFlatValue(a slog.Attr, prefix string, path []string, depth int, dst []*Attr, budget *attrBudget, arena *attrArena) []*Attr {
+	sep := handler.opts.FlattenGroups
+	key, value, ok := handler.resolveAttr(a, path, budget)
+	if !ok {
+		return dst
+	}
+
+	if value.Kind() == slog.KindGroup && depth >= handler.maxDepth() {
+		value = slog.StringValue(DepthExceededValue)
+	}
+
+	if value.Kind() != slog.KindGroup {
+		if isNil(value) || !budget.take() {
+			return dst
 		}
 
-		prune(child)
+		leaf := arena.newAttr()
+		leaf.Key = handler.uniqueKey(dst, joinKey(prefix, key, sep))
+		leaf.Value = handler.leafValue(value)
+
+		return append(dst, leaf)
+	}
+
+	if a.Key != "" {
+		prefix = joinKey(prefix, key, sep)
+		path = handler.childPath(path, key)
+	}
+
+	for _, attr := range value.Group() {
+		dst = handler.parseFlatValue(attr, prefix, path, depth+1, dst, budget, arena)
 	}
+
+	return dst
+}
+
+// resolveAttr returns the transformed key and resolved value of a, or false if
+// a is dropped. Attributes dropped by their key or by MaxAttrs are dropped
+// before a LogValuer is resolved, so their cost is never paid. Groups that
+// can't fit in the budget count as a single dropped attribute.
+func (handler *EasySlog) resolveAttr(a slog.Attr, path []string, budget *attrBudget) (string, slog.Value, bool) {
+	key := a.Key
+	if a.Key != "" {
+		key = handler.transformKey(path, a.Key)
+		if key == "" {
+			return "", slog.Value{}, false
+		}
+	}
+
+	if a.Value.Kind() == slog.KindLogValuer && budget.full() {
+		budget.dropped++
+		return "", slog.Value{}, false
+	}
+
+	// Resolve before checking the kind so a LogValuer that returns a group,
+	// including one with an empty key, is expanded or inlined like a literal
+	// slog.Group.
+	value := a.Value.Resolve()
+
+	if a.Key == "" && value.Kind() != slog.KindGroup {
+		switch handler.opts.EmptyKeys {
+		case DropEmptyKeys:
+			return "", slog.Value{}, false
+		case NameEmptyKeys:
+			key = EmptyKeyName
+		default:
+			// Kept unless KeyTransformer drops them
+			key = handler.transformKey(path, a.Key)
+		}
+	}
+
+	return key, value, true
+}
+
+// maxDepth returns Options.MaxDepth, or DefaultMaxDepth if it's unset.
+func (handler *EasySlog) maxDepth() int {
+	if handler.opts.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+
+	return handler.opts.MaxDepth
+}
+
+// transformKey applies Options.KeyTransformer and Options.KeySanitizer to
+// key, if set.
+func (handler *EasySlog) transformKey(path []string, key string) string {
+	if handler.opts.KeyTransformer != nil {
+		key = handler.opts.KeyTransformer(path, key)
+	}
+
+	if handler.opts.KeySanitizer != nil && key != "" {
+		key = handler.opts.KeySanitizer(key)
+	}
+
+	return key
+}
+
+// uniqueKey returns key, or key with the lowest free `_N` suffix if
+// Options.KeySanitizer is set and one of siblings already uses it.
+func (handler *EasySlog) uniqueKey(siblings []*Attr, key string) string {
+	if handler.opts.KeySanitizer == nil || key == "" || !hasKey(siblings, key) {
+		return key
+	}
+
+	for n := 2; ; n++ {
+		candidate := key + "_" + strconv.Itoa(n)
+		if !hasKey(siblings, candidate) {
+			return candidate
+		}
+	}
+}
+
+func hasKey(attrs []*Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// childPath returns path extended with key for the attributes of a group. The
+// path is only tracked when a KeyTransformer needs it.
+func (handler *EasySlog) childPath(path []string, key string) []string {
+	if handler.opts.KeyTransformer == nil {
+		return path
+	}
+
+	return append(slices.Clip(path), key)
+}
+
+// leafValue applies ValueStringer and MaxValueBytes to a resolved leaf value.
+// Strings and values of KindAny longer than the limit become truncated
+// strings.
+func (handler *EasySlog) leafValue(v slog.Value) slog.Value {
+	if handler.opts.ValueStringer != nil && v.Kind() == slog.KindAny {
+		if str, ok := handler.opts.ValueStringer(v.Any()); ok {
+			v = slog.StringValue(str)
+		}
+	}
+
+	limit := handler.opts.MaxValueBytes
+	if limit <= 0 {
+		return v
+	}
+
+	var str string
+	switch v.Kind() {
+	case slog.KindString, slog.KindAny:
+		str = v.String()
+	default:
+		return v
+	}
+
+	if len(str) <= limit {
+		return v
+	}
+
+	// Back up so a multi-byte rune isn't split
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(str[cut]) {
+		cut--
+	}
+
+	return slog.StringValue(str[:cut] + "…(truncated " + strconv.Itoa(len(str)-cut) + " bytes)")
+}
+
+func joinKey(prefix string, key string, sep string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + sep + key
+}
+
+// prune removes dead-end nodes from the tree, including groups that only
+// become empty once their own children have been pruned. If keepWithGroups is
+// true, groups opened via WithGroup are kept even when empty.
+func prune(a *Attr, keepWithGroups bool) {
+	a.Children = slices.DeleteFunc(a.Children, func(child *Attr) bool {
+		prune(child, keepWithGroups)
+		return child.empty() && !(keepWithGroups && child.withGroup)
+	})
 }
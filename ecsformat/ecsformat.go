@@ -0,0 +1,248 @@
+// Package ecsformat implements an easyslog.Formatter that renders records as
+// Elastic Common Schema (ECS) JSON documents for Elasticsearch.
+package ecsformat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// Version is the ECS version written to each document as `ecs.version`.
+const Version = "8.11.0"
+
+const (
+	// DefaultNamespace is used when Formatter.Namespace is empty.
+	DefaultNamespace = "labels"
+	// DefaultErrorKey is used when Formatter.ErrorKey is empty.
+	DefaultErrorKey = "err"
+)
+
+// Formatter implements easyslog.Formatter and renders each record as an ECS
+// document with `@timestamp`, `log.level`, `message` and `ecs.version`. When
+// Record.PC is set, `log.origin` holds the file name, line and function that
+// logged it. Attributes are mapped into ECS fields by FieldMappings and the
+// rest are nested under Namespace. Dotted ECS field names are written as
+// nested objects, e.g. `{"log":{"level":"info"}}`.
+type Formatter struct {
+	// FieldMappings maps the dot-separated path of an attribute, including
+	// the groups opened via WithGroup, to the ECS field it's written to, e.g.
+	// `http.method` to `http.request.method`. Mapping a group moves all of
+	// its attributes. Defaults to DefaultFieldMappings; copy and extend it to
+	// keep the defaults.
+	FieldMappings map[string]string
+	// Namespace is the field attributes without a mapping are nested under,
+	// so they can't collide with ECS fields. Defaults to DefaultNamespace.
+	Namespace string
+	// ErrorKey is the path of the attribute holding the record's error. Its
+	// message is written to `error.message`, its type to `error.type`, and,
+	// if it formats differently with `%+v` like errors carrying a stack, its
+	// detailed form to `error.stack_trace`. Defaults to DefaultErrorKey.
+	ErrorKey string
+	// LevelNames overrides the rendered level names. Levels not in the map
+	// render as the lowercase Record.LevelString(), e.g. `info` or `warn+2`.
+	LevelNames easyslog.LevelNamer
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("ecs", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+// DefaultFieldMappings returns the mappings used when Formatter.FieldMappings
+// is nil, as a starting point for adding more.
+func DefaultFieldMappings() map[string]string {
+	return map[string]string{
+		"http.method":      "http.request.method",
+		"http.status":      "http.response.status_code",
+		"http.path":        "url.path",
+		"http.url":         "url.full",
+		"http.user_agent":  "user_agent.original",
+		"http.remote_addr": "client.address",
+		"trace_id":         "trace.id",
+		"span_id":          "span.id",
+	}
+}
+
+var defaultFieldMappings = DefaultFieldMappings()
+
+// document is the ECS document being built for a record.
+type document struct {
+	fields    map[string]any
+	mappings  map[string]string
+	namespace []string
+	errorKey  string
+}
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	doc := &document{
+		fields:    make(map[string]any, 8),
+		mappings:  f.FieldMappings,
+		namespace: strings.Split(f.Namespace, "."),
+		errorKey:  f.ErrorKey,
+	}
+	if doc.mappings == nil {
+		doc.mappings = defaultFieldMappings
+	}
+	if f.Namespace == "" {
+		doc.namespace = []string{DefaultNamespace}
+	}
+	if doc.errorKey == "" {
+		doc.errorKey = DefaultErrorKey
+	}
+
+	if !record.Time.IsZero() {
+		doc.fields["@timestamp"] = record.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	level, ok := f.LevelNames[record.Level]
+	if !ok {
+		level = strings.ToLower(record.LevelString())
+	}
+	doc.set([]string{"log", "level"}, level)
+	doc.set([]string{"message"}, record.Message)
+	doc.set([]string{"ecs", "version"}, Version)
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		doc.set([]string{"log", "origin", "file", "name"}, filepath.Base(frame.File))
+		doc.set([]string{"log", "origin", "file", "line"}, frame.Line)
+		doc.set([]string{"log", "origin", "function"}, frame.Function)
+	}
+
+	for _, attr := range record.Attrs {
+		doc.add(attr, "", nil)
+	}
+
+	toWrite, err := json.Marshal(doc.fields)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(toWrite)
+	return err
+}
+
+// add writes attr, whose enclosing groups have the dot-separated path parent.
+// dst is the ECS field of the enclosing group when it was mapped, and nil
+// otherwise.
+func (doc *document) add(attr *easyslog.Attr, parent string, dst []string) {
+	path := attr.Key
+	if parent != "" {
+		path = parent + "." + attr.Key
+	}
+
+	if dst != nil {
+		dst = append(slices.Clip(dst), attr.Key)
+	} else if field, ok := doc.mappings[path]; ok {
+		dst = strings.Split(field, ".")
+	}
+
+	if attr.IsGroup() {
+		for _, child := range attr.Children {
+			doc.add(child, path, dst)
+		}
+		return
+	}
+
+	if dst == nil {
+		if path == doc.errorKey {
+			doc.addError(attr.Value)
+			return
+		}
+
+		dst = append(slices.Clip(doc.namespace), strings.Split(path, ".")...)
+	}
+
+	doc.set(dst, value(attr))
+}
+
+func (doc *document) addError(v slog.Value) {
+	err, ok := v.Any().(error)
+	if !ok {
+		doc.set([]string{"error", "message"}, v.String())
+		return
+	}
+
+	message := err.Error()
+	doc.set([]string{"error", "message"}, message)
+	doc.set([]string{"error", "type"}, fmt.Sprintf("%T", err))
+
+	if _, ok := err.(fmt.Formatter); ok {
+		if detailed := fmt.Sprintf("%+v", err); detailed != message {
+			doc.set([]string{"error", "stack_trace"}, detailed)
+		}
+	}
+}
+
+// set writes v to the field at path, creating the objects along it. Values
+// that are in the way of an object are replaced by it.
+func (doc *document) set(path []string, v any) {
+	fields := doc.fields
+	for _, key := range path[:len(path)-1] {
+		child, ok := fields[key].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			fields[key] = child
+		}
+
+		fields = child
+	}
+
+	fields[path[len(path)-1]] = v
+}
+
+// value returns the JSON representation of a leaf attribute. Durations are
+// nanoseconds like ECS's `event.duration`.
+func value(attr *easyslog.Attr) any {
+	if data, ok := attr.RawJSON(); ok && json.Valid(data) {
+		return json.RawMessage(data)
+	}
+
+	v := attr.Value
+	switch v.Kind() {
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		// JSON can't represent NaN or infinity
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case slog.KindDuration:
+		return v.Duration().Nanoseconds()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return x.Error()
+		case json.Marshaler:
+			if data, err := x.MarshalJSON(); err == nil && json.Valid(data) {
+				return json.RawMessage(data)
+			}
+		}
+	}
+
+	return v.String()
+}
@@ -0,0 +1,151 @@
+package ecsformat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func format(t *testing.T, f Formatter, r slog.Record) map[string]any {
+	var buf bytes.Buffer
+	require.NoError(t, easyslog.New(&buf, f, nil).Handle(context.Background(), r))
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+
+	return doc
+}
+
+func TestFormat(t *testing.T) {
+	r := slog.NewRecord(time.Unix(1700000000, 123000000).In(time.FixedZone("EST", -5*3600)), slog.LevelWarn, "slow request", 0)
+	r.AddAttrs(
+		slog.Group("http", slog.String("method", "GET"), slog.Int("status", 200), slog.String("path", "/users")),
+		slog.String("trace_id", "abc"),
+		slog.Duration("took", 1500*time.Millisecond),
+		slog.Group("user", slog.Int("id", 7)),
+		slog.Bool("cached", false),
+	)
+
+	require.Equal(t, map[string]any{
+		"@timestamp": "2023-11-14T22:13:20.123Z",
+		"message":    "slow request",
+		"log":        map[string]any{"level": "warn"},
+		"ecs":        map[string]any{"version": Version},
+		"http": map[string]any{
+			"request":  map[string]any{"method": "GET"},
+			"response": map[string]any{"status_code": float64(200)},
+		},
+		"url":   map[string]any{"path": "/users"},
+		"trace": map[string]any{"id": "abc"},
+		"labels": map[string]any{
+			"took":   float64(1500000000),
+			"user":   map[string]any{"id": float64(7)},
+			"cached": false,
+		},
+	}, format(t, Formatter{}, r))
+}
+
+func TestFieldMappings(t *testing.T) {
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "msg", 0)
+	r.AddAttrs(
+		slog.Group("req", slog.String("id", "r-1"), slog.Group("client", slog.String("ip", "10.0.0.1"))),
+		slog.String("http.method", "GET"),
+		slog.String("other", "x"),
+	)
+
+	doc := format(t, Formatter{
+		FieldMappings: map[string]string{"req.id": "http.request.id", "req.client": "client"},
+		Namespace:     "app.fields",
+	}, r)
+
+	require.Equal(t, map[string]any{
+		"message": "msg",
+		"log":     map[string]any{"level": "info"},
+		"ecs":     map[string]any{"version": Version},
+		"http":    map[string]any{"request": map[string]any{"id": "r-1"}},
+		"client":  map[string]any{"ip": "10.0.0.1"},
+		"app": map[string]any{"fields": map[string]any{
+			"http":  map[string]any{"method": "GET"},
+			"other": "x",
+		}},
+	}, doc)
+}
+
+// stackError formats with a fake stack for `%+v` like github.com/pkg/errors.
+type stackError struct{ msg string }
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%s\nmain.run\n\tmain.go:12", e.msg)
+		return
+	}
+
+	fmt.Fprint(s, e.msg)
+}
+
+func TestError(t *testing.T) {
+	r := slog.NewRecord(time.Time{}, slog.LevelError, "failed", 0)
+	r.AddAttrs(slog.Any("err", fmt.Errorf("save: %w", errors.New("disk full"))))
+
+	doc := format(t, Formatter{}, r)
+	require.Equal(t, map[string]any{"message": "save: disk full", "type": "*fmt.wrapError"}, doc["error"])
+	require.NotContains(t, doc, "labels")
+
+	r = slog.NewRecord(time.Time{}, slog.LevelError, "failed", 0)
+	r.AddAttrs(slog.Any("failure", &stackError{msg: "boom"}), slog.Any("err", errors.New("ignored")))
+
+	doc = format(t, Formatter{ErrorKey: "failure"}, r)
+	require.Equal(t, map[string]any{
+		"message":     "boom",
+		"type":        "*ecsformat.stackError",
+		"stack_trace": "boom\nmain.run\n\tmain.go:12",
+	}, doc["error"])
+	require.Equal(t, map[string]any{"err": "ignored"}, doc["labels"])
+
+	r = slog.NewRecord(time.Time{}, slog.LevelError, "failed", 0)
+	r.AddAttrs(slog.String("err", "plain"))
+
+	doc = format(t, Formatter{}, r)
+	require.Equal(t, map[string]any{"message": "plain"}, doc["error"])
+}
+
+func TestOrigin(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+
+	doc := format(t, Formatter{}, slog.NewRecord(time.Time{}, slog.LevelInfo, "msg", pcs[0]))
+
+	require.Equal(t, map[string]any{
+		"level": "info",
+		"origin": map[string]any{
+			"file":     map[string]any{"name": "ecsformat_test.go", "line": float64(frame.Line)},
+			"function": "github.com/blakewilliams/easyslog/ecsformat.TestOrigin",
+		},
+	}, doc["log"])
+}
+
+func TestLevelNames(t *testing.T) {
+	doc := format(t, Formatter{LevelNames: easyslog.LevelNamer{slog.LevelError: "critical"}}, slog.NewRecord(time.Time{}, slog.LevelError, "msg", 0))
+	require.Equal(t, map[string]any{"level": "critical"}, doc["log"])
+
+	doc = format(t, Formatter{}, slog.NewRecord(time.Time{}, slog.LevelInfo+2, "msg", 0))
+	require.Equal(t, map[string]any{"level": "info+2"}, doc["log"])
+}
+
+func TestNewFormatter(t *testing.T) {
+	formatter, err := easyslog.NewFormatter("ecs", map[string]any{"namespace": "fields", "field_mappings": map[string]any{"a": "b"}})
+	require.NoError(t, err)
+	require.Equal(t, Formatter{Namespace: "fields", FieldMappings: map[string]string{"a": "b"}}, formatter)
+}
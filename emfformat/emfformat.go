@@ -0,0 +1,309 @@
+// Package emfformat implements an easyslog.Formatter that renders records as
+// CloudWatch Embedded Metric Format (EMF) documents, so metrics can be
+// published by logging them, e.g. from AWS Lambda.
+package emfformat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+const (
+	// MessageKey is the property holding the record's message.
+	MessageKey = "message"
+	// LevelKey is the property holding the record's level.
+	LevelKey = "level"
+	// MetadataKey is the property holding the EMF metadata.
+	MetadataKey = "_aws"
+)
+
+// Units accepted by CloudWatch for Formatter.MetricKeys. Any other unit
+// CloudWatch supports, like `Kilobytes/Second`, can be used as well.
+const (
+	None         = "None"
+	Count        = "Count"
+	Percent      = "Percent"
+	Seconds      = "Seconds"
+	Milliseconds = "Milliseconds"
+	Microseconds = "Microseconds"
+	Bytes        = "Bytes"
+)
+
+// MissingMetricError is returned by Format when Formatter.ErrorOnMissing is
+// set and a metric or dimension is missing from the record.
+type MissingMetricError struct {
+	// Key is the dot-separated path of the missing attribute.
+	Key string
+	// Dimension is true if the attribute is a dimension rather than a metric.
+	Dimension bool
+}
+
+func (e *MissingMetricError) Error() string {
+	if e.Dimension {
+		return fmt.Sprintf("emfformat: missing dimension %q", e.Key)
+	}
+
+	return fmt.Sprintf("emfformat: missing or non-numeric metric %q", e.Key)
+}
+
+// Formatter implements easyslog.Formatter and renders each record as a JSON
+// EMF document. Attributes are flattened into top-level properties named
+// after their dot-separated path, including the groups opened via WithGroup,
+// e.g. `http.status`, alongside MessageKey and LevelKey, which take
+// precedence over attributes of the same name. The MetadataKey block lists
+// MetricKeys as the record's metrics in each of the Dimensions sets, with the
+// record's time as its millisecond Timestamp. A zero Record.Time is replaced
+// with the current time, since CloudWatch requires one.
+type Formatter struct {
+	// Namespace is the CloudWatch namespace the metrics are published to.
+	Namespace string
+	// Dimensions are the dimension sets the metrics are aggregated by, each
+	// listing the paths of the attributes holding its dimensions, e.g.
+	// `{{"service"}, {"service", "http.route"}}`. Dimension values are
+	// written as strings, as CloudWatch requires. An empty set aggregates
+	// the metrics without dimensions.
+	Dimensions [][]string
+	// MetricKeys maps the paths of the attributes holding metrics to their
+	// unit, e.g. `http.latency` to Milliseconds. Metrics must be numbers;
+	// durations are converted to Seconds, Milliseconds or Microseconds when
+	// that's their unit.
+	MetricKeys map[string]string
+	// ErrorOnMissing makes Format return a *MissingMetricError when a metric
+	// is missing or isn't a number, or a dimension is missing. By default
+	// such records are written without the MetadataKey block, so they're
+	// kept as plain log lines.
+	ErrorOnMissing bool
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("emf", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+type (
+	metadata struct {
+		Timestamp         int64       `json:"Timestamp"`
+		CloudWatchMetrics []directive `json:"CloudWatchMetrics"`
+	}
+
+	directive struct {
+		Namespace  string     `json:"Namespace"`
+		Dimensions [][]string `json:"Dimensions"`
+		Metrics    []metric   `json:"Metrics"`
+	}
+
+	metric struct {
+		Name string `json:"Name"`
+		Unit string `json:"Unit,omitempty"`
+	}
+)
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	doc := make(map[string]any, len(record.Attrs)+3)
+	for _, attr := range record.Attrs {
+		flatten(doc, attr, "")
+	}
+
+	doc[MessageKey] = record.Message
+	doc[LevelKey] = record.LevelString()
+
+	meta, err := f.metadata(doc, record)
+	if err != nil {
+		return err
+	}
+
+	// An attribute can't pose as the metadata
+	delete(doc, MetadataKey)
+	if meta != nil {
+		doc[MetadataKey] = meta
+	}
+
+	toWrite, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(toWrite)
+	return err
+}
+
+// metadata builds the EMF metadata for record, replacing the metrics and
+// dimensions in doc with their EMF representation. It returns nil if a
+// metric or dimension is missing and f.ErrorOnMissing isn't set, or if there
+// are no metrics.
+func (f Formatter) metadata(doc map[string]any, record easyslog.Record) (*metadata, error) {
+	if len(f.MetricKeys) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(f.MetricKeys))
+	for name := range f.MetricKeys {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	metrics := make([]metric, len(names))
+	values := make(map[string]any, len(names))
+	for i, name := range names {
+		unit := f.MetricKeys[name]
+
+		value, ok := record.Get(strings.Split(name, ".")...)
+		if !ok {
+			return f.missing(name, false)
+		}
+
+		number, ok := metricValue(value, unit)
+		if !ok {
+			return f.missing(name, false)
+		}
+
+		metrics[i] = metric{Name: name, Unit: unit}
+		values[name] = number
+	}
+
+	dimensions := make(map[string]string)
+	for _, set := range f.Dimensions {
+		for _, name := range set {
+			if _, ok := dimensions[name]; ok {
+				continue
+			}
+
+			value, ok := record.Get(strings.Split(name, ".")...)
+			if !ok {
+				return f.missing(name, true)
+			}
+
+			dimensions[name] = value.String()
+		}
+	}
+
+	for name, value := range values {
+		doc[name] = value
+	}
+	for name, value := range dimensions {
+		doc[name] = value
+	}
+
+	timestamp := record.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	dimensionSets := f.Dimensions
+	if dimensionSets == nil {
+		dimensionSets = [][]string{}
+	}
+
+	return &metadata{
+		Timestamp: timestamp.UnixMilli(),
+		CloudWatchMetrics: []directive{{
+			Namespace:  f.Namespace,
+			Dimensions: dimensionSets,
+			Metrics:    metrics,
+		}},
+	}, nil
+}
+
+func (f Formatter) missing(key string, dimension bool) (*metadata, error) {
+	if f.ErrorOnMissing {
+		return nil, &MissingMetricError{Key: key, Dimension: dimension}
+	}
+
+	return nil, nil
+}
+
+// metricValue returns v as a JSON number, keeping integers as integers.
+func metricValue(v slog.Value, unit string) (any, bool) {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return v.Int64(), true
+	case slog.KindUint64:
+		return v.Uint64(), true
+	case slog.KindFloat64:
+		// JSON can't represent NaN or infinity
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, true
+		}
+	case slog.KindDuration:
+		switch unit {
+		case Seconds:
+			return v.Duration().Seconds(), true
+		case Milliseconds:
+			return float64(v.Duration()) / float64(time.Millisecond), true
+		case Microseconds:
+			return float64(v.Duration()) / float64(time.Microsecond), true
+		}
+	}
+
+	return nil, false
+}
+
+// flatten writes attr into doc, named after its path below the dot-separated
+// parent.
+func flatten(doc map[string]any, attr *easyslog.Attr, parent string) {
+	path := attr.Key
+	if parent != "" {
+		path = parent + "." + attr.Key
+	}
+
+	if attr.IsGroup() {
+		for _, child := range attr.Children {
+			flatten(doc, child, path)
+		}
+		return
+	}
+
+	doc[path] = value(attr)
+}
+
+// value returns the JSON representation of a leaf attribute.
+func value(attr *easyslog.Attr) any {
+	if data, ok := attr.RawJSON(); ok && json.Valid(data) {
+		return json.RawMessage(data)
+	}
+
+	v := attr.Value
+	switch v.Kind() {
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return x.Error()
+		case json.Marshaler:
+			if data, err := x.MarshalJSON(); err == nil && json.Valid(data) {
+				return json.RawMessage(data)
+			}
+		}
+	}
+
+	return v.String()
+}
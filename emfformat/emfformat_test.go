@@ -0,0 +1,221 @@
+package emfformat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func format(t *testing.T, f Formatter, r slog.Record) (map[string]any, string) {
+	var buf bytes.Buffer
+	require.NoError(t, easyslog.New(&buf, f, nil).Handle(context.Background(), r))
+
+	line := buf.String()
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+
+	var doc map[string]any
+	require.NoError(t, decoder.Decode(&doc))
+
+	return doc, line
+}
+
+// validate checks doc against the structural rules of the EMF specification.
+func validate(t *testing.T, doc map[string]any) {
+	t.Helper()
+
+	meta, ok := doc[MetadataKey].(map[string]any)
+	require.True(t, ok, "metadata must be an object")
+
+	timestamp, ok := meta["Timestamp"].(json.Number)
+	require.True(t, ok, "Timestamp must be a number")
+	_, err := timestamp.Int64()
+	require.NoError(t, err, "Timestamp must be an integer")
+
+	directives, ok := meta["CloudWatchMetrics"].([]any)
+	require.True(t, ok, "CloudWatchMetrics must be an array")
+	require.NotEmpty(t, directives)
+
+	for _, d := range directives {
+		directive, ok := d.(map[string]any)
+		require.True(t, ok, "directive must be an object")
+
+		namespace, ok := directive["Namespace"].(string)
+		require.True(t, ok, "Namespace must be a string")
+		require.NotEmpty(t, namespace)
+		require.LessOrEqual(t, len(namespace), 1024)
+
+		sets, ok := directive["Dimensions"].([]any)
+		require.True(t, ok, "Dimensions must be an array")
+		for _, s := range sets {
+			set, ok := s.([]any)
+			require.True(t, ok, "dimension set must be an array")
+			require.LessOrEqual(t, len(set), 30)
+
+			for _, name := range set {
+				_, ok := doc[name.(string)].(string)
+				require.True(t, ok, "dimension %v must be a string member", name)
+			}
+		}
+
+		metrics, ok := directive["Metrics"].([]any)
+		require.True(t, ok, "Metrics must be an array")
+		require.NotEmpty(t, metrics)
+		require.LessOrEqual(t, len(metrics), 100)
+
+		for _, m := range metrics {
+			metric := m.(map[string]any)
+			name, ok := metric["Name"].(string)
+			require.True(t, ok, "metric Name must be a string")
+
+			_, ok = doc[name].(json.Number)
+			require.True(t, ok, "metric %s must be a number member", name)
+
+			if unit, ok := metric["Unit"]; ok {
+				require.IsType(t, "", unit)
+			}
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	f := Formatter{
+		Namespace:  "shop",
+		Dimensions: [][]string{{"service"}, {"service", "http.route"}},
+		MetricKeys: map[string]string{
+			"http.latency": Milliseconds,
+			"orders":       Count,
+			"ratio":        Percent,
+		},
+	}
+
+	r := slog.NewRecord(time.UnixMilli(1700000000123).Add(456*time.Microsecond), slog.LevelInfo, "checkout", 0)
+	r.AddAttrs(
+		slog.String("service", "api"),
+		slog.Group("http", slog.String("route", "/orders"), slog.Duration("latency", 1500*time.Microsecond), slog.Int("status", 201)),
+		slog.Int("orders", 3),
+		slog.Float64("ratio", 12.5),
+		slog.Bool("cached", true),
+	)
+
+	doc, line := format(t, f, r)
+	validate(t, doc)
+
+	require.Equal(t, map[string]any{
+		"message":     "checkout",
+		"level":       "INFO",
+		"service":     "api",
+		"http.route":  "/orders",
+		"http.status": json.Number("201"),
+		"orders":      json.Number("3"),
+		"ratio":       json.Number("12.5"),
+		// Converted to the metric's unit
+		"http.latency": json.Number("1.5"),
+		"cached":       true,
+		"_aws": map[string]any{
+			"Timestamp": json.Number("1700000000123"),
+			"CloudWatchMetrics": []any{map[string]any{
+				"Namespace":  "shop",
+				"Dimensions": []any{[]any{"service"}, []any{"service", "http.route"}},
+				"Metrics": []any{
+					map[string]any{"Name": "http.latency", "Unit": "Milliseconds"},
+					map[string]any{"Name": "orders", "Unit": "Count"},
+					map[string]any{"Name": "ratio", "Unit": "Percent"},
+				},
+			}},
+		},
+	}, doc)
+
+	// Integers stay integers rather than becoming floats
+	require.Contains(t, line, `"orders":3,`)
+}
+
+func TestDimensionsAreStrings(t *testing.T) {
+	f := Formatter{
+		Namespace:  "shop",
+		Dimensions: [][]string{{"region", "shard"}},
+		MetricKeys: map[string]string{"n": ""},
+	}
+
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "msg", 0)
+	r.AddAttrs(slog.String("region", "eu"), slog.Int("shard", 4), slog.Uint64("n", 1))
+
+	start := time.Now().UnixMilli()
+	doc, line := format(t, f, r)
+	validate(t, doc)
+
+	require.Equal(t, "4", doc["shard"])
+	require.Contains(t, line, `{"Name":"n"}`)
+
+	// A zero time is replaced by the current one
+	timestamp, err := doc[MetadataKey].(map[string]any)["Timestamp"].(json.Number).Int64()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, timestamp, start)
+}
+
+func TestWithGroup(t *testing.T) {
+	var buf bytes.Buffer
+	f := Formatter{Namespace: "ns", MetricKeys: map[string]string{"job.took": Seconds}}
+	slog.New(easyslog.New(&buf, f, nil)).WithGroup("job").Info("done", "took", 2*time.Second)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Equal(t, float64(2), doc["job.took"])
+	require.Equal(t, []any{}, doc[MetadataKey].(map[string]any)["CloudWatchMetrics"].([]any)[0].(map[string]any)["Dimensions"])
+}
+
+func TestMissing(t *testing.T) {
+	f := Formatter{
+		Namespace:  "shop",
+		Dimensions: [][]string{{"service"}},
+		MetricKeys: map[string]string{"orders": Count},
+	}
+
+	for name, attrs := range map[string][]slog.Attr{
+		"metric":      {slog.String("service", "api")},
+		"non-numeric": {slog.String("service", "api"), slog.String("orders", "3")},
+		"dimension":   {slog.Int("orders", 3)},
+		"duration":    {slog.String("service", "api"), slog.Duration("orders", time.Second)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+			r.AddAttrs(attrs...)
+			r.AddAttrs(slog.String(MetadataKey, "spoofed"))
+
+			doc, _ := format(t, f, r)
+			require.NotContains(t, doc, MetadataKey)
+			require.Equal(t, "msg", doc["message"])
+
+			strict := f
+			strict.ErrorOnMissing = true
+			err := easyslog.New(&bytes.Buffer{}, strict, nil).Handle(context.Background(), r)
+
+			var missing *MissingMetricError
+			require.True(t, errors.As(err, &missing))
+			require.Equal(t, name == "dimension", missing.Dimension)
+		})
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	f, err := easyslog.NewFormatter("emf", map[string]any{
+		"namespace":        "shop",
+		"dimensions":       []any{[]any{"service"}},
+		"metric_keys":      map[string]any{"orders": "Count"},
+		"error_on_missing": true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, Formatter{
+		Namespace:      "shop",
+		Dimensions:     [][]string{{"service"}},
+		MetricKeys:     map[string]string{"orders": "Count"},
+		ErrorOnMissing: true,
+	}, f)
+}
@@ -0,0 +1,44 @@
+package easyslog
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// MaxErrorDepth is the number of causes ErrorValue follows before stopping,
+// so an Unwrap cycle can't recurse forever.
+const MaxErrorDepth = 10
+
+// ErrorValue returns err as a group with a `msg` key holding err.Error() and,
+// if errors.Unwrap returns a cause, a nested `cause` group built the same way,
+// e.g. `{"msg": "save: disk full", "cause": {"msg": "disk full"}}`. If an error
+// in the chain implements slog.LogValuer and resolves to a group, its attrs
+// are added alongside `msg`. A nil err returns the zero Value.
+func ErrorValue(err error) slog.Value {
+	return errorValue(err, 0)
+}
+
+// ErrorChain returns an Attr for key with the value of ErrorValue(err).
+func ErrorChain(key string, err error) slog.Attr {
+	return slog.Attr{Key: key, Value: ErrorValue(err)}
+}
+
+func errorValue(err error, depth int) slog.Value {
+	if err == nil {
+		return slog.Value{}
+	}
+
+	attrs := []slog.Attr{slog.String("msg", err.Error())}
+
+	if valuer, ok := err.(slog.LogValuer); ok {
+		if details := valuer.LogValue().Resolve(); details.Kind() == slog.KindGroup {
+			attrs = append(attrs, details.Group()...)
+		}
+	}
+
+	if cause := errors.Unwrap(err); cause != nil && depth+1 < MaxErrorDepth {
+		attrs = append(attrs, slog.Attr{Key: "cause", Value: errorValue(cause, depth+1)})
+	}
+
+	return slog.GroupValue(attrs...)
+}
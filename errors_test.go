@@ -0,0 +1,64 @@
+package easyslog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type queryError struct {
+	query string
+	err   error
+}
+
+func (e *queryError) Error() string { return "query failed: " + e.err.Error() }
+func (e *queryError) Unwrap() error { return e.err }
+
+func (e *queryError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("query", e.query))
+}
+
+// loopError unwraps to itself.
+type loopError struct{}
+
+func (e *loopError) Error() string { return "loop" }
+func (e *loopError) Unwrap() error { return e }
+
+func TestErrorValue(t *testing.T) {
+	root := errors.New("connection reset")
+	err := fmt.Errorf("load user: %w", &queryError{query: "SELECT 1", err: root})
+
+	formatter := &recordingFormatter{}
+	slog.New(New(io.Discard, formatter, nil)).Error("failed", ErrorChain("err", err))
+
+	record := formatter.records[0]
+	value, ok := record.Get("err", "msg")
+	require.True(t, ok)
+	require.Equal(t, "load user: query failed: connection reset", value.String())
+
+	value, ok = record.Get("err", "cause", "query")
+	require.True(t, ok)
+	require.Equal(t, "SELECT 1", value.String())
+
+	value, ok = record.Get("err", "cause", "cause", "msg")
+	require.True(t, ok)
+	require.Equal(t, "connection reset", value.String())
+
+	_, ok = record.Get("err", "cause", "cause", "cause")
+	require.False(t, ok)
+}
+
+func TestErrorValueDepth(t *testing.T) {
+	depth := 0
+	for v := ErrorValue(&loopError{}); v.Kind() == slog.KindGroup; depth++ {
+		group := v.Group()
+		v = group[len(group)-1].Value
+	}
+
+	require.Equal(t, MaxErrorDepth, depth)
+	require.Equal(t, slog.Value{}, ErrorValue(nil))
+}
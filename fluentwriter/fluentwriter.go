@@ -0,0 +1,324 @@
+// Package fluentwriter sends log records to Fluentd or Fluent Bit over the
+// Forward protocol, without a sidecar tailing log files.
+package fluentwriter
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+var (
+	// ErrClosed is returned by Write and WriteRecord after Close has been
+	// called.
+	ErrClosed = errors.New("fluentwriter: writer closed")
+	// ErrBufferFull is returned when MaxPending events are already waiting to
+	// be sent, e.g. while disconnected. The event is dropped.
+	ErrBufferFull = errors.New("fluentwriter: pending buffer full")
+)
+
+const (
+	// DefaultMaxPending is used when Options.MaxPending is zero.
+	DefaultMaxPending = 1024
+	// DefaultMinBackoff is used when Options.MinBackoff is zero.
+	DefaultMinBackoff = 100 * time.Millisecond
+	// DefaultMaxBackoff is used when Options.MaxBackoff is zero.
+	DefaultMaxBackoff = 10 * time.Second
+	// DefaultFlushTimeout is used when Options.FlushTimeout is zero.
+	DefaultFlushTimeout = 5 * time.Second
+)
+
+// Options to configure a Writer.
+type Options struct {
+	// Tag is the Fluentd tag of every event. Defaults to "easyslog".
+	Tag string
+	// MaxPending is the number of events buffered while they wait to be sent.
+	// Defaults to DefaultMaxPending.
+	MaxPending int
+	// MinBackoff and MaxBackoff bound the delay between reconnect attempts,
+	// which doubles after each failure. Default to DefaultMinBackoff and
+	// DefaultMaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// FlushTimeout is how long Close keeps trying to send pending events.
+	// Defaults to DefaultFlushTimeout.
+	FlushTimeout time.Duration
+}
+
+// Writer sends events to a Forward protocol endpoint in Message mode,
+// `[tag, time, record]`. Events are queued and sent by a background
+// goroutine, which reconnects with exponential backoff when the connection
+// fails.
+//
+// Writer implements easyslog.RecordWriter, so a handler writing to it sends
+// each record as a map of `level`, `msg`, and its attributes, with groups as
+// nested maps, and never calls its formatter. Used as a plain io.Writer,
+// e.g. behind another handler, each line is sent as a `message` field.
+type Writer struct {
+	network string
+	address string
+	tag     string
+	dial    func(network, address string, timeout time.Duration) (net.Conn, error)
+
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	flushTimeout time.Duration
+
+	pending chan []byte
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+var _ easyslog.RecordWriter = (*Writer)(nil)
+
+// New returns a Writer sending to address on network, e.g. "tcp" and
+// "localhost:24224" or "unix" and a socket path. It connects in the
+// background, so it doesn't fail if the endpoint is down. opts may be nil.
+func New(network string, address string, opts *Options) *Writer {
+	return newWithDialer(network, address, opts, net.DialTimeout)
+}
+
+// newWithDialer is New with the function used to connect, for tests.
+func newWithDialer(network string, address string, opts *Options, dial func(network, address string, timeout time.Duration) (net.Conn, error)) *Writer {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	w := &Writer{
+		network:      network,
+		address:      address,
+		tag:          opts.Tag,
+		dial:         dial,
+		minBackoff:   opts.MinBackoff,
+		maxBackoff:   opts.MaxBackoff,
+		flushTimeout: opts.FlushTimeout,
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+
+	if w.tag == "" {
+		w.tag = "easyslog"
+	}
+	if w.minBackoff <= 0 {
+		w.minBackoff = DefaultMinBackoff
+	}
+	if w.maxBackoff <= 0 {
+		w.maxBackoff = DefaultMaxBackoff
+	}
+	if w.flushTimeout <= 0 {
+		w.flushTimeout = DefaultFlushTimeout
+	}
+
+	maxPending := opts.MaxPending
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPending
+	}
+	w.pending = make(chan []byte, maxPending)
+
+	go w.run()
+
+	return w
+}
+
+// WriteRecord implements easyslog.RecordWriter and queues r as an event.
+func (w *Writer) WriteRecord(r easyslog.Record) error {
+	e := w.header(r.Time)
+	e.mapHeader(len(r.Attrs) + 2)
+	e.str("level")
+	e.str(r.LevelString())
+	e.str("msg")
+	e.str(r.Message)
+	for _, attr := range r.Attrs {
+		e.str(attr.Key)
+		if attr.IsGroup() {
+			e.attrs(attr.Children)
+		} else {
+			e.value(attr.Value)
+		}
+	}
+
+	return w.enqueue(e.b)
+}
+
+// Write queues p, without its trailing newline, as an event with a single
+// `message` field.
+func (w *Writer) Write(p []byte) (int, error) {
+	message := p
+	if n := len(message); n > 0 && message[n-1] == '\n' {
+		message = message[:n-1]
+	}
+
+	e := w.header(time.Now())
+	e.mapHeader(1)
+	e.str("message")
+	e.str(string(message))
+
+	if err := w.enqueue(e.b); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (w *Writer) header(t time.Time) *encoder {
+	e := &encoder{b: make([]byte, 0, 256)}
+	e.arrayHeader(3)
+	e.str(w.tag)
+	if t.IsZero() {
+		t = time.Now()
+	}
+	e.eventTime(t)
+
+	return e
+}
+
+func (w *Writer) enqueue(event []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	select {
+	case w.pending <- event:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// Close stops accepting events and waits up to FlushTimeout for pending
+// events to be sent before closing the connection. It's safe to call more
+// than once.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+	}
+	w.mu.Unlock()
+
+	<-w.stopped
+	return nil
+}
+
+func (w *Writer) run() {
+	defer close(w.stopped)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	// Once done is closed it's set to nil and flushBy is set, after which
+	// pending events are sent until the queue is empty or flushBy passes.
+	done := w.done
+	var flushBy time.Time
+	var deadline <-chan time.Time
+	stop := func() {
+		done = nil
+		flushBy = time.Now().Add(w.flushTimeout)
+		deadline = time.After(w.flushTimeout)
+	}
+
+	// timeout returns how long a dial or write may take, which is capped by
+	// the time left to flush after Close, reporting false once it's up.
+	timeout := func() (time.Duration, bool) {
+		select {
+		case <-done:
+			stop()
+		default:
+		}
+
+		if flushBy.IsZero() {
+			return w.maxBackoff, true
+		}
+
+		left := time.Until(flushBy)
+		return min(left, w.maxBackoff), left > 0
+	}
+
+	// sleep waits out the backoff after a failed dial or write, doubling it
+	// for the next failure, and reports false if Close gives up meanwhile.
+	backoff := w.minBackoff
+	sleep := func() bool {
+		wait := time.NewTimer(backoff)
+		defer wait.Stop()
+		backoff = min(backoff*2, w.maxBackoff)
+
+		for {
+			select {
+			case <-wait.C:
+				return true
+			case <-deadline:
+				return false
+			case <-done:
+				stop()
+			}
+		}
+	}
+
+	for {
+		var event []byte
+		if done == nil {
+			select {
+			case event = <-w.pending:
+			default:
+				return
+			}
+		} else {
+			select {
+			case event = <-w.pending:
+			case <-done:
+				stop()
+				continue
+			}
+		}
+
+		// Keep trying this event until it's sent or Close gives up.
+		for {
+			limit, ok := timeout()
+			if !ok {
+				return
+			}
+
+			if conn == nil {
+				var err error
+				conn, err = w.dial(w.network, w.address, limit)
+				if err != nil {
+					conn = nil
+					if !sleep() {
+						return
+					}
+					continue
+				}
+			}
+
+			_ = conn.SetWriteDeadline(time.Now().Add(limit))
+			if _, err := conn.Write(event); err != nil {
+				// Peers that accept connections and then reset them get the
+				// same backoff as ones that refuse them.
+				conn.Close()
+				conn = nil
+				if !sleep() {
+					return
+				}
+				continue
+			}
+
+			backoff = w.minBackoff
+			break
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package fluentwriter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/prettylog"
+	"github.com/stretchr/testify/require"
+)
+
+type eventTime struct {
+	sec, nsec uint32
+}
+
+// decode reads a single msgpack value from r, supporting what encoder writes.
+func decode(r *bufio.Reader) (any, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil
+		}
+		return b
+	}
+	length := func(size int) int {
+		b := read(size)
+		switch size {
+		case 1:
+			return int(b[0])
+		case 2:
+			return int(binary.BigEndian.Uint16(b))
+		default:
+			return int(binary.BigEndian.Uint32(b))
+		}
+	}
+	array := func(n int) (any, error) {
+		values := make([]any, n)
+		for i := range values {
+			if values[i], err = decode(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	object := func(n int) (any, error) {
+		values := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			key, err := decode(r)
+			if err != nil {
+				return nil, err
+			}
+			if values[key.(string)], err = decode(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	switch {
+	case c < 0x80:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return object(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return array(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return string(read(int(c & 0x1f))), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		return read(length(1 << (c - 0xc4))), err
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(read(8))), err
+	case 0xce:
+		return int64(binary.BigEndian.Uint32(read(4))), err
+	case 0xcf:
+		return binary.BigEndian.Uint64(read(8)), err
+	case 0xd3:
+		return int64(binary.BigEndian.Uint64(read(8))), err
+	case 0xd7:
+		b := read(9)
+		return eventTime{binary.BigEndian.Uint32(b[1:5]), binary.BigEndian.Uint32(b[5:9])}, err
+	case 0xd9, 0xda, 0xdb:
+		return string(read(length(1 << (c - 0xd9)))), err
+	case 0xdc, 0xdd:
+		return array(length(2 << (c - 0xdc)))
+	case 0xde, 0xdf:
+		return object(length(2 << (c - 0xde)))
+	}
+
+	return nil, fmt.Errorf("unsupported msgpack byte %#x", c)
+}
+
+// serve accepts a single connection on l and sends each decoded event.
+func serve(t *testing.T, l net.Listener) <-chan []any {
+	t.Helper()
+
+	events := make(chan []any, 100)
+	go func() {
+		defer close(events)
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			event, err := decode(r)
+			if err != nil {
+				return
+			}
+			events <- event.([]any)
+		}
+	}()
+
+	return events
+}
+
+func receive(t *testing.T, events <-chan []any) []any {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return nil
+	}
+}
+
+func TestWriteRecord(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	events := serve(t, l)
+
+	w := New("tcp", l.Addr().String(), &Options{Tag: "app.access"})
+	defer w.Close()
+
+	logger := slog.New(easyslog.New(w, prettylog.Formatter{}, nil))
+	logger.With("service", "api").WithGroup("http").Warn("slow",
+		"status", 200,
+		"ratio", 0.5,
+		"ok", true,
+		"user", slog.GroupValue(slog.Int("id", 42), slog.String("name", "fox")),
+		slog.Group("header", "host", "example.com"),
+	)
+
+	event := receive(t, events)
+	require.Equal(t, "app.access", event[0])
+
+	ts := event[1].(eventTime)
+	require.WithinDuration(t, time.Now(), time.Unix(int64(ts.sec), int64(ts.nsec)), 5*time.Second)
+
+	require.Equal(t, map[string]any{
+		"level":   "WARN",
+		"msg":     "slow",
+		"service": "api",
+		"http": map[string]any{
+			"status": int64(200),
+			"ratio":  0.5,
+			"ok":     true,
+			"user":   map[string]any{"id": int64(42), "name": "fox"},
+			"header": map[string]any{"host": "example.com"},
+		},
+	}, event[2])
+}
+
+func TestWriteLines(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	events := serve(t, l)
+
+	w := New("tcp", l.Addr().String(), nil)
+	defer w.Close()
+
+	// io.MultiWriter hides WriteRecord, so the formatted line is sent
+	logger := slog.New(easyslog.New(io.MultiWriter(w), prettylog.Formatter{NoColor: true}, nil))
+	logger.Info("hello", "a", 1)
+
+	event := receive(t, events)
+	require.Equal(t, "easyslog", event[0])
+	require.Equal(t, map[string]any{"message": "[INF] hello a=1 "}, event[2])
+}
+
+func TestReconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fluent.sock")
+	w := New("unix", path, &Options{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer w.Close()
+
+	logger := slog.New(easyslog.New(w, prettylog.Formatter{}, nil))
+	for i := 0; i < 3; i++ {
+		logger.Info("buffered", "n", i)
+	}
+
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+	events := serve(t, l)
+
+	for i := 0; i < 3; i++ {
+		event := receive(t, events)
+		require.Equal(t, int64(i), event[2].(map[string]any)["n"])
+	}
+}
+
+func TestBufferFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sock")
+	w := New("unix", path, &Options{MaxPending: 1, FlushTimeout: time.Millisecond})
+
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		_, err = w.Write([]byte("line\n"))
+	}
+	require.ErrorIs(t, err, ErrBufferFull)
+
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+
+	_, err = w.Write([]byte("line\n"))
+	require.ErrorIs(t, err, ErrClosed)
+	require.True(t, errors.Is(w.WriteRecord(easyslog.Record{}), ErrClosed))
+}
+
+func TestCloseFlushes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	events := serve(t, l)
+
+	w := New("tcp", l.Addr().String(), nil)
+	for i := 0; i < 10; i++ {
+		_, _ = w.Write([]byte("line\n"))
+	}
+	require.NoError(t, w.Close())
+
+	count := 0
+	for range events {
+		count++
+	}
+	require.Equal(t, 10, count)
+}
+
+func TestResetConnectionsBackOff(t *testing.T) {
+	// Every connection succeeds but is reset before the first write
+	var dials atomic.Int64
+	dial := func(network, address string, timeout time.Duration) (net.Conn, error) {
+		dials.Add(1)
+		conn, peer := net.Pipe()
+		peer.Close()
+		return conn, nil
+	}
+
+	w := newWithDialer("tcp", "fluentd:24224", &Options{MinBackoff: 20 * time.Millisecond, MaxBackoff: 40 * time.Millisecond, FlushTimeout: 200 * time.Millisecond}, dial)
+	_, err := w.Write([]byte("line\n"))
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	// Close gives up on the event once the flush deadline passes
+	start := time.Now()
+	require.NoError(t, w.Close())
+	require.Less(t, time.Since(start), time.Second)
+
+	// Failed writes wait out the backoff before redialing
+	require.LessOrEqual(t, dials.Load(), int64(12))
+}
@@ -0,0 +1,178 @@
+package fluentwriter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// encoder appends the subset of msgpack needed for Forward protocol events.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) arrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.b = append(e.b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.b = append(e.b, 0xdc)
+		e.b = binary.BigEndian.AppendUint16(e.b, uint16(n))
+	default:
+		e.b = append(e.b, 0xdd)
+		e.b = binary.BigEndian.AppendUint32(e.b, uint32(n))
+	}
+}
+
+func (e *encoder) mapHeader(n int) {
+	switch {
+	case n < 16:
+		e.b = append(e.b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.b = append(e.b, 0xde)
+		e.b = binary.BigEndian.AppendUint16(e.b, uint16(n))
+	default:
+		e.b = append(e.b, 0xdf)
+		e.b = binary.BigEndian.AppendUint32(e.b, uint32(n))
+	}
+}
+
+func (e *encoder) str(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.b = append(e.b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.b = append(e.b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.b = append(e.b, 0xda)
+		e.b = binary.BigEndian.AppendUint16(e.b, uint16(n))
+	default:
+		e.b = append(e.b, 0xdb)
+		e.b = binary.BigEndian.AppendUint32(e.b, uint32(n))
+	}
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) bin(p []byte) {
+	n := len(p)
+	switch {
+	case n <= math.MaxUint8:
+		e.b = append(e.b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.b = append(e.b, 0xc5)
+		e.b = binary.BigEndian.AppendUint16(e.b, uint16(n))
+	default:
+		e.b = append(e.b, 0xc6)
+		e.b = binary.BigEndian.AppendUint32(e.b, uint32(n))
+	}
+	e.b = append(e.b, p...)
+}
+
+func (e *encoder) int(i int64) {
+	if i >= 0 {
+		e.uint(uint64(i))
+		return
+	}
+
+	if i >= -32 {
+		e.b = append(e.b, byte(i))
+		return
+	}
+
+	e.b = append(e.b, 0xd3)
+	e.b = binary.BigEndian.AppendUint64(e.b, uint64(i))
+}
+
+func (e *encoder) uint(u uint64) {
+	switch {
+	case u < 128:
+		e.b = append(e.b, byte(u))
+	case u <= math.MaxUint32:
+		e.b = append(e.b, 0xce)
+		e.b = binary.BigEndian.AppendUint32(e.b, uint32(u))
+	default:
+		e.b = append(e.b, 0xcf)
+		e.b = binary.BigEndian.AppendUint64(e.b, u)
+	}
+}
+
+func (e *encoder) float(f float64) {
+	e.b = append(e.b, 0xcb)
+	e.b = binary.BigEndian.AppendUint64(e.b, math.Float64bits(f))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.b = append(e.b, 0xc3)
+	} else {
+		e.b = append(e.b, 0xc2)
+	}
+}
+
+func (e *encoder) nil() {
+	e.b = append(e.b, 0xc0)
+}
+
+// eventTime writes t as the Forward protocol EventTime extension, type 0 with
+// big-endian seconds and nanoseconds.
+func (e *encoder) eventTime(t time.Time) {
+	e.b = append(e.b, 0xd7, 0x00)
+	e.b = binary.BigEndian.AppendUint32(e.b, uint32(t.Unix()))
+	e.b = binary.BigEndian.AppendUint32(e.b, uint32(t.Nanosecond()))
+}
+
+func (e *encoder) attrs(attrs []*easyslog.Attr) {
+	e.mapHeader(len(attrs))
+	for _, attr := range attrs {
+		e.str(attr.Key)
+		if attr.IsGroup() {
+			e.attrs(attr.Children)
+		} else {
+			e.value(attr.Value)
+		}
+	}
+}
+
+func (e *encoder) value(v slog.Value) {
+	v = v.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		e.str(v.String())
+	case slog.KindInt64:
+		e.int(v.Int64())
+	case slog.KindUint64:
+		e.uint(v.Uint64())
+	case slog.KindFloat64:
+		e.float(v.Float64())
+	case slog.KindBool:
+		e.bool(v.Bool())
+	case slog.KindDuration:
+		e.int(int64(v.Duration()))
+	case slog.KindTime:
+		e.str(v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		group := v.Group()
+		e.mapHeader(len(group))
+		for _, attr := range group {
+			e.str(attr.Key)
+			e.value(attr.Value)
+		}
+	default:
+		switch value := v.Any().(type) {
+		case nil:
+			e.nil()
+		case []byte:
+			e.bin(value)
+		case error:
+			e.str(value.Error())
+		default:
+			e.str(fmt.Sprint(value))
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package easyslog_test
+
+import (
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	_ "github.com/blakewilliams/easyslog/alignedlog"
+	_ "github.com/blakewilliams/easyslog/canonicalformat"
+	_ "github.com/blakewilliams/easyslog/csvformat"
+	_ "github.com/blakewilliams/easyslog/ecsformat"
+	_ "github.com/blakewilliams/easyslog/emfformat"
+	_ "github.com/blakewilliams/easyslog/gelf"
+	_ "github.com/blakewilliams/easyslog/htmlformat"
+	_ "github.com/blakewilliams/easyslog/journaldlog"
+	_ "github.com/blakewilliams/easyslog/jsonlog"
+	_ "github.com/blakewilliams/easyslog/logfmt"
+	_ "github.com/blakewilliams/easyslog/lokiformat"
+	_ "github.com/blakewilliams/easyslog/prettylog"
+	_ "github.com/blakewilliams/easyslog/syslog"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuiltinFormatters resolves every name documented on RegisterFormatter.
+func TestBuiltinFormatters(t *testing.T) {
+	for _, name := range []string{
+		"json", "pretty", "logfmt", "text", "aligned", "canonical", "csv",
+		"ecs", "emf", "gelf", "html", "journald", "loki", "syslog",
+	} {
+		formatter, err := easyslog.NewFormatter(name, nil)
+		require.NoError(t, err, name)
+		require.NotNil(t, formatter, name)
+	}
+}
@@ -0,0 +1,144 @@
+// Package gelf implements an easyslog.Formatter that renders records as GELF
+// 1.1 JSON messages for Graylog.
+package gelf
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// Version is the GELF specification version written to each message.
+const Version = "1.1"
+
+// Formatter implements easyslog.Formatter and renders each record as a GELF
+// message with `version`, `host`, `short_message`, `timestamp`, and `level`,
+// followed by the record's attributes as additional fields. Group keys are
+// joined with underscores, e.g. `_request_method`.
+type Formatter struct {
+	// Host is the name of the host sending the message. Defaults to
+	// os.Hostname.
+	Host string
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("gelf", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+var hostname = sync.OnceValue(func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+
+	return host
+})
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	host := f.Host
+	if host == "" {
+		host = hostname()
+	}
+
+	message := make(map[string]any, len(record.Attrs)+5)
+	message["version"] = Version
+	message["host"] = host
+	message["short_message"] = record.Message
+	message["level"] = Level(record.Level)
+
+	if !record.Time.IsZero() {
+		message["timestamp"] = float64(record.Time.UnixMilli()) / 1000
+	}
+
+	for _, attr := range record.Attrs {
+		writeAttr(message, attr, "")
+	}
+
+	toWrite, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(toWrite)
+	return err
+}
+
+// Level maps a slog level to a syslog severity, see
+// easyslog.SyslogSeverity.
+func Level(level slog.Level) int {
+	return easyslog.SyslogSeverity(level)
+}
+
+func writeAttr(dst map[string]any, attr *easyslog.Attr, prefix string) {
+	name := attr.Key
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+
+	if attr.IsGroup() {
+		for _, child := range attr.Children {
+			writeAttr(dst, child, name)
+		}
+		return
+	}
+
+	dst[FieldName(name)] = value(attr.Value)
+}
+
+// FieldName converts key into a valid GELF additional field name: it's
+// prefixed with an underscore and characters outside [A-Za-z0-9_.-] become
+// underscores. `_id`, which is reserved, becomes `__id`.
+func FieldName(key string) string {
+	var b strings.Builder
+	b.Grow(len(key) + 1)
+	b.WriteByte('_')
+
+	for _, r := range key {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.' || r == '-' {
+			b.WriteRune(r)
+			continue
+		}
+
+		b.WriteByte('_')
+	}
+
+	name := b.String()
+	if name == "_id" {
+		return "__id"
+	}
+
+	return name
+}
+
+// value returns v as a number or string, the only types GELF allows.
+func value(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindDuration:
+		return v.Duration().Seconds()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	default:
+		return v.String()
+	}
+}
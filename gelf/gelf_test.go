@@ -0,0 +1,71 @@
+package gelf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{Host: "web-1"}, nil)
+
+	r := slog.NewRecord(time.UnixMilli(1700000000123), slog.LevelError, "failed", 0)
+	r.AddAttrs(
+		slog.Int("status", 500),
+		slog.Bool("retry", true),
+		slog.Duration("took", 1500*time.Millisecond),
+		slog.Any("err", errors.New("boom")),
+		slog.String("id", "abc"),
+		slog.String("user name", "fox"),
+		slog.Group("request", slog.String("method", "GET"), slog.Group("header", slog.String("x", "y"))),
+	)
+	require.NoError(t, handler.Handle(context.Background(), r))
+
+	var message map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &message))
+
+	require.Equal(t, map[string]any{
+		"version":           "1.1",
+		"host":              "web-1",
+		"short_message":     "failed",
+		"timestamp":         1700000000.123,
+		"level":             float64(3),
+		"_status":           float64(500),
+		"_retry":            "true",
+		"_took":             1.5,
+		"_err":              "boom",
+		"__id":              "abc",
+		"_user_name":        "fox",
+		"_request_method":   "GET",
+		"_request_header_x": "y",
+	}, message)
+}
+
+func TestDefaultHost(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{}, nil)).Info("hi")
+
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	var message map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &message))
+	require.Equal(t, host, message["host"])
+	require.Equal(t, float64(6), message["level"])
+}
+
+func TestLevel(t *testing.T) {
+	require.Equal(t, 7, Level(slog.LevelDebug))
+	require.Equal(t, 6, Level(slog.LevelInfo+2))
+	require.Equal(t, 4, Level(slog.LevelWarn))
+	require.Equal(t, 3, Level(slog.LevelError+4))
+}
@@ -0,0 +1,160 @@
+// Package htmlformat implements an easyslog.Formatter that renders each record
+// as an HTML fragment, for embedding logs in debug pages.
+package htmlformat
+
+import (
+	"html"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// Formatter implements easyslog.Formatter and renders each record as a
+// `<div class="log log-info">` element. Attributes are rendered as
+// `<span class="attr"><span class="key">k</span>=<span class="val">v</span></span>`
+// and groups as nested `<details>` elements with the group name as the
+// summary. Every key, value, and message is HTML escaped.
+type Formatter struct {
+	// TimeFormat is the layout used for the record time. Defaults to
+	// time.RFC3339. Times are rendered in their own location, see
+	// easyslog.Options.TimeZone to pick one.
+	TimeFormat string
+	// LevelNames overrides the rendered level names. Levels not in the map
+	// render as Record.LevelString().
+	LevelNames easyslog.LevelNamer
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("html", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	var b strings.Builder
+
+	b.WriteString(`<div class="log `)
+	b.WriteString(LevelClass(record.Level))
+	b.WriteString(`">`)
+
+	if !record.Time.IsZero() {
+		timeFormat := f.TimeFormat
+		if timeFormat == "" {
+			timeFormat = time.RFC3339
+		}
+
+		b.WriteString(`<time datetime="`)
+		b.WriteString(record.Time.Format(time.RFC3339Nano))
+		b.WriteString(`">`)
+		b.WriteString(html.EscapeString(record.Time.Format(timeFormat)))
+		b.WriteString(`</time> `)
+	}
+
+	b.WriteString(`<span class="level">`)
+	b.WriteString(html.EscapeString(f.LevelNames.RecordName(record)))
+	b.WriteString(`</span> <span class="msg">`)
+	b.WriteString(html.EscapeString(record.Message))
+	b.WriteString(`</span>`)
+
+	for _, attr := range record.Attrs {
+		b.WriteByte(' ')
+		writeAttr(&b, attr)
+	}
+
+	b.WriteString(`</div>`)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeAttr(b *strings.Builder, attr *easyslog.Attr) {
+	if attr.IsGroup() {
+		b.WriteString(`<details class="group" open><summary>`)
+		b.WriteString(html.EscapeString(attr.Key))
+		b.WriteString(`</summary>`)
+		for i, child := range attr.Children {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeAttr(b, child)
+		}
+		b.WriteString(`</details>`)
+		return
+	}
+
+	b.WriteString(`<span class="attr"><span class="key">`)
+	b.WriteString(html.EscapeString(attr.Key))
+	b.WriteString(`</span>=<span class="val">`)
+	b.WriteString(html.EscapeString(attr.Value.String()))
+	b.WriteString(`</span></span>`)
+}
+
+// LevelClass returns the CSS class for level: `log-debug`, `log-info`,
+// `log-warn`, or `log-error`. Levels between the standard ones use the class of
+// the nearest standard level below them.
+func LevelClass(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "log-debug"
+	case level < slog.LevelWarn:
+		return "log-info"
+	case level < slog.LevelError:
+		return "log-warn"
+	default:
+		return "log-error"
+	}
+}
+
+// PageHeader returns the start of a self-contained HTML page, including a
+// stylesheet for the classes used by Formatter, that log lines can be written
+// into. Close it with PageFooter.
+func PageHeader() string {
+	return pageHeader
+}
+
+// PageFooter returns the end of the page started by PageHeader.
+func PageFooter() string {
+	return pageFooter
+}
+
+const pageHeader = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Logs</title>
+<style>
+body { font-family: ui-monospace, monospace; font-size: 13px; margin: 1em; }
+.log { padding: 2px 4px; border-left: 3px solid transparent; white-space: pre-wrap; }
+.log time { color: #888; }
+.log .level { font-weight: bold; }
+.log .key { color: #0a6e8a; }
+.log details.group { display: inline-block; vertical-align: top; margin-left: 1em; }
+.log details.group summary { color: #0a6e8a; cursor: pointer; }
+.log-debug { border-color: #4caf50; }
+.log-debug .level { color: #4caf50; }
+.log-info { border-color: #2196f3; }
+.log-info .level { color: #2196f3; }
+.log-warn { border-color: #ff9800; background: #fff8e1; }
+.log-warn .level { color: #ff9800; }
+.log-error { border-color: #f44336; background: #ffebee; }
+.log-error .level { color: #f44336; }
+</style>
+</head>
+<body>
+<main class="logs">
+`
+
+const pageFooter = `</main>
+</body>
+</html>
+`
@@ -0,0 +1,107 @@
+package htmlformat
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+var tagPattern = regexp.MustCompile(`</?([a-z]+)[^>]*>`)
+
+// requireBalanced asserts every tag in s is closed in the right order, ignoring
+// void elements.
+func requireBalanced(t *testing.T, s string) {
+	t.Helper()
+
+	var stack []string
+	for _, match := range tagPattern.FindAllStringSubmatch(s, -1) {
+		tag, name := match[0], match[1]
+		switch {
+		case name == "meta" || strings.HasPrefix(tag, "<!"):
+			continue
+		case strings.HasPrefix(tag, "</"):
+			require.NotEmpty(t, stack, "unexpected %s", tag)
+			require.Equal(t, stack[len(stack)-1], name, "mismatched %s", tag)
+			stack = stack[:len(stack)-1]
+		default:
+			stack = append(stack, name)
+		}
+	}
+
+	require.Empty(t, stack)
+}
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := slog.NewRecord(ts, slog.LevelWarn, "slow", 0)
+	r.AddAttrs(slog.Int("ms", 250))
+	require.NoError(t, l.Handler().Handle(context.Background(), r))
+
+	require.Equal(t, `<div class="log log-warn"><time datetime="2024-01-02T03:04:05Z">2024-01-02T03:04:05Z</time> `+
+		`<span class="level">WARN</span> <span class="msg">slow</span> `+
+		`<span class="attr"><span class="key">ms</span>=<span class="val">250</span></span></div>`+"\n", buf.String())
+}
+
+func TestEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Error(`<script>alert("msg")</script>`,
+		"<b>", `<script>alert('val')</script>`,
+		slog.Group("<img src=x onerror=alert(1)>", "k", "a&b"),
+	)
+
+	out := buf.String()
+	require.NotContains(t, out, "<script>")
+	require.NotContains(t, out, "<b>")
+	require.NotContains(t, out, "<img")
+	require.Contains(t, out, `<span class="msg">&lt;script&gt;alert(&#34;msg&#34;)&lt;/script&gt;</span>`)
+	require.Contains(t, out, `<span class="key">&lt;b&gt;</span>=<span class="val">&lt;script&gt;alert(&#39;val&#39;)&lt;/script&gt;</span>`)
+	require.Contains(t, out, `<summary>&lt;img src=x onerror=alert(1)&gt;</summary>`)
+	require.Contains(t, out, `<span class="val">a&amp;b</span>`)
+	requireBalanced(t, out)
+}
+
+func TestNestedGroups(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.WithGroup("http").Info("request", "method", "GET", slog.Group("user", "id", 1, slog.Group("org", "id", 2)))
+
+	out := buf.String()
+	require.Equal(t, 3, strings.Count(out, "<details"))
+	require.Contains(t, out, `<summary>user</summary><span class="attr"><span class="key">id</span>=<span class="val">1</span></span> `+
+		`<details class="group" open><summary>org</summary>`)
+	requireBalanced(t, out)
+}
+
+func TestLevelClass(t *testing.T) {
+	require.Equal(t, "log-debug", LevelClass(slog.LevelDebug))
+	require.Equal(t, "log-info", LevelClass(slog.LevelInfo+2))
+	require.Equal(t, "log-warn", LevelClass(slog.LevelWarn))
+	require.Equal(t, "log-error", LevelClass(slog.LevelError+4))
+}
+
+func TestPage(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(PageHeader())
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+	l.Info("one", slog.Group("g", "a", 1))
+	l.Error("two")
+	buf.WriteString(PageFooter())
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "<!DOCTYPE html>"))
+	require.Contains(t, out, ".log-error")
+	requireBalanced(t, out)
+}
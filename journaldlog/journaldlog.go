@@ -0,0 +1,168 @@
+// Package journaldlog implements an easyslog.Formatter that emits systemd
+// journald's native protocol, and a Writer that sends each record to the
+// journal socket as a datagram.
+package journaldlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// Formatter implements easyslog.Formatter and renders records as journald
+// native protocol fields. PRIORITY is derived from the level, MESSAGE from the
+// message, CODE_FILE/CODE_LINE/CODE_FUNC from the PC when present, SYSLOG_PID
+// from Record.PID when easyslog.Options.IncludePID is set, and every
+// attribute becomes a field named after its uppercased path, e.g.
+// `REQUEST_METHOD`. Attributes named like one of those fields are prefixed
+// with `X_`, e.g. `X_MESSAGE`, since journald keeps every value of a repeated
+// field.
+//
+// The final field is left unterminated since the handler appends the trailing
+// newline.
+type Formatter struct {
+	// SyslogIdentifier, when set, is emitted as SYSLOG_IDENTIFIER.
+	SyslogIdentifier string
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("journald", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	var buf bytes.Buffer
+
+	writeField(&buf, "PRIORITY", strconv.Itoa(Priority(record.Level)))
+
+	if f.SyslogIdentifier != "" {
+		writeField(&buf, "SYSLOG_IDENTIFIER", f.SyslogIdentifier)
+	}
+
+	if record.PID != 0 {
+		writeField(&buf, "SYSLOG_PID", strconv.Itoa(record.PID))
+	}
+
+	if record.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{record.PC})
+		frame, _ := frames.Next()
+
+		writeField(&buf, "CODE_FILE", frame.File)
+		writeField(&buf, "CODE_LINE", strconv.Itoa(frame.Line))
+		writeField(&buf, "CODE_FUNC", frame.Function)
+	}
+
+	for _, attr := range record.Attrs {
+		// Already written as SYSLOG_PID
+		if record.PID != 0 && attr.Key == easyslog.PIDKey && !attr.IsGroup() {
+			continue
+		}
+
+		writeAttr(&buf, attr, "")
+	}
+
+	writeField(&buf, "MESSAGE", record.Message)
+
+	// Drop the last terminator, Handle adds it back
+	buf.Truncate(buf.Len() - 1)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Priority maps a slog level to a syslog priority, see
+// easyslog.SyslogSeverity.
+func Priority(level slog.Level) int {
+	return easyslog.SyslogSeverity(level)
+}
+
+func writeAttr(buf *bytes.Buffer, attr *easyslog.Attr, prefix string) {
+	name := attr.Key
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+
+	if attr.IsGroup() {
+		for _, child := range attr.Children {
+			writeAttr(buf, child, name)
+		}
+		return
+	}
+
+	name = FieldName(name)
+	if formatterFields[name] {
+		name = "X_" + name
+	}
+
+	writeField(buf, name, attr.Value.String())
+}
+
+// formatterFields are the fields Format writes itself, which attributes may
+// not use.
+var formatterFields = map[string]bool{
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"SYSLOG_PID":        true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+	"MESSAGE":           true,
+}
+
+// writeField writes a single terminated field. Values containing a newline use
+// the binary-safe form: the name, a newline, the little-endian 64-bit length,
+// and the raw value.
+func writeField(buf *bytes.Buffer, name string, value string) {
+	buf.WriteString(name)
+
+	if strings.IndexByte(value, '\n') == -1 {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// FieldName converts key into a valid journald field name: it's uppercased,
+// characters outside [A-Z0-9_] become underscores, and names that don't start
+// with a letter are prefixed with `X_` since leading underscores are reserved
+// for trusted fields.
+func FieldName(key string) string {
+	var b strings.Builder
+	b.Grow(len(key) + 2)
+
+	for _, r := range strings.ToUpper(key) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+			continue
+		}
+
+		b.WriteByte('_')
+	}
+
+	name := b.String()
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		name = "X_" + name
+	}
+
+	return name
+}
@@ -0,0 +1,170 @@
+package journaldlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+// decode parses a single native protocol entry
+func decode(t *testing.T, b []byte) map[string]string {
+	fields := make(map[string]string)
+
+	for len(b) > 0 {
+		nameEnd := bytes.IndexAny(b, "=\n")
+		require.NotEqual(t, -1, nameEnd, "unterminated field name")
+		name := string(b[:nameEnd])
+
+		if b[nameEnd] == '=' {
+			valueEnd := bytes.IndexByte(b[nameEnd:], '\n')
+			require.NotEqual(t, -1, valueEnd, "unterminated field %s", name)
+
+			fields[name] = string(b[nameEnd+1 : nameEnd+valueEnd])
+			b = b[nameEnd+valueEnd+1:]
+			continue
+		}
+
+		b = b[nameEnd+1:]
+		size := binary.LittleEndian.Uint64(b[:8])
+		b = b[8:]
+
+		fields[name] = string(b[:size])
+		require.Equal(t, byte('\n'), b[size], "binary field %s must be terminated", name)
+		b = b[size+1:]
+	}
+
+	return fields
+}
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{SyslogIdentifier: "api"}, nil)
+	l := slog.New(handler)
+
+	l.WithGroup("request").Warn("slow request", "method", "GET", "duration-ms", 1200)
+
+	fields := decode(t, buf.Bytes())
+	require.Equal(t, "4", fields["PRIORITY"])
+	require.Equal(t, "api", fields["SYSLOG_IDENTIFIER"])
+	require.Equal(t, "slow request", fields["MESSAGE"])
+	require.Equal(t, "GET", fields["REQUEST_METHOD"])
+	require.Equal(t, "1200", fields["REQUEST_DURATION_MS"])
+	require.True(t, strings.HasSuffix(fields["CODE_FILE"], "journaldlog_test.go"))
+	require.NotEmpty(t, fields["CODE_LINE"])
+	require.Contains(t, fields["CODE_FUNC"], "TestFormat")
+}
+
+func TestSyslogPID(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{}, &easyslog.Options{IncludePID: true})).Info("msg")
+
+	fields := decode(t, buf.Bytes())
+	require.Equal(t, strconv.Itoa(os.Getpid()), fields["SYSLOG_PID"])
+	require.NotContains(t, fields, "PID")
+
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{}, nil)).Info("msg")
+	require.NotContains(t, decode(t, buf.Bytes()), "SYSLOG_PID")
+}
+
+func TestBinarySafeValues(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Error("line one\nline two", "trace", "a\nb\n")
+
+	fields := decode(t, buf.Bytes())
+	require.Equal(t, "3", fields["PRIORITY"])
+	require.Equal(t, "line one\nline two", fields["MESSAGE"])
+	require.Equal(t, "a\nb\n", fields["TRACE"])
+}
+
+func TestFormatterFieldCollisions(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{SyslogIdentifier: "api"}, nil)).Warn("real",
+		"message", "fake", "priority", 0, "code_file", "fake.go", "syslog_identifier", "fake", slog.Group("syslog", "pid", 1),
+	)
+
+	fields := decode(t, buf.Bytes())
+	require.Equal(t, "real", fields["MESSAGE"])
+	require.Equal(t, "4", fields["PRIORITY"])
+	require.Equal(t, "api", fields["SYSLOG_IDENTIFIER"])
+	require.True(t, strings.HasSuffix(fields["CODE_FILE"], "journaldlog_test.go"))
+	require.Equal(t, "fake", fields["X_MESSAGE"])
+	require.Equal(t, "0", fields["X_PRIORITY"])
+	require.Equal(t, "fake.go", fields["X_CODE_FILE"])
+	require.Equal(t, "fake", fields["X_SYSLOG_IDENTIFIER"])
+	require.Equal(t, "1", fields["X_SYSLOG_PID"])
+
+	// Each field is written once
+	entry := append([]byte("\n"), buf.Bytes()...)
+	for _, name := range []string{"MESSAGE", "PRIORITY", "CODE_FILE", "SYSLOG_IDENTIFIER"} {
+		require.Equal(t, 1, bytes.Count(entry, []byte("\n"+name+"=")), name)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	require.Equal(t, 7, Priority(slog.LevelDebug-4))
+	require.Equal(t, 7, Priority(slog.LevelDebug))
+	require.Equal(t, 6, Priority(slog.LevelInfo))
+	require.Equal(t, 6, Priority(slog.LevelInfo+2))
+	require.Equal(t, 4, Priority(slog.LevelWarn))
+	require.Equal(t, 3, Priority(slog.LevelError))
+	require.Equal(t, 3, Priority(slog.LevelError+4))
+}
+
+func TestFieldName(t *testing.T) {
+	require.Equal(t, "USER_ID", FieldName("user_id"))
+	require.Equal(t, "HTTP_STATUS_CODE", FieldName("http.status-code"))
+	require.Equal(t, "X_1ST", FieldName("1st"))
+	require.Equal(t, "X__PRIVATE", FieldName("_private"))
+	require.Equal(t, "CAF_", FieldName("café"))
+	require.Equal(t, "X_", FieldName(""))
+}
+
+func TestWriterFallback(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(filepath.Join(t.TempDir(), "missing.socket"), &buf)
+	defer w.Close()
+
+	require.False(t, w.Connected())
+
+	l := slog.New(easyslog.New(w, Formatter{}, nil))
+	l.Info("hello")
+
+	require.Equal(t, "hello", decode(t, buf.Bytes())["MESSAGE"])
+}
+
+func TestWriterSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var fallback bytes.Buffer
+	w := NewWriter(path, &fallback)
+	defer w.Close()
+	require.True(t, w.Connected())
+
+	l := slog.New(easyslog.New(w, Formatter{}, nil))
+	l.Info("first", "k", "v")
+	l.Info("second\nline")
+
+	datagram := make([]byte, 4096)
+	for _, expected := range []string{"first", "second\nline"} {
+		n, err := conn.Read(datagram)
+		require.NoError(t, err)
+		require.Equal(t, expected, decode(t, datagram[:n])["MESSAGE"])
+	}
+
+	require.Zero(t, fallback.Len())
+}
@@ -0,0 +1,58 @@
+package journaldlog
+
+import (
+	"io"
+	"net"
+)
+
+// DefaultSocket is the path of journald's native protocol socket.
+const DefaultSocket = "/run/systemd/journal/socket"
+
+// Writer sends each Write to the journald socket as a single datagram. Since
+// Handle writes one record per call, each record becomes one journal entry. If
+// the socket couldn't be connected to, writes go to the fallback writer.
+type Writer struct {
+	conn     *net.UnixConn
+	fallback io.Writer
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter connects to the journald socket at path, or DefaultSocket if path
+// is empty. If the socket is absent writes are sent to fallback instead.
+func NewWriter(path string, fallback io.Writer) *Writer {
+	if path == "" {
+		path = DefaultSocket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return &Writer{fallback: fallback}
+	}
+
+	return &Writer{conn: conn, fallback: fallback}
+}
+
+// Connected returns true if writes are being sent to the journald socket.
+func (w *Writer) Connected() bool {
+	return w.conn != nil
+}
+
+// Write sends p as a single datagram, or writes it to the fallback writer when
+// not connected.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.conn == nil {
+		return w.fallback.Write(p)
+	}
+
+	return w.conn.Write(p)
+}
+
+// Close closes the socket connection, if any.
+func (w *Writer) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+
+	return w.conn.Close()
+}
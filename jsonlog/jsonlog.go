@@ -0,0 +1,400 @@
+// Package jsonlog implements an easyslog.Formatter that renders each record as
+// a single JSON object.
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// BytesEncoding determines how []byte values are rendered.
+type BytesEncoding int
+
+const (
+	// Base64 renders []byte values as standard base64 strings.
+	Base64 BytesEncoding = iota
+	// Hex renders []byte values as lowercase hex strings.
+	Hex
+)
+
+// DurationEncoding determines how time.Duration values are rendered.
+type DurationEncoding int
+
+const (
+	// Nanoseconds renders durations as integer nanoseconds, e.g. `1500000`.
+	Nanoseconds DurationEncoding = iota
+	// DurationString renders durations using time.Duration.String, e.g.
+	// `"1.5ms"`.
+	DurationString
+)
+
+// Formatter implements easyslog.Formatter and renders records as JSON objects
+// with `time`, `level`, and `msg` keys, and a `logger` key for records with a
+// HandlerName, followed by the record's attributes in order.
+//
+// The object is written directly from the attribute tree without building an
+// intermediate map, so it's the recommended formatter for production JSON
+// logs. Like slog.JSONHandler, duplicate keys are written as-is.
+type Formatter struct {
+	// BytesEncoding determines how []byte values are encoded. Defaults to
+	// Base64.
+	BytesEncoding BytesEncoding
+	// DurationEncoding determines how durations are encoded. Defaults to
+	// Nanoseconds. Times are always RFC 3339 strings with nanoseconds.
+	DurationEncoding DurationEncoding
+	// LevelNames overrides the rendered level names. Levels not in the map
+	// render as Record.LevelString().
+	LevelNames easyslog.LevelNamer
+	// ErrorChain renders error values as objects with the error message and
+	// its unwrapped causes, see easyslog.ErrorValue. By default errors render
+	// as their message.
+	ErrorChain bool
+	// StrictEncoding makes Format return the error of a value encoding/json
+	// can't encode, like a channel, a func or a cyclic struct, so the line
+	// isn't written. By default only that value is replaced, with a string
+	// like `"!ERROR(json: unsupported type: chan int)"`, and the rest of the
+	// line is written as usual.
+	StrictEncoding bool
+	// Keys renames the built-in time, level, message, and handler name keys.
+	// A key set to "" is omitted from the output. Defaults to DefaultKeys.
+	Keys *Keys
+}
+
+// Keys holds the names of the built-in keys written before the attributes.
+type Keys struct {
+	Time    string
+	Level   string
+	Message string
+	// Name holds Record.HandlerName, and is left out for records without
+	// one.
+	Name string
+}
+
+var defaultKeys = DefaultKeys()
+
+// DefaultKeys returns the standard slog key names, `time`, `level`, and `msg`,
+// along with `logger` for the handler name, as a starting point for renaming
+// or omitting some of them.
+func DefaultKeys() Keys {
+	return Keys{Time: slog.TimeKey, Level: slog.LevelKey, Message: slog.MessageKey, Name: DefaultNameKey}
+}
+
+// DefaultNameKey is the key DefaultKeys uses for Record.HandlerName.
+const DefaultNameKey = "logger"
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("json", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	bp := bufPool.Get().(*[]byte)
+	defer func() {
+		// Don't hold on to unusually large buffers.
+		if cap(*bp) <= 64*1024 {
+			bufPool.Put(bp)
+		}
+	}()
+
+	keys := f.Keys
+	if keys == nil {
+		keys = &defaultKeys
+	}
+
+	b := append((*bp)[:0], '{')
+
+	if keys.Time != "" && !record.Time.IsZero() {
+		b = appendKey(b, keys.Time)
+		b = append(b, '"')
+		b = record.Time.AppendFormat(b, time.RFC3339Nano)
+		b = append(b, '"')
+	}
+
+	if keys.Level != "" {
+		b = appendKey(b, keys.Level)
+		b = appendString(b, f.LevelNames.RecordName(record))
+	}
+
+	if keys.Message != "" {
+		b = appendKey(b, keys.Message)
+		b = appendString(b, record.Message)
+	}
+
+	if keys.Name != "" && record.HandlerName != "" {
+		b = appendKey(b, keys.Name)
+		b = appendString(b, record.HandlerName)
+	}
+
+	var err error
+	for _, attr := range record.Attrs {
+		if b[len(b)-1] != '{' {
+			b = append(b, ',')
+		}
+		if b, err = f.appendAttr(b, attr); err != nil {
+			return err
+		}
+	}
+
+	b = append(b, '}')
+	*bp = b
+
+	_, err = w.Write(b)
+	return err
+}
+
+// appendKey writes a top-level key, preceded by a comma unless it's the first
+// one in the object.
+func appendKey(b []byte, key string) []byte {
+	if b[len(b)-1] != '{' {
+		b = append(b, ',')
+	}
+	b = appendString(b, key)
+
+	return append(b, ':')
+}
+
+func (f Formatter) appendAttr(b []byte, attr *easyslog.Attr) ([]byte, error) {
+	b = appendString(b, attr.Key)
+	b = append(b, ':')
+
+	if data, ok := attr.RawJSON(); ok {
+		return appendRawJSON(b, data), nil
+	}
+
+	if !attr.IsGroup() {
+		return f.appendValue(b, attr.Value)
+	}
+
+	b = append(b, '{')
+	var err error
+	for i, child := range attr.Children {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if b, err = f.appendAttr(b, child); err != nil {
+			return b, err
+		}
+	}
+
+	return append(b, '}'), nil
+}
+
+func (f Formatter) appendValue(b []byte, v slog.Value) ([]byte, error) {
+	v = v.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		return appendString(b, v.String()), nil
+	case slog.KindInt64:
+		return strconv.AppendInt(b, v.Int64(), 10), nil
+	case slog.KindUint64:
+		return strconv.AppendUint(b, v.Uint64(), 10), nil
+	case slog.KindFloat64:
+		return appendFloat(b, v.Float64()), nil
+	case slog.KindBool:
+		return strconv.AppendBool(b, v.Bool()), nil
+	case slog.KindDuration:
+		if f.DurationEncoding == DurationString {
+			return appendString(b, v.Duration().String()), nil
+		}
+		return strconv.AppendInt(b, int64(v.Duration()), 10), nil
+	case slog.KindTime:
+		b = append(b, '"')
+		b = v.Time().AppendFormat(b, time.RFC3339Nano)
+		return append(b, '"'), nil
+	case slog.KindGroup:
+		b = append(b, '{')
+		var err error
+		for i, attr := range v.Group() {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendString(b, attr.Key)
+			b = append(b, ':')
+			if b, err = f.appendValue(b, attr.Value); err != nil {
+				return b, err
+			}
+		}
+		return append(b, '}'), nil
+	}
+
+	switch value := v.Any().(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case []byte:
+		if f.BytesEncoding == Hex {
+			return appendEncoded(b, hex.EncodedLen(len(value)), func(dst []byte) { hex.Encode(dst, value) }), nil
+		}
+		return appendEncoded(b, base64.StdEncoding.EncodedLen(len(value)), func(dst []byte) {
+			base64.StdEncoding.Encode(dst, value)
+		}), nil
+	case error:
+		if f.ErrorChain {
+			return f.appendValue(b, easyslog.ErrorValue(value))
+		}
+		return appendString(b, value.Error()), nil
+	default:
+		encoded, err := appendJSON(b, value)
+		if err != nil && !f.StrictEncoding {
+			return appendString(b, "!ERROR("+err.Error()+")"), nil
+		}
+		return encoded, err
+	}
+}
+
+// appendEncoded appends a quoted string of n bytes written by encode.
+func appendEncoded(b []byte, n int, encode func(dst []byte)) []byte {
+	b = append(b, '"')
+	b = slices.Grow(b, n+1)
+	encode(b[len(b) : len(b)+n])
+	b = b[:len(b)+n]
+
+	return append(b, '"')
+}
+
+// appendRawJSON embeds data verbatim if it's valid JSON, compacting it first if
+// it spans multiple lines, and as a quoted string otherwise.
+func appendRawJSON(b []byte, data []byte) []byte {
+	if !json.Valid(data) {
+		return appendString(b, string(data))
+	}
+
+	if bytes.ContainsAny(data, "\r\n") {
+		var buf bytes.Buffer
+		_ = json.Compact(&buf, data)
+		return append(b, buf.Bytes()...)
+	}
+
+	return append(b, data...)
+}
+
+// appendJSON falls back to encoding/json for arbitrary values, without HTML
+// escaping to match appendString. b is returned unchanged on error. Cycles
+// don't hang, since encoding/json reports them as errors once pointers are
+// nested deeply enough.
+func appendJSON(b []byte, value any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return b, err
+	}
+
+	return append(b, bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})...), nil
+}
+
+// appendFloat formats f the way encoding/json does. NaN and infinities, which
+// JSON can't represent, are written as the strings "NaN", "+Inf" and "-Inf".
+func appendFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(b, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(b, `"-Inf"`...)
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9, as encoding/json does.
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString writes s as a quoted JSON string. Invalid UTF-8 is replaced
+// with U+FFFD and, unlike encoding/json, HTML characters aren't escaped.
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+
+		// U+2028 and U+2029 are valid JSON but break JavaScript parsers.
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
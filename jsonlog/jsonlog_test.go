@@ -0,0 +1,546 @@
+package jsonlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func parseLines(t *testing.T, b []byte) []map[string]any {
+	var results []map[string]any
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		var result map[string]any
+		require.NoError(t, json.Unmarshal(line, &result))
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func TestSlogtest(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{}, &easyslog.Options{Level: slog.LevelDebug})
+
+	err := slogtest.TestHandler(handler, func() []map[string]any {
+		return parseLines(t, buf.Bytes())
+	})
+
+	require.NoError(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("hello", "n", 1, "ok", true, "err", errors.New("boom"), slog.Group("req", "path", "/"))
+
+	results := parseLines(t, buf.Bytes())
+	require.Len(t, results, 1)
+	delete(results[0], "time")
+
+	require.Equal(t, map[string]any{
+		"msg":   "hello",
+		"level": "INFO",
+		"n":     float64(1),
+		"ok":    true,
+		"err":   "boom",
+		"req":   map[string]any{"path": "/"},
+	}, results[0])
+}
+
+func TestBytesBase64(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("raw", "data", []byte{0xde, 0xad, 0xbe, 0xef})
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, "3q2+7w==", results[0]["data"])
+}
+
+func TestBytesHex(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{BytesEncoding: Hex}, nil))
+
+	l.Info("raw", slog.Group("payload", "data", []byte{0xde, 0xad, 0xbe, 0xef}))
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, map[string]any{"data": "deadbeef"}, results[0]["payload"])
+}
+
+func TestLevelNames(t *testing.T) {
+	var buf bytes.Buffer
+	names := easyslog.LevelNamer{slog.LevelInfo: "info", slog.LevelWarn: "warning"}
+	l := slog.New(easyslog.New(&buf, Formatter{LevelNames: names}, nil))
+
+	l.Info("a")
+	l.Warn("b")
+	l.Log(context.Background(), slog.LevelInfo+2, "c")
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, "info", results[0]["level"])
+	require.Equal(t, "warning", results[1]["level"])
+	require.Equal(t, "INFO+2", results[2]["level"])
+}
+
+func TestKeepEmptyGroups(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, &easyslog.Options{KeepEmptyGroups: true}))
+
+	l.WithGroup("context").Info("msg")
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, map[string]any{}, results[0]["context"])
+}
+
+type inlineValuer struct{}
+
+func (inlineValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("v", 4))
+}
+
+func TestInlineGroupsAtDepth(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("depth2", slog.Group("outer", slog.Group("", slog.Int("a", 1))))
+	l.Info("depth3", slog.Group("x", slog.Group("y", slog.Group("", slog.Int("b", 2)), "c", 3)))
+	l.Info("empty", slog.Group("x", slog.Group("", slog.Any("nil", nil))), "k", "v")
+	l.Info("valuer", slog.Group("x", slog.Any("", inlineValuer{})))
+
+	results := parseLines(t, buf.Bytes())
+	require.Len(t, results, 4)
+
+	require.Equal(t, map[string]any{"a": float64(1)}, results[0]["outer"])
+	require.Equal(t, map[string]any{"y": map[string]any{"b": float64(2), "c": float64(3)}}, results[1]["x"])
+	require.NotContains(t, results[2], "x")
+	require.NotContains(t, results[2], "")
+	require.Equal(t, "v", results[2]["k"])
+	require.Equal(t, map[string]any{"v": float64(4)}, results[3]["x"])
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestAllKinds(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	l.Info("kinds",
+		"s", "<a&b>",
+		"i", -3,
+		"u", uint64(math.MaxUint64),
+		"f", 1.5,
+		"small", 1e-7,
+		"nan", math.NaN(),
+		"inf", math.Inf(-1),
+		"b", false,
+		"d", time.Second,
+		"t", ts,
+		"p", point{1, 2},
+		"m", map[string]int{"z": 1},
+	)
+
+	line := buf.String()
+	require.Contains(t, line, `"s":"<a&b>"`)
+	require.Contains(t, line, `"u":18446744073709551615`)
+	require.Contains(t, line, `"small":1e-7`)
+	require.Contains(t, line, `"nan":"NaN","inf":"-Inf"`)
+	require.Contains(t, line, `"t":"2024-01-02T03:04:05.000000006Z"`)
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, float64(-3), results[0]["i"])
+	require.Equal(t, 1.5, results[0]["f"])
+	require.Equal(t, false, results[0]["b"])
+	require.Equal(t, float64(time.Second), results[0]["d"])
+	require.Equal(t, map[string]any{"X": float64(1), "Y": float64(2)}, results[0]["p"])
+	require.Equal(t, map[string]any{"z": float64(1)}, results[0]["m"])
+}
+
+func TestOrder(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.With("z", 1).WithGroup("g").Info("msg", "b", 2, "a", 3)
+
+	line := buf.String()
+	require.Regexp(t, `^\{"time":"[^"]+","level":"INFO","msg":"msg","z":1,"g":\{"b":2,"a":3\}\}\n$`, line)
+}
+
+func TestStringEscaping(t *testing.T) {
+	for _, s := range []string{
+		"plain",
+		`quote " and \ backslash`,
+		"new\nline\r\ttab",
+		"\x00\x01\x1f\x7f",
+		"日本語 ✓",
+		"bad \xff utf8",
+		"js \u2028\u2029",
+	} {
+		b := appendString(nil, s)
+		require.True(t, json.Valid(b), s)
+
+		var got string
+		require.NoError(t, json.Unmarshal(b, &got))
+		require.Equal(t, strings.ToValidUTF8(s, "\ufffd"), got)
+	}
+}
+
+// node is cyclic when next points back at it.
+type node struct {
+	Name string
+	Next *node
+}
+
+func TestUnsupportedValue(t *testing.T) {
+	cycle := &node{Name: "a"}
+	cycle.Next = cycle
+
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{}, nil)
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "bad", 0)
+	r.AddAttrs(
+		slog.String("before", "x"),
+		slog.Any("ch", make(chan int)),
+		slog.Group("g", slog.Any("fn", func() {}), slog.Int("n", 1)),
+		slog.Any("cycle", cycle),
+		slog.Float64("nan", math.NaN()),
+		slog.Float64("inf", math.Inf(1)),
+		slog.String("after", "y"),
+	)
+	require.NoError(t, handler.Handle(context.Background(), r))
+
+	line := parseLines(t, buf.Bytes())[0]
+	require.Equal(t, "x", line["before"])
+	require.Equal(t, "!ERROR(json: unsupported type: chan int)", line["ch"])
+	require.Equal(t, map[string]any{"fn": "!ERROR(json: unsupported type: func())", "n": float64(1)}, line["g"])
+	require.Equal(t, "!ERROR(json: unsupported value: encountered a cycle via *jsonlog.node)", line["cycle"])
+	require.Equal(t, "NaN", line["nan"])
+	require.Equal(t, "+Inf", line["inf"])
+	require.Equal(t, "y", line["after"])
+
+	buf.Reset()
+	err := easyslog.New(&buf, Formatter{StrictEncoding: true}, nil).Handle(context.Background(), r)
+	var unsupported *json.UnsupportedTypeError
+	require.ErrorAs(t, err, &unsupported)
+	require.Zero(t, buf.Len())
+}
+
+// mapFormatter is the map-based approach the streaming Formatter replaced,
+// kept to compare in benchmarks.
+type mapFormatter struct{}
+
+func (mapFormatter) Format(w io.Writer, record easyslog.Record) error {
+	result := map[string]any{
+		slog.TimeKey:    record.Time,
+		slog.LevelKey:   record.Level.String(),
+		slog.MessageKey: record.Message,
+	}
+	var add func(dst map[string]any, attr *easyslog.Attr)
+	add = func(dst map[string]any, attr *easyslog.Attr) {
+		if !attr.IsGroup() {
+			dst[attr.Key] = attr.Value.Any()
+			return
+		}
+		group := map[string]any{}
+		for _, child := range attr.Children {
+			add(group, child)
+		}
+		dst[attr.Key] = group
+	}
+	for _, attr := range record.Attrs {
+		add(result, attr)
+	}
+
+	return json.NewEncoder(w).Encode(result)
+}
+
+func benchmarkFormatter(b *testing.B, formatter easyslog.Formatter) {
+	l := slog.New(easyslog.New(io.Discard, formatter, nil)).With("service", "api").WithGroup("request")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("handled", "method", "GET", "path", "/users", "status", 200, "duration", time.Millisecond, slog.Group("user", "id", 42, "admin", false))
+	}
+}
+
+func BenchmarkFormatter(b *testing.B) {
+	benchmarkFormatter(b, Formatter{})
+}
+
+func BenchmarkMapFormatter(b *testing.B) {
+	benchmarkFormatter(b, mapFormatter{})
+}
+
+func TestTimeAndDuration(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 500, time.FixedZone("EST", -5*60*60))
+
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+	l.Info("msg", slog.Time("at", ts), slog.Duration("took", 1500*time.Microsecond))
+
+	require.Contains(t, buf.String(), `"at":"2024-05-06T07:08:09.0000005-05:00","took":1500000}`)
+
+	buf.Reset()
+	l = slog.New(easyslog.New(&buf, Formatter{DurationEncoding: DurationString}, nil))
+	l.Info("msg", slog.Group("req", slog.Time("at", ts.UTC()), slog.Duration("took", 1500*time.Microsecond)))
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, map[string]any{"at": "2024-05-06T12:08:09.0000005Z", "took": "1.5ms"}, results[0]["req"])
+}
+
+func TestKeys(t *testing.T) {
+	keys := DefaultKeys()
+	keys.Time = "ts"
+	keys.Level = ""
+
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{Keys: &keys}, nil))
+	l.Info("renamed", "a", 1)
+
+	results := parseLines(t, buf.Bytes())
+	require.Contains(t, results[0], "ts")
+	require.NotContains(t, results[0], "time")
+	require.NotContains(t, results[0], "level")
+	require.Equal(t, "renamed", results[0]["msg"])
+}
+
+func TestOmitAllKeys(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{Keys: &Keys{}}, nil))
+
+	l.Info("gone", "a", 1, slog.Group("g", "b", 2))
+	l.Info("gone")
+
+	require.Equal(t, `{"a":1,"g":{"b":2}}`+"\n{}\n", buf.String())
+}
+
+func TestErrorChain(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{ErrorChain: true}, nil))
+
+	err := fmt.Errorf("save: %w", errors.New("disk full"))
+	l.Error("failed", "err", err, slog.Group("g", "plain", errors.New("oops")))
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, map[string]any{
+		"msg":   "save: disk full",
+		"cause": map[string]any{"msg": "disk full"},
+	}, results[0]["err"])
+	require.Equal(t, map[string]any{"plain": map[string]any{"msg": "oops"}}, results[0]["g"])
+}
+
+func TestRawJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("raw",
+		easyslog.RawJSON("valid", []byte(`{"a":[1,2]}`)),
+		easyslog.RawJSON("invalid", []byte(`{"a":`)),
+		slog.Group("g", easyslog.RawJSON("pretty", []byte("{\n  \"b\": true\n}"))),
+	)
+
+	line := buf.String()
+	require.Contains(t, line, `"valid":{"a":[1,2]},"invalid":"{\"a\":","g":{"pretty":{"b":true}}}`+"\n")
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, map[string]any{"a": []any{float64(1), float64(2)}}, results[0]["valid"])
+}
+
+func TestHandlerLevelNames(t *testing.T) {
+	var buf bytes.Buffer
+	names := easyslog.LevelNamer{easyslog.LevelTrace: "TRACE", easyslog.LevelFatal: "FATAL"}
+	l := slog.New(easyslog.New(&buf, Formatter{}, &easyslog.Options{Level: easyslog.LevelTrace, LevelNames: names}))
+
+	l.Log(context.Background(), easyslog.LevelTrace, "trace")
+	l.Log(context.Background(), easyslog.LevelFatal, "fatal")
+	l.Log(context.Background(), slog.LevelWarn+1, "unnamed")
+
+	results := parseLines(t, buf.Bytes())
+	require.Equal(t, "TRACE", results[0]["level"])
+	require.Equal(t, "FATAL", results[1]["level"])
+	require.Equal(t, "WARN+1", results[2]["level"])
+}
+
+func TestNewFormatter(t *testing.T) {
+	formatter, err := easyslog.NewFormatter("json", map[string]any{
+		"duration_encoding": DurationString,
+		"keys":              map[string]any{"message": "message"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, Formatter{DurationEncoding: DurationString, Keys: &Keys{Message: "message"}}, formatter)
+
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, formatter, nil)).Info("hello", "took", time.Second)
+	require.Equal(t, `{"message":"hello","took":"1s"}`+"\n", buf.String())
+}
+
+// kindsSchema declares the JSON type of every field logged by the kinds case
+// of TestExactOutput, by dot-separated path.
+var kindsSchema = map[string]string{
+	"time": "string", "level": "string", "msg": "string",
+	"i": "number", "max": "number", "u": "number",
+	"f": "number", "tenth": "number", "big": "number", "neg0": "number", "whole": "number",
+	"t": "boolean", "false": "boolean",
+	"at": "string", "d": "number",
+	"err": "string", "wrapped": "string",
+	"g": "object", "g.s": "string", "g.h": "object", "g.h.x": "number",
+	"slice": "array", "nilslice": "null", "ptr": "null",
+	"ctl": "string",
+}
+
+// validateSchema checks that line holds exactly the fields in schema with the
+// declared JSON types.
+func validateSchema(t *testing.T, line []byte, schema map[string]string) {
+	t.Helper()
+
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	var doc map[string]any
+	require.NoError(t, decoder.Decode(&doc))
+
+	got := map[string]string{}
+	var walk func(prefix string, fields map[string]any)
+	walk = func(prefix string, fields map[string]any) {
+		for key, value := range fields {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+
+			switch v := value.(type) {
+			case map[string]any:
+				got[path] = "object"
+				walk(path, v)
+			case []any:
+				got[path] = "array"
+			case string:
+				got[path] = "string"
+			case json.Number:
+				got[path] = "number"
+			case bool:
+				got[path] = "boolean"
+			case nil:
+				got[path] = "null"
+			}
+		}
+	}
+	walk("", doc)
+
+	require.Equal(t, schema, got)
+}
+
+func TestExactOutput(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name    string
+		message string
+		attrs   []slog.Attr
+		handler func(h slog.Handler) slog.Handler
+		want    string
+		schema  map[string]string
+	}{
+		{
+			name:    "kinds",
+			message: "kinds",
+			attrs: []slog.Attr{
+				slog.Int("i", -3), slog.Int64("max", math.MaxInt64), slog.Uint64("u", math.MaxUint64),
+				slog.Float64("f", 1.5), slog.Float64("tenth", 0.1), slog.Float64("big", 1e21),
+				slog.Float64("neg0", math.Copysign(0, -1)), slog.Float64("whole", 3),
+				slog.Bool("t", true), slog.Bool("false", false),
+				slog.Time("at", time.Date(2024, 1, 2, 3, 4, 5, 6000, time.FixedZone("", 3600))),
+				slog.Duration("d", -time.Millisecond),
+				slog.Any("nil", nil),
+				slog.Any("err", errors.New(`boom: "x"`)),
+				slog.Any("wrapped", fmt.Errorf("a: %w", errors.New("b"))),
+				slog.Group("g", slog.String("s", ""), slog.Group("h", slog.Int("x", 1)), slog.Group("empty")),
+				slog.Any("slice", []int{1, 2}), slog.Any("nilslice", []string(nil)), slog.Any("ptr", (*int)(nil)),
+				slog.String("ctl", "\x00\x1f\u2028é"),
+			},
+			want: `{"time":"2024-01-02T03:04:05Z","level":"INFO","msg":"kinds",` +
+				`"i":-3,"max":9223372036854775807,"u":18446744073709551615,` +
+				`"f":1.5,"tenth":0.1,"big":1e+21,"neg0":-0,"whole":3,` +
+				`"t":true,"false":false,"at":"2024-01-02T03:04:05.000006+01:00","d":-1000000,` +
+				`"err":"boom: \"x\"","wrapped":"a: b","g":{"s":"","h":{"x":1}},` +
+				`"slice":[1,2],"nilslice":null,"ptr":null,"ctl":"\u0000\u001f\u2028é"}`,
+			schema: kindsSchema,
+		},
+		{
+			name:    "escaped keys and message",
+			message: "line\n\"two\" <b>",
+			attrs:   []slog.Attr{slog.String(`k"ey`, "v\\"), slog.String("tab\tkey", "\r")},
+			want:    `{"time":"2024-01-02T03:04:05Z","level":"INFO","msg":"line\n\"two\" <b>","k\"ey":"v\\","tab\tkey":"\r"}`,
+		},
+		{
+			name:    "empty message and no attrs",
+			message: "",
+			want:    `{"time":"2024-01-02T03:04:05Z","level":"INFO","msg":""}`,
+		},
+		{
+			name:    "handler attrs and groups",
+			message: "nested",
+			attrs:   []slog.Attr{slog.Int("c", 3), slog.Group("", slog.Int("inline", 4))},
+			handler: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g").WithAttrs([]slog.Attr{slog.Int("b", 2)}).WithGroup("unused")
+			},
+			want: `{"time":"2024-01-02T03:04:05Z","level":"INFO","msg":"nested","a":1,"g":{"b":2,"unused":{"c":3,"inline":4}}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var handler slog.Handler = easyslog.New(&buf, Formatter{}, nil)
+			if tc.handler != nil {
+				handler = tc.handler(handler)
+			}
+
+			r := slog.NewRecord(ts, slog.LevelInfo, tc.message, 0)
+			r.AddAttrs(tc.attrs...)
+			require.NoError(t, handler.Handle(context.Background(), r))
+
+			require.Equal(t, tc.want+"\n", buf.String())
+			require.True(t, json.Valid(buf.Bytes()))
+
+			if tc.schema != nil {
+				validateSchema(t, buf.Bytes(), tc.schema)
+			}
+		})
+	}
+}
+
+func TestHandlerName(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{Keys: &Keys{Message: "msg", Name: "component"}}, &easyslog.Options{Name: "server"})
+
+	slog.New(handler.WithName("http")).Info("served")
+	slog.New(easyslog.New(&buf, Formatter{}, &easyslog.Options{Name: "db", OmitTime: true})).Info("query")
+	slog.New(easyslog.New(&buf, Formatter{}, &easyslog.Options{OmitTime: true})).Info("unnamed")
+	slog.New(easyslog.New(&buf, Formatter{Keys: &Keys{Message: "msg"}}, &easyslog.Options{Name: "db"})).Info("omitted")
+
+	require.Equal(t, `{"msg":"served","component":"server.http"}
+{"level":"INFO","msg":"query","logger":"db"}
+{"level":"INFO","msg":"unnamed"}
+{"msg":"omitted"}
+`, buf.String())
+}
@@ -0,0 +1,85 @@
+package easyslog
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnakeCaseKeys is an Options.KeySanitizer that converts keys to snake_case,
+// splitting words on case changes and on anything other than letters and
+// digits, e.g. `userID`, `user-id` and `User ID` all become `user_id`, and
+// `HTTPServer` becomes `http_server`. Keys without letters or digits become
+// empty and are dropped.
+func SnakeCaseKeys(key string) string {
+	runes := []rune(key)
+
+	var b strings.Builder
+	b.Grow(len(key) + 4)
+
+	separate := false
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separate = b.Len() > 0
+			continue
+		}
+
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			// The last capital of an acronym starts the next word, e.g. the S
+			// in HTTPServer
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				separate = b.Len() > 0
+			}
+		}
+
+		if separate {
+			b.WriteByte('_')
+			separate = false
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// IdentifierKeys is an Options.KeySanitizer that makes keys valid identifiers
+// for systems like Prometheus labels or journald fields: every character
+// outside [A-Za-z0-9_] is replaced by an underscore, and keys starting with a
+// digit are prefixed with one, e.g. `user-agent` becomes `user_agent` and
+// `2xx` becomes `_2xx`.
+func IdentifierKeys(key string) string {
+	valid := key == "" || !isDigit(key[0])
+	for i := 0; valid && i < len(key); i++ {
+		valid = isIdentifierByte(key[i])
+	}
+
+	if valid {
+		return key
+	}
+
+	var b strings.Builder
+	b.Grow(len(key) + 1)
+
+	if isDigit(key[0]) {
+		b.WriteByte('_')
+	}
+
+	for _, r := range key {
+		if r < 0x80 && isIdentifierByte(byte(r)) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifierByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c == '_'
+}
@@ -0,0 +1,109 @@
+package easyslog
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnakeCaseKeys(t *testing.T) {
+	for key, want := range map[string]string{
+		"userID":         "user_id",
+		"user-id":        "user_id",
+		"User ID":        "user_id",
+		"HTTPServer":     "http_server",
+		"already_snake":  "already_snake",
+		"request.method": "request_method",
+		"_leading__":     "leading",
+		"v2API":          "v2_api",
+		"ÄrgerNis":       "ärger_nis",
+		"--":             "",
+		"":               "",
+	} {
+		require.Equal(t, want, SnakeCaseKeys(key), key)
+	}
+}
+
+func TestIdentifierKeys(t *testing.T) {
+	for key, want := range map[string]string{
+		"user_agent": "user_agent",
+		"user-agent": "user_agent",
+		"2xx":        "_2xx",
+		"a.b c":      "a_b_c",
+		"café":       "caf_",
+		"":           "",
+	} {
+		require.Equal(t, want, IdentifierKeys(key), key)
+	}
+}
+
+func keys(attrs []*Attr) []string {
+	keys := make([]string, len(attrs))
+	for i, attr := range attrs {
+		keys[i] = attr.Key
+	}
+
+	return keys
+}
+
+func TestKeySanitizerCollisions(t *testing.T) {
+	formatter := &recordingFormatter{}
+	l := slog.New(New(io.Discard, formatter, &Options{KeySanitizer: IdentifierKeys}))
+
+	l.With("a-b", 1).Info("msg", "a_b", 2, "a.b", 3, "a_b_2", 4, "--", 5)
+
+	attrs := formatter.records[0].Attrs
+	require.Equal(t, []string{"a_b", "a_b_2", "a_b_3", "a_b_2_2", "__"}, keys(attrs))
+	for i, attr := range attrs {
+		require.Equal(t, int64(i+1), attr.Value.Int64())
+	}
+
+	formatter = &recordingFormatter{}
+	l = slog.New(New(io.Discard, formatter, &Options{KeySanitizer: IdentifierKeys, FlattenGroups: "."}))
+	l.Info("msg", slog.Group("req", "a-b", 1, "a_b", 2), slog.Group("req", "a.b", 3))
+	require.Equal(t, []string{"req.a_b", "req.a_b_2", "req.a_b_3"}, keys(formatter.records[0].Attrs))
+}
+
+func TestKeySanitizerGroups(t *testing.T) {
+	formatter := &recordingFormatter{}
+	l := slog.New(New(io.Discard, formatter, &Options{KeySanitizer: SnakeCaseKeys}))
+
+	derived := l.WithGroup("httpRequest").With("remoteAddr", "::1")
+	derived.Info("msg", slog.Group("Headers", "userAgent", "curl", "user-agent", "wget"), slog.Group("headers", "x", 1))
+
+	record := formatter.records[0]
+	require.Equal(t, []string{"http_request"}, record.Groups)
+
+	value, ok := record.Get("http_request", "remote_addr")
+	require.True(t, ok)
+	require.Equal(t, "::1", value.String())
+
+	value, ok = record.Get("http_request", "headers", "user_agent")
+	require.True(t, ok)
+	require.Equal(t, "curl", value.String())
+
+	value, ok = record.Get("http_request", "headers", "user_agent_2")
+	require.True(t, ok)
+	require.Equal(t, "wget", value.String())
+
+	_, ok = record.Get("http_request", "headers_2", "x")
+	require.True(t, ok)
+
+	// Handlers derived from the same one keep sanitizing
+	var b bytes.Buffer
+	handler := New(&b, JSONFormatter{}, &Options{KeySanitizer: IdentifierKeys})
+	slog.New(handler.WithAttrs([]slog.Attr{slog.Int("base-attr", 1)}).WithGroup("my group")).Info("msg", "a key", 2)
+	require.Contains(t, b.String(), `"base_attr":"1"`)
+	require.Contains(t, b.String(), `"my_group":{"a_key":"2"}`)
+}
+
+func TestWrapKeySanitizer(t *testing.T) {
+	var b bytes.Buffer
+	l := slog.New(Wrap(slog.NewJSONHandler(&b, nil), &WrapOptions{KeySanitizer: IdentifierKeys}))
+
+	l.Info("msg", "a-b", 1, "a_b", 2)
+	require.Contains(t, b.String(), `"a_b":1,"a_b_2":2`)
+}
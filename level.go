@@ -0,0 +1,126 @@
+package easyslog
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+)
+
+// LevelNamer maps levels to the names formatters render for them, e.g.
+// `INFO` vs `info` vs `Information`. Levels missing from the map fall back to
+// slog.Level.String().
+type LevelNamer map[slog.Level]string
+
+// Name returns the name for level.
+func (names LevelNamer) Name(level slog.Level) string {
+	if name, ok := names[level]; ok {
+		return name
+	}
+
+	return level.String()
+}
+
+// RecordName returns the name for the record's level, falling back to
+// Record.LevelString, which honors Options.LevelNames.
+func (names LevelNamer) RecordName(r Record) string {
+	if name, ok := names[r.Level]; ok {
+		return name
+	}
+
+	return r.LevelString()
+}
+
+// Custom levels beyond slog's four, for use with Options.LevelNames, e.g.
+// `LevelNames: LevelNamer{LevelTrace: "TRACE", LevelFatal: "FATAL"}`.
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelFatal = slog.LevelError + 4
+)
+
+// shortLevels are the abbreviations used by Record.LevelShort.
+var shortLevels = map[slog.Level]string{
+	slog.LevelDebug: "DBG",
+	slog.LevelInfo:  "INF",
+	slog.LevelWarn:  "WRN",
+	slog.LevelError: "ERR",
+}
+
+// LevelString returns Record.LevelName if it's set, and the level as
+// slog.Level.String() renders it otherwise, e.g. `INFO` or `INFO+2`.
+func (r Record) LevelString() string {
+	if r.LevelName != "" {
+		return r.LevelName
+	}
+
+	return r.Level.String()
+}
+
+// LevelShort returns a three letter abbreviation of the record's level, with
+// an offset from the nearest standard level below it like slog.Level.String,
+// e.g. `INF` or `INF+2`.
+func (r Record) LevelShort() string {
+	base := standardLevel(r.Level)
+	short := shortLevels[base]
+	if r.Level == base {
+		return short
+	}
+
+	offset := strconv.Itoa(int(r.Level - base))
+	if r.Level > base {
+		offset = "+" + offset
+	}
+
+	return short + offset
+}
+
+// SyslogSeverity returns the syslog severity of the record's level, see
+// SyslogSeverity.
+func (r Record) SyslogSeverity() int {
+	return SyslogSeverity(r.Level)
+}
+
+// SyslogSeverity maps a slog level to a syslog severity: debug (7),
+// informational (6), warning (4), or error (3). Levels between the standard
+// ones map like the nearest standard level below them.
+func SyslogSeverity(level slog.Level) int {
+	switch standardLevel(level) {
+	case slog.LevelError:
+		return 3
+	case slog.LevelWarn:
+		return 4
+	case slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// standardLevel returns the nearest standard level at or below level, or
+// LevelDebug for levels below it.
+func standardLevel(level slog.Level) slog.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return slog.LevelDebug
+	case level < slog.LevelWarn:
+		return slog.LevelInfo
+	case level < slog.LevelError:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+type minLevelKey struct{}
+
+// WithMinLevel returns a copy of ctx carrying level as the minimum level to log
+// at. It's honored by handlers using ContextMinLevel as their
+// Options.MinLevelFromContext.
+func WithMinLevel(ctx context.Context, level slog.Level) context.Context {
+	return context.WithValue(ctx, minLevelKey{}, level)
+}
+
+// ContextMinLevel returns the level set on ctx by WithMinLevel, if any.
+func ContextMinLevel(ctx context.Context) (slog.Level, bool) {
+	level, ok := ctx.Value(minLevelKey{}).(slog.Level)
+	return level, ok
+}
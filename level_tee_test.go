@@ -0,0 +1,34 @@
+package easyslog_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/slogtesting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxLevelTee(t *testing.T) {
+	app, appHandler := slogtesting.New(&easyslog.Options{Level: slog.LevelDebug, MaxLevel: slog.LevelWarn})
+	// Just above the app log's MaxLevel, so no level falls in between
+	alert, alertHandler := slogtesting.New(&easyslog.Options{Level: slog.LevelWarn + 1})
+	l := slog.New(slogtesting.Tee(appHandler, alertHandler))
+
+	for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelWarn + 2, slog.LevelError, slog.LevelError + 4} {
+		l.Log(context.Background(), level, level.String())
+	}
+
+	messages := func(records []easyslog.Record) []string {
+		var messages []string
+		for _, r := range records {
+			messages = append(messages, r.Message)
+		}
+		return messages
+	}
+
+	// Every record is written exactly once
+	require.Equal(t, []string{"DEBUG", "INFO", "WARN"}, messages(app.Records()))
+	require.Equal(t, []string{"WARN+2", "ERROR", "ERROR+4"}, messages(alert.Records()))
+}
@@ -0,0 +1,238 @@
+package easyslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelNamer(t *testing.T) {
+	names := LevelNamer{
+		slog.LevelInfo: "info",
+		slog.LevelWarn: "WARNING",
+	}
+
+	require.Equal(t, "info", names.Name(slog.LevelInfo))
+	require.Equal(t, "WARNING", names.Name(slog.LevelWarn))
+	require.Equal(t, "ERROR", names.Name(slog.LevelError))
+	require.Equal(t, "INFO+2", names.Name(slog.LevelInfo+2))
+
+	var empty LevelNamer
+	require.Equal(t, "DEBUG", empty.Name(slog.LevelDebug))
+}
+
+func TestMinLevelFromContext(t *testing.T) {
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{MinLevelFromContext: ContextMinLevel})
+	l := slog.New(handler)
+
+	debugCtx := WithMinLevel(context.Background(), slog.LevelDebug)
+	errorCtx := WithMinLevel(context.Background(), slog.LevelError)
+
+	l.DebugContext(context.Background(), "dropped")
+	l.DebugContext(debugCtx, "debug")
+	l.InfoContext(errorCtx, "suppressed")
+	l.ErrorContext(errorCtx, "error")
+	l.Info("default")
+
+	messages := make([]string, 0, len(formatter.records))
+	for _, record := range formatter.records {
+		messages = append(messages, record.Message)
+	}
+	require.Equal(t, []string{"debug", "error", "default"}, messages)
+
+	require.True(t, handler.Enabled(nil, slog.LevelInfo))
+	require.False(t, handler.Enabled(nil, slog.LevelDebug))
+	require.True(t, handler.WithGroup("g").Enabled(debugCtx, slog.LevelDebug))
+}
+
+func TestMinLevelFromContextHandle(t *testing.T) {
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{MinLevelFromContext: ContextMinLevel})
+
+	errorCtx := WithMinLevel(context.Background(), slog.LevelError)
+	require.NoError(t, handler.Handle(errorCtx, slog.NewRecord(time.Now(), slog.LevelInfo, "dropped", 0)))
+	require.Empty(t, formatter.records)
+}
+
+func TestMinLevelFromContextUnset(t *testing.T) {
+	handler := New(io.Discard, &recordingFormatter{}, nil)
+	debugCtx := WithMinLevel(context.Background(), slog.LevelDebug)
+
+	require.False(t, handler.Enabled(debugCtx, slog.LevelDebug))
+	require.True(t, handler.Enabled(nil, slog.LevelInfo))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		handler.Enabled(debugCtx, slog.LevelInfo)
+	})
+	require.Zero(t, allocs)
+}
+
+func TestForceLevelAttr(t *testing.T) {
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{Level: slog.LevelWarn, ForceLevelAttr: "force_log"})
+	l := slog.New(handler)
+
+	require.True(t, handler.Enabled(context.Background(), slog.LevelDebug))
+
+	l.Debug("dropped")
+	l.Debug("forced", "force_log", true)
+	l.Info("not forced", "force_log", false)
+	l.Info("forced string", "force_log", "true")
+	l.Info("grouped", slog.Group("g", "force_log", true))
+	l.Warn("warn")
+
+	messages := make([]string, 0, len(formatter.records))
+	for _, record := range formatter.records {
+		messages = append(messages, record.Message)
+	}
+	require.Equal(t, []string{"forced", "forced string", "warn"}, messages)
+
+	_, ok := formatter.records[0].Get("force_log")
+	require.True(t, ok)
+}
+
+func TestForceLevelAttrWithContextLevel(t *testing.T) {
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{MinLevelFromContext: ContextMinLevel, ForceLevelAttr: "force_log"})
+
+	errorCtx := WithMinLevel(context.Background(), slog.LevelError)
+	slog.New(handler).InfoContext(errorCtx, "forced", "force_log", true)
+	slog.New(handler).InfoContext(errorCtx, "dropped")
+
+	require.Len(t, formatter.records, 1)
+	require.Equal(t, "forced", formatter.records[0].Message)
+}
+
+// resolveCounter counts how often it's resolved.
+type resolveCounter struct {
+	resolved int
+}
+
+func (c *resolveCounter) LogValue() slog.Value {
+	c.resolved++
+	return slog.StringValue("expensive")
+}
+
+func TestMaxLevel(t *testing.T) {
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{MaxLevel: slog.LevelWarn, ForceLevelAttr: "force"})
+	ctx := context.Background()
+
+	// MaxLevel is inclusive
+	require.True(t, handler.Enabled(ctx, slog.LevelInfo))
+	require.True(t, handler.Enabled(ctx, slog.LevelWarn))
+	require.False(t, handler.Enabled(ctx, slog.LevelWarn+1))
+	require.False(t, handler.Enabled(ctx, slog.LevelError))
+
+	// Handle checks it too, and derived handlers inherit it
+	derived := handler.WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+	require.NoError(t, derived.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelWarn, "warn", 0)))
+	forced := slog.NewRecord(time.Now(), slog.LevelError, "forced", 0)
+	forced.AddAttrs(slog.Bool("force", true))
+	require.NoError(t, derived.Handle(ctx, forced))
+
+	require.Len(t, formatter.records, 1)
+	require.Equal(t, "warn", formatter.records[0].Message)
+}
+
+func TestMaxLevelVar(t *testing.T) {
+	var max slog.LevelVar
+	max.Set(slog.LevelInfo)
+	handler := New(io.Discard, &recordingFormatter{}, &Options{Level: slog.LevelDebug, MaxLevel: &max})
+
+	require.False(t, handler.Enabled(context.Background(), slog.LevelWarn))
+	max.Set(slog.LevelWarn)
+	require.True(t, handler.Enabled(context.Background(), slog.LevelWarn))
+	require.True(t, handler.Enabled(context.Background(), slog.LevelDebug))
+}
+
+func TestDroppedRecordsSkipResolution(t *testing.T) {
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{
+		Level:               slog.LevelWarn,
+		MinLevelFromContext: ContextMinLevel,
+		ForceLevelAttr:      "force_log",
+		Sampler: func(ctx context.Context, r slog.Record) bool {
+			return r.Message != "sampled"
+		},
+	})
+	l := slog.New(handler)
+
+	dropped := &resolveCounter{}
+	l.Info("below level", "value", dropped, slog.Group("g", "nested", dropped))
+	l.InfoContext(WithMinLevel(context.Background(), slog.LevelError), "below context level", "value", dropped)
+	l.Warn("sampled", "value", dropped)
+	l.Info("not forced", "force_log", false, "value", dropped)
+	require.Zero(t, dropped.resolved)
+	require.Empty(t, formatter.records)
+
+	kept := &resolveCounter{}
+	l.Info("forced", "force_log", true, "value", kept)
+	require.Equal(t, 1, kept.resolved)
+	require.Len(t, formatter.records, 1)
+}
+
+func BenchmarkEnabled(b *testing.B) {
+	handler := New(io.Discard, &recordingFormatter{}, &Options{MinLevelFromContext: ContextMinLevel})
+	ctx := WithMinLevel(context.Background(), slog.LevelDebug)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		handler.Enabled(ctx, slog.LevelDebug)
+	}
+}
+
+func TestRecordLevelHelpers(t *testing.T) {
+	for _, tc := range []struct {
+		level    slog.Level
+		str      string
+		short    string
+		severity int
+	}{
+		{slog.LevelDebug - 4, "DEBUG-4", "DBG-4", 7},
+		{slog.LevelDebug, "DEBUG", "DBG", 7},
+		{slog.LevelInfo, "INFO", "INF", 6},
+		{slog.LevelInfo + 2, "INFO+2", "INF+2", 6},
+		{slog.LevelWarn, "WARN", "WRN", 4},
+		{slog.LevelError, "ERROR", "ERR", 3},
+		{slog.LevelError + 4, "ERROR+4", "ERR+4", 3},
+	} {
+		r := Record{Level: tc.level}
+		require.Equal(t, tc.str, r.LevelString())
+		require.Equal(t, tc.short, r.LevelShort())
+		require.Equal(t, tc.severity, r.SyslogSeverity())
+	}
+}
+
+func TestOptionsLevelNames(t *testing.T) {
+	formatter := &recordingFormatter{}
+	names := LevelNamer{LevelTrace: "TRACE", LevelFatal: "FATAL"}
+	l := slog.New(New(io.Discard, formatter, &Options{Level: LevelTrace, LevelNames: names}))
+
+	l.Log(context.Background(), LevelTrace, "trace")
+	l.Log(context.Background(), LevelFatal, "fatal")
+	l.Log(context.Background(), slog.LevelInfo+1, "unnamed")
+
+	require.Equal(t, "TRACE", formatter.records[0].LevelName)
+	require.Equal(t, "FATAL", formatter.records[1].LevelString())
+	require.Equal(t, "INFO+1", formatter.records[2].LevelName)
+
+	formatter = &recordingFormatter{}
+	slog.New(New(io.Discard, formatter, nil)).Info("default")
+	require.Empty(t, formatter.records[0].LevelName)
+	require.Equal(t, "INFO", formatter.records[0].LevelString())
+}
+
+func TestLevelNamerRecordName(t *testing.T) {
+	names := LevelNamer{slog.LevelInfo: "info"}
+
+	require.Equal(t, "info", names.RecordName(Record{Level: slog.LevelInfo, LevelName: "INFORMATION"}))
+	require.Equal(t, "FATAL", names.RecordName(Record{Level: LevelFatal, LevelName: "FATAL"}))
+	require.Equal(t, "WARN", names.RecordName(Record{Level: slog.LevelWarn}))
+}
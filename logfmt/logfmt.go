@@ -0,0 +1,135 @@
+// Package logfmt implements an easyslog.Formatter that renders each record as
+// a logfmt line, `time=... level=INFO msg=hello key=value`, like
+// slog.TextHandler.
+package logfmt
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// QuoteMode determines when values are quoted.
+type QuoteMode int
+
+const (
+	// WhenNeeded quotes values that are empty, aren't valid UTF-8, or contain
+	// spaces, `=`, `"` or control characters.
+	WhenNeeded QuoteMode = iota
+	// Always quotes every value.
+	Always
+	// Never writes values as-is, for consumers that split on the first `=`
+	// and the next space. Values containing spaces or newlines can't be told
+	// apart from the rest of the line.
+	Never
+)
+
+// Formatter implements easyslog.Formatter and renders records as `key=value`
+// pairs: `time`, `level` and `msg`, followed by the record's attributes in
+// order with group keys joined by dots. Times are RFC 3339 with nanoseconds
+// and RawJSON values are written as their JSON text. lokiformat uses it for
+// its Logfmt lines.
+type Formatter struct {
+	// QuoteMode determines when values are quoted. Defaults to WhenNeeded.
+	QuoteMode QuoteMode
+	// OmitTime leaves out the `time` key, e.g. when the line is shipped with
+	// its own timestamp. Records with a zero time never have one.
+	OmitTime bool
+	// OmitLevel leaves out the `level` key.
+	OmitLevel bool
+	// LevelNames overrides the rendered level names. Levels not in the map
+	// render as Record.LevelString().
+	LevelNames easyslog.LevelNamer
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	factory := func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	}
+
+	// `text` is slog's name for the same format
+	easyslog.RegisterFormatter("logfmt", factory)
+	easyslog.RegisterFormatter("text", factory)
+}
+
+func (f Formatter) Format(w io.Writer, r easyslog.Record) error {
+	var buf bytes.Buffer
+
+	if !f.OmitTime && !r.Time.IsZero() {
+		buf.WriteString("time=")
+		f.QuoteMode.appendValue(&buf, r.Time.Format(time.RFC3339Nano))
+		buf.WriteByte(' ')
+	}
+
+	if !f.OmitLevel {
+		buf.WriteString("level=")
+		f.QuoteMode.appendValue(&buf, f.LevelNames.RecordName(r))
+		buf.WriteByte(' ')
+	}
+
+	buf.WriteString("msg=")
+	f.QuoteMode.appendValue(&buf, r.Message)
+
+	for _, attr := range r.Attrs {
+		f.appendAttr(&buf, attr, "")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (f Formatter) appendAttr(buf *bytes.Buffer, attr *easyslog.Attr, prefix string) {
+	key := attr.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+
+	if attr.IsGroup() {
+		for _, child := range attr.Children {
+			f.appendAttr(buf, child, key)
+		}
+		return
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if data, ok := attr.RawJSON(); ok {
+		f.QuoteMode.appendValue(buf, string(data))
+	} else {
+		f.QuoteMode.appendValue(buf, attr.Value.String())
+	}
+}
+
+// appendValue writes s, quoted as determined by quote.
+func (quote QuoteMode) appendValue(buf *bytes.Buffer, s string) {
+	switch quote {
+	case Always:
+	case Never:
+		buf.WriteString(s)
+		return
+	default:
+		if s != "" && strings.IndexFunc(s, needsQuote) < 0 && utf8.ValidString(s) {
+			buf.WriteString(s)
+			return
+		}
+	}
+
+	buf.WriteString(strconv.Quote(s))
+}
+
+func needsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f || (r >= 0x80 && r < 0xa0)
+}
@@ -0,0 +1,89 @@
+package logfmt
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, &easyslog.Options{TimeZone: time.UTC}))
+
+	l.Info("hello world", "user", "fox", slog.Group("request", "method", "GET", "query", `a="b"`), "empty", "", easyslog.RawJSON("raw", []byte(`{"a":1}`)))
+
+	timestamp, rest, _ := strings.Cut(buf.String(), " ")
+	require.Regexp(t, `^time=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z$`, timestamp)
+	require.Equal(t, `level=INFO msg="hello world" user=fox request.method=GET request.query="a=\"b\"" empty="" raw="{\"a\":1}"`+"\n", rest)
+}
+
+func TestOmit(t *testing.T) {
+	var buf bytes.Buffer
+	f := Formatter{OmitTime: true, OmitLevel: true}
+	require.NoError(t, f.Format(&buf, easyslog.Record{Time: time.Now(), Level: slog.LevelWarn, Message: "hi"}))
+	require.Equal(t, "msg=hi", buf.String())
+
+	// Records without a time never have one
+	buf.Reset()
+	f = Formatter{LevelNames: easyslog.LevelNamer{slog.LevelWarn: "warning"}}
+	require.NoError(t, f.Format(&buf, easyslog.Record{Level: slog.LevelWarn, Message: "hi"}))
+	require.Equal(t, "level=warning msg=hi", buf.String())
+}
+
+func TestQuoting(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Formatter{OmitTime: true}.Format(&buf, easyslog.Record{
+		Level:   slog.LevelInfo,
+		Message: "hi",
+		Attrs: []*easyslog.Attr{
+			{Key: "plain", Value: slog.StringValue("GET")},
+			{Key: "space", Value: slog.StringValue("a b")},
+			{Key: "eq", Value: slog.StringValue("a=b")},
+			{Key: "quote", Value: slog.StringValue(`say "hi"`)},
+			{Key: "tab", Value: slog.StringValue("a\tb")},
+			{Key: "invalid", Value: slog.StringValue("\xff")},
+			{Key: "n", Value: slog.IntValue(1)},
+		},
+	}))
+
+	require.Equal(t, `level=INFO msg=hi plain=GET space="a b" eq="a=b" quote="say \"hi\"" tab="a\tb" invalid="\xff" n=1`, buf.String())
+}
+
+func TestQuoteMode(t *testing.T) {
+	r := easyslog.Record{
+		Level:   slog.LevelInfo,
+		Message: "hi",
+		Attrs: []*easyslog.Attr{
+			{Key: "plain", Value: slog.StringValue("GET")},
+			{Key: "space", Value: slog.StringValue("a b")},
+			{Key: "eq", Value: slog.StringValue("a=b")},
+			{Key: "quote", Value: slog.StringValue(`say "hi"`)},
+			{Key: "tab", Value: slog.StringValue("a\tb")},
+			{Key: "empty", Value: slog.StringValue("")},
+			{Key: "n", Value: slog.IntValue(1)},
+		},
+	}
+
+	for mode, want := range map[QuoteMode]string{
+		WhenNeeded: `level=INFO msg=hi plain=GET space="a b" eq="a=b" quote="say \"hi\"" tab="a\tb" empty="" n=1`,
+		Always:     `level="INFO" msg="hi" plain="GET" space="a b" eq="a=b" quote="say \"hi\"" tab="a\tb" empty="" n="1"`,
+		Never:      "level=INFO msg=hi plain=GET space=a b eq=a=b quote=say \"hi\" tab=a\tb empty= n=1",
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, Formatter{QuoteMode: mode}.Format(&buf, r))
+		require.Equal(t, want, buf.String(), mode)
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	for _, name := range []string{"logfmt", "text"} {
+		formatter, err := easyslog.NewFormatter(name, map[string]any{"omit_time": true, "quote_mode": Always})
+		require.NoError(t, err)
+		require.Equal(t, Formatter{OmitTime: true, QuoteMode: Always}, formatter)
+	}
+}
@@ -0,0 +1,225 @@
+// Package lokiformat implements an easyslog.Formatter that renders records as
+// Grafana Loki push API payloads, with selected attributes promoted to stream
+// labels.
+package lokiformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/jsonlog"
+	"github.com/blakewilliams/easyslog/logfmt"
+)
+
+// LevelLabel is the label key that promotes the record's level to a label.
+const LevelLabel = "level"
+
+// LineFormat determines how the log line of an entry is rendered.
+type LineFormat int
+
+const (
+	// JSON renders the line as a jsonlog object with `level` and `msg` keys
+	// followed by the attributes. The time is left out since it's sent as
+	// the entry's timestamp.
+	JSON LineFormat = iota
+	// Logfmt renders the line with the logfmt package as
+	// `level=INFO msg=hello key=value`, with group keys joined by dots. The
+	// time is left out like with JSON.
+	Logfmt
+)
+
+// Formatter implements easyslog.Formatter and renders each record as a push
+// payload with a single stream and entry. Use Entry and Payload to batch
+// records into fewer streams, like lokiwriter does.
+type Formatter struct {
+	// LabelKeys are the dot-separated paths of attributes promoted to stream
+	// labels, e.g. `service` or `request.env`. The label is named after the
+	// path converted by LabelName, e.g. `request_env`, and the attribute is
+	// left out of the line. LevelLabel promotes the record's level. Attributes that
+	// are missing or are groups add no label.
+	LabelKeys []string
+	// Labels are added to every stream, e.g. `job`. Loki rejects streams
+	// without labels, so set it when LabelKeys may not match.
+	Labels map[string]string
+	// LineFormat determines how the line is rendered. Defaults to JSON.
+	LineFormat LineFormat
+	// QuoteMode determines when values are quoted with Logfmt. Defaults to
+	// logfmt.WhenNeeded.
+	QuoteMode logfmt.QuoteMode
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("loki", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+// Entry is a single log line and the labels of its stream.
+type Entry struct {
+	Labels map[string]string
+	Time   time.Time
+	Line   string
+}
+
+// Format writes r as a push payload, `{"streams":[...]}`.
+func (f Formatter) Format(w io.Writer, r easyslog.Record) error {
+	entry, err := f.Entry(r)
+	if err != nil {
+		return err
+	}
+
+	payload, err := Payload([]Entry{entry})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(payload)
+	return err
+}
+
+// Entry extracts the labels of r and renders the rest of it as the line. A
+// zero Record.Time is replaced with the current time, since Loki requires a
+// timestamp.
+func (f Formatter) Entry(r easyslog.Record) (Entry, error) {
+	labels := make(map[string]string, len(f.LabelKeys)+len(f.Labels))
+	for name, value := range f.Labels {
+		labels[name] = value
+	}
+
+	levelLabel := false
+	for _, key := range f.LabelKeys {
+		if key == LevelLabel {
+			levelLabel = true
+			labels[LevelLabel] = r.LevelString()
+			continue
+		}
+
+		path := strings.Split(key, ".")
+		value, ok := r.Get(path...)
+		if !ok {
+			continue
+		}
+
+		labels[LabelName(key)] = value.String()
+		r = r.Without(path...)
+	}
+
+	timestamp := r.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var buf bytes.Buffer
+	if f.LineFormat == Logfmt {
+		if err := (logfmt.Formatter{QuoteMode: f.QuoteMode, OmitTime: true, OmitLevel: levelLabel}).Format(&buf, r); err != nil {
+			return Entry{}, err
+		}
+	} else {
+		keys := jsonlog.Keys{Level: slog.LevelKey, Message: slog.MessageKey, Name: jsonlog.DefaultNameKey}
+		if levelLabel {
+			keys.Level = ""
+		}
+
+		if err := (jsonlog.Formatter{Keys: &keys}).Format(&buf, r); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	return Entry{Labels: labels, Time: timestamp, Line: buf.String()}, nil
+}
+
+// LabelName converts key into a valid Loki label name, matching
+// [a-zA-Z_][a-zA-Z0-9_]*: characters outside [a-zA-Z0-9_], including dots,
+// become underscores, and names that start with a digit are prefixed with an
+// underscore. Loki rejects a whole push containing an invalid name.
+func LabelName(key string) string {
+	var b strings.Builder
+	b.Grow(len(key) + 1)
+
+	for _, r := range key {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+			continue
+		}
+
+		b.WriteByte('_')
+	}
+
+	name := b.String()
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	return name
+}
+
+type (
+	payload struct {
+		Streams []stream `json:"streams"`
+	}
+
+	stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+)
+
+// Payload encodes entries as a push API request body, grouping entries with
+// the same labels into one stream. Streams are ordered by their first entry
+// and keep the order of their entries.
+func Payload(entries []Entry) ([]byte, error) {
+	var body payload
+	streams := make(map[string]int)
+
+	for _, entry := range entries {
+		key := labelsKey(entry.Labels)
+		i, ok := streams[key]
+		if !ok {
+			i = len(body.Streams)
+			streams[key] = i
+			body.Streams = append(body.Streams, stream{Stream: entry.Labels})
+		}
+
+		value := [2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), entry.Line}
+		body.Streams[i].Values = append(body.Streams[i].Values, value)
+	}
+
+	if body.Streams == nil {
+		body.Streams = []stream{}
+	}
+
+	return json.Marshal(body)
+}
+
+// labelsKey returns a string identifying a label set regardless of map order.
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(strconv.Quote(name))
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+
+	return b.String()
+}
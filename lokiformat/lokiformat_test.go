@@ -0,0 +1,143 @@
+package lokiformat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/logfmt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	f := Formatter{LabelKeys: []string{"level", "service", "request.env"}, Labels: map[string]string{"job": "api"}}
+	handler := easyslog.New(&buf, f, nil)
+
+	r := slog.NewRecord(time.Unix(1700000000, 123), slog.LevelWarn, "slow", 0)
+	r.AddAttrs(
+		slog.String("service", "web"),
+		slog.Group("request", slog.String("env", "prod"), slog.String("path", "/")),
+		slog.Int("took", 12),
+	)
+	require.NoError(t, handler.Handle(context.Background(), r))
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &payload))
+
+	require.Equal(t, map[string]any{
+		"streams": []any{
+			map[string]any{
+				"stream": map[string]any{"job": "api", "level": "WARN", "service": "web", "request_env": "prod"},
+				"values": []any{
+					[]any{"1700000000000000123", `{"msg":"slow","request":{"path":"/"},"took":12}`},
+				},
+			},
+		},
+	}, payload)
+}
+
+func TestEntryRemovesEmptyGroups(t *testing.T) {
+	var entry Entry
+	f := Formatter{LabelKeys: []string{"k8s.pod.name", "missing", "k8s"}}
+	handler := easyslog.New(&bytes.Buffer{}, f, &easyslog.Options{Tap: func(r easyslog.Record) {
+		var err error
+		entry, err = f.Entry(r)
+		require.NoError(t, err)
+	}})
+
+	slog.New(handler).Info("msg", slog.Group("k8s", slog.Group("pod", "name", "web-1")), "a", 1)
+
+	require.Equal(t, map[string]string{"k8s_pod_name": "web-1"}, entry.Labels)
+	require.Equal(t, `{"level":"INFO","msg":"msg","a":1}`, entry.Line)
+}
+
+func TestEntryDoesntModifyRecord(t *testing.T) {
+	var record easyslog.Record
+	handler := easyslog.New(&bytes.Buffer{}, Formatter{LabelKeys: []string{"g.a"}}, &easyslog.Options{Tap: func(r easyslog.Record) {
+		record = r
+	}})
+
+	slog.New(handler).Info("msg", slog.Group("g", "a", 1, "b", 2))
+
+	_, ok := record.Get("g", "a")
+	require.True(t, ok)
+}
+
+func TestLogfmt(t *testing.T) {
+	f := Formatter{LabelKeys: []string{"service"}, LineFormat: Logfmt}
+	r := easyslog.Record{
+		Level:   slog.LevelInfo,
+		Message: "hello world",
+		Attrs: []*easyslog.Attr{
+			{Key: "service", Value: slog.StringValue("web")},
+			{Key: "request", Children: []*easyslog.Attr{
+				{Key: "method", Value: slog.StringValue("GET")},
+				{Key: "query", Value: slog.StringValue(`a="b"`)},
+			}},
+			{Key: "empty", Value: slog.StringValue("")},
+		},
+	}
+
+	entry, err := f.Entry(r)
+	require.NoError(t, err)
+	require.Equal(t, `level=INFO msg="hello world" request.method=GET request.query="a=\"b\"" empty=""`, entry.Line)
+	require.False(t, entry.Time.IsZero())
+
+	f.LabelKeys = []string{"level"}
+	entry, err = f.Entry(r)
+	require.NoError(t, err)
+	require.Equal(t, `msg="hello world" service=web request.method=GET request.query="a=\"b\"" empty=""`, entry.Line)
+
+	f.QuoteMode = logfmt.Always
+	entry, err = f.Entry(r)
+	require.NoError(t, err)
+	require.Equal(t, `msg="hello world" service="web" request.method="GET" request.query="a=\"b\"" empty=""`, entry.Line)
+}
+
+func TestLabelName(t *testing.T) {
+	for key, want := range map[string]string{
+		"service":       "service",
+		"request.env":   "request_env",
+		"http-status":   "http_status",
+		"path/to":       "path_to",
+		"user id":       "user_id",
+		"1st":           "_1st",
+		"_private":      "_private",
+		"café":          "caf_",
+		"":              "_",
+		"Mixed.Case_09": "Mixed_Case_09",
+	} {
+		require.Equal(t, want, LabelName(key), key)
+	}
+
+	entry, err := Formatter{LabelKeys: []string{"k8s.pod-name"}}.Entry(easyslog.Record{Attrs: []*easyslog.Attr{
+		{Key: "k8s", Children: []*easyslog.Attr{{Key: "pod-name", Value: slog.StringValue("api-0")}}},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"k8s_pod_name": "api-0"}, entry.Labels)
+}
+
+func TestPayloadGroupsStreams(t *testing.T) {
+	at := time.Unix(0, 5)
+	entries := []Entry{
+		{Labels: map[string]string{"app": "a", "env": "prod"}, Time: at, Line: "one"},
+		{Labels: map[string]string{"app": "b"}, Time: at, Line: "two"},
+		{Labels: map[string]string{"env": "prod", "app": "a"}, Time: at, Line: "three"},
+	}
+
+	body, err := Payload(entries)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"streams":[
+		{"stream":{"app":"a","env":"prod"},"values":[["5","one"],["5","three"]]},
+		{"stream":{"app":"b"},"values":[["5","two"]]}
+	]}`, string(body))
+
+	body, err = Payload(nil)
+	require.NoError(t, err)
+	require.Equal(t, `{"streams":[]}`, string(body))
+}
@@ -0,0 +1,342 @@
+// Package lokiwriter pushes log records to Grafana Loki's push API, batching
+// them into streams by label set.
+package lokiwriter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/lokiformat"
+)
+
+var (
+	// ErrClosed is returned by Write and WriteRecord after Close has been
+	// called.
+	ErrClosed = errors.New("lokiwriter: writer closed")
+	// ErrBufferFull is returned when MaxPending entries are already waiting to
+	// be sent, e.g. while Loki is unavailable. The entry is dropped.
+	ErrBufferFull = errors.New("lokiwriter: pending buffer full")
+)
+
+const (
+	// DefaultMaxPending is used when Options.MaxPending is zero.
+	DefaultMaxPending = 4096
+	// DefaultBatchSize is used when Options.BatchSize is zero.
+	DefaultBatchSize = 512
+	// DefaultBatchWait is used when Options.BatchWait is zero.
+	DefaultBatchWait = time.Second
+	// DefaultMinBackoff is used when Options.MinBackoff is zero.
+	DefaultMinBackoff = 100 * time.Millisecond
+	// DefaultMaxBackoff is used when Options.MaxBackoff is zero.
+	DefaultMaxBackoff = 10 * time.Second
+	// DefaultMaxRetries is used when Options.MaxRetries is zero.
+	DefaultMaxRetries = 10
+	// DefaultFlushTimeout is used when Options.FlushTimeout is zero.
+	DefaultFlushTimeout = 5 * time.Second
+)
+
+// Options to configure a Writer.
+type Options struct {
+	// Client sends the push requests. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Header is added to every push request, e.g. `Authorization` or
+	// `X-Scope-OrgID`.
+	Header http.Header
+	// MaxPending is the number of entries buffered while they wait to be
+	// sent. Defaults to DefaultMaxPending.
+	MaxPending int
+	// BatchSize is the most entries sent in one request, and BatchWait how
+	// long a partial batch waits for more entries. Default to
+	// DefaultBatchSize and DefaultBatchWait.
+	BatchSize int
+	BatchWait time.Duration
+	// MinBackoff and MaxBackoff bound the delay between retries of a failed
+	// request, which doubles after each failure. Default to DefaultMinBackoff
+	// and DefaultMaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxRetries is how many times a batch is retried after network errors,
+	// 429 and 5xx responses before it's dropped. Other responses drop it
+	// immediately. Defaults to DefaultMaxRetries.
+	MaxRetries int
+	// FlushTimeout is how long Close keeps trying to send pending entries.
+	// Defaults to DefaultFlushTimeout.
+	FlushTimeout time.Duration
+	// OnError, when set, is called from the background goroutine with the
+	// error of each batch that's dropped.
+	OnError func(err error)
+}
+
+// Writer queues entries and pushes them to Loki from a background goroutine,
+// in batches of up to BatchSize entries grouped into one stream per label set.
+//
+// Writer implements easyslog.RecordWriter, so a handler writing to it renders
+// each record with its lokiformat.Formatter and never calls its own
+// formatter. Used as a plain io.Writer, e.g. behind another handler, each
+// line is sent as-is with the Formatter's static Labels.
+type Writer struct {
+	endpoint  string
+	formatter lokiformat.Formatter
+	client    *http.Client
+	header    http.Header
+
+	batchSize    int
+	batchWait    time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	maxRetries   int
+	flushTimeout time.Duration
+	onError      func(err error)
+
+	pending chan lokiformat.Entry
+	done    chan struct{}
+	stopped chan struct{}
+	// ctx is cancelled FlushTimeout after Close so a request in flight, or a
+	// batch being retried, gives up.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+var _ easyslog.RecordWriter = (*Writer)(nil)
+
+// New returns a Writer pushing to endpoint, e.g.
+// "http://localhost:3100/loki/api/v1/push". opts may be nil.
+func New(endpoint string, formatter lokiformat.Formatter, opts *Options) *Writer {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	w := &Writer{
+		endpoint:     endpoint,
+		formatter:    formatter,
+		client:       opts.Client,
+		header:       opts.Header,
+		batchSize:    opts.BatchSize,
+		batchWait:    opts.BatchWait,
+		minBackoff:   opts.MinBackoff,
+		maxBackoff:   opts.MaxBackoff,
+		maxRetries:   opts.MaxRetries,
+		flushTimeout: opts.FlushTimeout,
+		onError:      opts.OnError,
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+
+	if w.client == nil {
+		w.client = http.DefaultClient
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = DefaultBatchSize
+	}
+	if w.batchWait <= 0 {
+		w.batchWait = DefaultBatchWait
+	}
+	if w.minBackoff <= 0 {
+		w.minBackoff = DefaultMinBackoff
+	}
+	if w.maxBackoff <= 0 {
+		w.maxBackoff = DefaultMaxBackoff
+	}
+	if w.maxRetries <= 0 {
+		w.maxRetries = DefaultMaxRetries
+	}
+	if w.flushTimeout <= 0 {
+		w.flushTimeout = DefaultFlushTimeout
+	}
+
+	maxPending := opts.MaxPending
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPending
+	}
+	w.pending = make(chan lokiformat.Entry, maxPending)
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	go w.run()
+
+	return w
+}
+
+// WriteRecord implements easyslog.RecordWriter and queues r as an entry.
+func (w *Writer) WriteRecord(r easyslog.Record) error {
+	entry, err := w.formatter.Entry(r)
+	if err != nil {
+		return err
+	}
+
+	return w.enqueue(entry)
+}
+
+// Write queues p, without its trailing newline, as an entry with the
+// Formatter's static Labels.
+func (w *Writer) Write(p []byte) (int, error) {
+	line := p
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+
+	entry := lokiformat.Entry{Labels: w.formatter.Labels, Time: time.Now(), Line: string(line)}
+	if err := w.enqueue(entry); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (w *Writer) enqueue(entry lokiformat.Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	select {
+	case w.pending <- entry:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// Close stops accepting entries and waits up to FlushTimeout for pending
+// entries to be sent. It's safe to call more than once.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+		time.AfterFunc(w.flushTimeout, w.cancel)
+	}
+	w.mu.Unlock()
+
+	<-w.stopped
+	return nil
+}
+
+func (w *Writer) run() {
+	defer close(w.stopped)
+	defer w.cancel()
+
+	batch := make([]lokiformat.Entry, 0, w.batchSize)
+	timer := time.NewTimer(w.batchWait)
+	timer.Stop()
+
+	flush := func() {
+		if len(batch) > 0 {
+			w.push(w.ctx, batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case entry := <-w.pending:
+			if len(batch) == 0 {
+				timer.Reset(w.batchWait)
+			}
+
+			batch = append(batch, entry)
+			if len(batch) >= w.batchSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		case <-w.done:
+			// Send what's left until the queue is empty or ctx is cancelled
+			timer.Stop()
+
+			for {
+				select {
+				case entry := <-w.pending:
+					batch = append(batch, entry)
+					if len(batch) >= w.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// push sends batch, retrying with backoff until it succeeds, fails with a
+// response that can't be retried, runs out of retries, or ctx is cancelled.
+func (w *Writer) push(ctx context.Context, batch []lokiformat.Entry) {
+	body, err := lokiformat.Payload(batch)
+	if err != nil {
+		w.dropped(err)
+		return
+	}
+
+	backoff := w.minBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.send(ctx, body)
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt >= w.maxRetries || ctx.Err() != nil {
+			w.dropped(err)
+			return
+		}
+
+		wait := time.NewTimer(backoff)
+		backoff = min(backoff*2, w.maxBackoff)
+
+		select {
+		case <-wait.C:
+		case <-ctx.Done():
+			wait.Stop()
+			w.dropped(err)
+			return
+		}
+	}
+}
+
+// send posts body once, reporting whether a failure can be retried.
+func (w *Writer) send(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	for key, values := range w.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("lokiwriter: push failed: %s", resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func (w *Writer) dropped(err error) {
+	if w.onError != nil {
+		w.onError(err)
+	}
+}
@@ -0,0 +1,185 @@
+package lokiwriter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/lokiformat"
+	"github.com/stretchr/testify/require"
+)
+
+type pushRequest struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+// fakeLoki records push requests, responding with the queued statuses first
+// and 204 afterwards.
+type fakeLoki struct {
+	mu       sync.Mutex
+	requests []pushRequest
+	headers  []http.Header
+	statuses []int
+	attempts int
+}
+
+func (l *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.attempts++
+	if len(l.statuses) > 0 {
+		status := l.statuses[0]
+		l.statuses = l.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	var req pushRequest
+	if r.Method != http.MethodPost || r.URL.Path != "/loki/api/v1/push" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	l.requests = append(l.requests, req)
+	l.headers = append(l.headers, r.Header.Clone())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newServer(t *testing.T, loki *fakeLoki) string {
+	server := httptest.NewServer(loki)
+	t.Cleanup(server.Close)
+
+	return server.URL + "/loki/api/v1/push"
+}
+
+func TestBatchesStreamsByLabels(t *testing.T) {
+	loki := &fakeLoki{}
+	header := http.Header{"X-Scope-Orgid": []string{"tenant"}}
+	w := New(newServer(t, loki), lokiformat.Formatter{LabelKeys: []string{"level", "app"}}, &Options{BatchWait: time.Hour, Header: header})
+
+	l := slog.New(easyslog.New(w, nil, nil))
+	l.Info("one", "app", "web")
+	l.Error("two", "app", "web")
+	l.Info("three", "app", "web", "n", 3)
+	l.Info("four", "app", "worker")
+
+	require.NoError(t, w.Close())
+
+	require.Len(t, loki.requests, 1)
+	require.Equal(t, "tenant", loki.headers[0].Get("X-Scope-OrgID"))
+	require.Equal(t, "application/json", loki.headers[0].Get("Content-Type"))
+
+	streams := loki.requests[0].Streams
+	require.Len(t, streams, 3)
+
+	require.Equal(t, map[string]string{"level": "INFO", "app": "web"}, streams[0].Stream)
+	require.Len(t, streams[0].Values, 2)
+	require.Equal(t, `{"msg":"one"}`, streams[0].Values[0][1])
+	require.Equal(t, `{"msg":"three","n":3}`, streams[0].Values[1][1])
+
+	require.Equal(t, map[string]string{"level": "ERROR", "app": "web"}, streams[1].Stream)
+	require.Equal(t, map[string]string{"level": "INFO", "app": "worker"}, streams[2].Stream)
+}
+
+func TestBatchSize(t *testing.T) {
+	loki := &fakeLoki{}
+	w := New(newServer(t, loki), lokiformat.Formatter{Labels: map[string]string{"job": "test"}}, &Options{BatchSize: 2, BatchWait: time.Hour})
+
+	l := slog.New(easyslog.New(w, nil, nil))
+	for i := 0; i < 5; i++ {
+		l.Info("msg", "i", i)
+	}
+	require.NoError(t, w.Close())
+
+	require.Len(t, loki.requests, 3)
+	require.Len(t, loki.requests[0].Streams[0].Values, 2)
+	require.Len(t, loki.requests[2].Streams[0].Values, 1)
+}
+
+func TestBatchWait(t *testing.T) {
+	loki := &fakeLoki{}
+	w := New(newServer(t, loki), lokiformat.Formatter{Labels: map[string]string{"job": "test"}}, &Options{BatchWait: 10 * time.Millisecond})
+	defer w.Close()
+
+	slog.New(easyslog.New(w, nil, nil)).Info("msg")
+
+	require.Eventually(t, func() bool {
+		loki.mu.Lock()
+		defer loki.mu.Unlock()
+		return len(loki.requests) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRetries(t *testing.T) {
+	loki := &fakeLoki{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	w := New(newServer(t, loki), lokiformat.Formatter{Labels: map[string]string{"job": "test"}}, &Options{MinBackoff: time.Millisecond})
+
+	slog.New(easyslog.New(w, nil, nil)).Info("msg")
+	require.NoError(t, w.Close())
+
+	require.Equal(t, 3, loki.attempts)
+	require.Len(t, loki.requests, 1)
+}
+
+func TestDropsBadRequests(t *testing.T) {
+	loki := &fakeLoki{statuses: []int{http.StatusBadRequest}}
+	var errs []error
+	w := New(newServer(t, loki), lokiformat.Formatter{Labels: map[string]string{"job": "test"}}, &Options{
+		MinBackoff: time.Millisecond,
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+
+	slog.New(easyslog.New(w, nil, nil)).Info("msg")
+	require.NoError(t, w.Close())
+
+	require.Equal(t, 1, loki.attempts)
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "lokiwriter: push failed: 400 Bad Request")
+}
+
+func TestWrite(t *testing.T) {
+	loki := &fakeLoki{}
+	w := New(newServer(t, loki), lokiformat.Formatter{Labels: map[string]string{"job": "test"}}, nil)
+
+	n, err := io.WriteString(w, "plain line\n")
+	require.NoError(t, err)
+	require.Equal(t, 11, n)
+	require.NoError(t, w.Close())
+
+	require.Len(t, loki.requests, 1)
+	require.Equal(t, map[string]string{"job": "test"}, loki.requests[0].Streams[0].Stream)
+	require.Equal(t, "plain line", loki.requests[0].Streams[0].Values[0][1])
+}
+
+func TestBufferFullAndClosed(t *testing.T) {
+	blocked := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer server.Close()
+	defer close(blocked)
+
+	w := New(server.URL, lokiformat.Formatter{}, &Options{MaxPending: 1, BatchSize: 1, FlushTimeout: 10 * time.Millisecond})
+
+	// The first entry is taken by the blocked request, the second fills the
+	// queue.
+	require.NoError(t, w.WriteRecord(easyslog.Record{Message: "one"}))
+	require.Eventually(t, func() bool {
+		return w.WriteRecord(easyslog.Record{Message: "two"}) == nil
+	}, time.Second, time.Millisecond)
+	require.ErrorIs(t, w.WriteRecord(easyslog.Record{Message: "three"}), ErrBufferFull)
+
+	require.NoError(t, w.Close())
+	require.ErrorIs(t, w.WriteRecord(easyslog.Record{Message: "four"}), ErrClosed)
+	require.NoError(t, w.Close())
+}
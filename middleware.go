@@ -0,0 +1,56 @@
+package easyslog
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"slices"
+)
+
+// Middleware decorates a Formatter. A middleware may modify the Record before
+// calling the wrapped Formatter, and may modify the formatted bytes by having
+// the wrapped Formatter write into its own buffer before copying the result
+// to w.
+type Middleware func(Formatter) Formatter
+
+// Chain wraps f with middlewares. The first middleware is the outermost: it
+// sees the Record first and the formatted bytes last.
+func Chain(f Formatter, middlewares ...Middleware) Formatter {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		f = middlewares[i](f)
+	}
+
+	return f
+}
+
+// WithStaticField returns a Middleware that adds an attribute with key and v
+// to the end of every Record's top-level attributes.
+func WithStaticField(key string, v slog.Value) Middleware {
+	return func(next Formatter) Formatter {
+		return FormatterFunc(func(w io.Writer, r Record) error {
+			// Clip so appending never writes into a slice the caller retains
+			r.Attrs = append(slices.Clip(r.Attrs), &Attr{Key: key, Value: v})
+			return next.Format(w, r)
+		})
+	}
+}
+
+// MaxLineLength returns a Middleware that truncates the formatted output to at
+// most n bytes. Nothing is written if the wrapped Formatter returns an error.
+func MaxLineLength(n int) Middleware {
+	return func(next Formatter) Formatter {
+		return FormatterFunc(func(w io.Writer, r Record) error {
+			var buf bytes.Buffer
+			if err := next.Format(&buf, r); err != nil {
+				return err
+			}
+
+			if buf.Len() > n {
+				buf.Truncate(n)
+			}
+
+			_, err := w.Write(buf.Bytes())
+			return err
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package easyslog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// keysFormatter writes the message followed by each top-level key
+func keysFormatter(w io.Writer, r Record) error {
+	_, _ = w.Write([]byte(r.Message))
+	for _, attr := range r.Attrs {
+		_, _ = w.Write([]byte(" " + attr.Key + "=" + attr.Value.String()))
+	}
+
+	return nil
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Formatter) Formatter {
+			return FormatterFunc(func(w io.Writer, r Record) error {
+				calls = append(calls, name)
+				return next.Format(w, r)
+			})
+		}
+	}
+
+	var b bytes.Buffer
+	formatter := Chain(FormatterFunc(keysFormatter), trace("outer"), trace("inner"))
+	slog.New(New(&b, formatter, nil)).Info("msg")
+
+	require.Equal(t, []string{"outer", "inner"}, calls)
+}
+
+func TestWithStaticField(t *testing.T) {
+	var b bytes.Buffer
+	formatter := Chain(FormatterFunc(keysFormatter), WithStaticField("env", slog.StringValue("prod")))
+	l := slog.New(New(&b, formatter, nil))
+
+	l.Info("one", "a", 1)
+	l.Info("two")
+
+	require.Equal(t, "one a=1 env=prod\ntwo env=prod\n", b.String())
+}
+
+func TestMaxLineLength(t *testing.T) {
+	var b bytes.Buffer
+	formatter := Chain(FormatterFunc(keysFormatter), MaxLineLength(8))
+	l := slog.New(New(&b, formatter, nil))
+
+	l.Info("short")
+	l.Info("a much longer message")
+
+	require.Equal(t, "short\na much l\n", b.String())
+}
+
+func TestMiddlewareOrderingAffectsOutput(t *testing.T) {
+	var truncatedFirst, staticFirst bytes.Buffer
+
+	slog.New(New(&truncatedFirst, Chain(FormatterFunc(keysFormatter), MaxLineLength(10), WithStaticField("k", slog.StringValue("v"))), nil)).Info("message")
+	slog.New(New(&staticFirst, Chain(FormatterFunc(keysFormatter), WithStaticField("k", slog.StringValue("v")), MaxLineLength(20)), nil)).Info("message")
+
+	require.Equal(t, "message k=\n", truncatedFirst.String())
+	require.Equal(t, "message k=v\n", staticFirst.String())
+}
+
+func TestMiddlewareErrorPropagation(t *testing.T) {
+	boom := errors.New("boom")
+	failing := FormatterFunc(func(w io.Writer, r Record) error {
+		_, _ = w.Write([]byte("partial"))
+		return boom
+	})
+
+	var b bytes.Buffer
+	handler := New(&b, Chain(failing, WithStaticField("k", slog.StringValue("v")), MaxLineLength(3)), nil)
+
+	err := slog.New(handler).Handler().Handle(context.Background(), slog.Record{Message: "msg"})
+	require.ErrorIs(t, err, boom)
+	require.Empty(t, b.String())
+}
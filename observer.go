@@ -0,0 +1,39 @@
+package easyslog
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Drop reasons reported to Observer.ObserveDrop.
+const (
+	// DropFormatError is reported when the formatter returns an error or
+	// panics.
+	DropFormatError = "format_error"
+	// DropWriteError is reported when writing or syncing the formatted line
+	// fails.
+	DropWriteError = "write_error"
+	// DropClosed is reported when a line is handled after Close.
+	DropClosed = "closed"
+	// DropSampled is reported when Options.Sampler drops a record.
+	DropSampled = "sampled"
+	// DropInvalidLine is reported when Options.ValidateLine rejects a line
+	// and SanitizeInvalidLines isn't set.
+	DropInvalidLine = "invalid_line"
+	// DropSuppressed is reported when a record is dropped because writes
+	// are suppressed after Options.WriteFailureThreshold write errors.
+	DropSuppressed = "suppressed"
+)
+
+// Observer receives metrics about the lines handled by EasySlog. It's called
+// synchronously from Handle, so implementations should be cheap and safe for
+// concurrent use.
+type Observer interface {
+	// ObserveRecord is called after a line is written, with its size in
+	// bytes (including the trailing newline) and the time spent formatting
+	// and writing it. The size is zero for records passed to a RecordWriter.
+	ObserveRecord(level slog.Level, bytes int, dur time.Duration)
+	// ObserveDrop is called when a line is not written, with one of the Drop
+	// reasons.
+	ObserveDrop(level slog.Level, reason string)
+}
@@ -0,0 +1,85 @@
+package easyslog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeObserver struct {
+	mu      sync.Mutex
+	records map[slog.Level]int
+	bytes   int
+	drops   map[string]int
+}
+
+func newFakeObserver() *fakeObserver {
+	return &fakeObserver{records: map[slog.Level]int{}, drops: map[string]int{}}
+}
+
+func (o *fakeObserver) ObserveRecord(level slog.Level, bytes int, dur time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.records[level]++
+	o.bytes += bytes
+}
+
+func (o *fakeObserver) ObserveDrop(level slog.Level, reason string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.drops[reason]++
+}
+
+type errorFormatter struct{}
+
+func (errorFormatter) Format(w io.Writer, record Record) error {
+	return errors.New("nope")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestObserverRecords(t *testing.T) {
+	observer := newFakeObserver()
+	var b bytes.Buffer
+	l := slog.New(New(&b, &FastJSONFormatter{}, &Options{Observer: observer}))
+
+	l.Info("one")
+	l.Info("two")
+	l.Error("three")
+	l.Debug("disabled")
+
+	require.Equal(t, map[slog.Level]int{slog.LevelInfo: 2, slog.LevelError: 1}, observer.records)
+	require.Equal(t, b.Len(), observer.bytes)
+	require.Empty(t, observer.drops)
+}
+
+func TestObserverDrops(t *testing.T) {
+	observer := newFakeObserver()
+	l := slog.New(New(io.Discard, errorFormatter{}, &Options{Observer: observer}))
+	l.Info("bad")
+	l.Warn("bad")
+
+	observer2 := newFakeObserver()
+	l = slog.New(New(io.Discard, panicFormatter{value: "boom"}, &Options{Observer: observer2}))
+	l.Info("panic")
+
+	observer3 := newFakeObserver()
+	l = slog.New(New(failingWriter{}, &FastJSONFormatter{}, &Options{Observer: observer3}))
+	l.Error("bad")
+
+	require.Equal(t, map[string]int{DropFormatError: 2}, observer.drops)
+	require.Equal(t, map[string]int{DropFormatError: 1}, observer2.drops)
+	require.Equal(t, map[string]int{DropWriteError: 1}, observer3.drops)
+	require.Empty(t, observer.records)
+	require.Empty(t, observer3.records)
+}
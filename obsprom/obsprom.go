@@ -0,0 +1,215 @@
+// Package obsprom provides an easyslog.Observer that tracks log volume as
+// Prometheus-style counters and histograms and exposes them in the Prometheus
+// text exposition format. It has no dependencies outside the standard
+// library, so it can be scraped directly or bridged into an existing
+// registry.
+package obsprom
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+var (
+	// DefaultByteBuckets are the upper bounds, in bytes, of the line size
+	// histogram when Options.ByteBuckets is empty.
+	DefaultByteBuckets = []float64{64, 128, 256, 512, 1024, 4096, 16384}
+	// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+	// histogram when Options.LatencyBuckets is empty.
+	DefaultLatencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01}
+)
+
+// Options to configure an Observer.
+type Options struct {
+	// Namespace prefixes every metric name, e.g. `myapp_log_records_total`.
+	// Defaults to "easyslog".
+	Namespace string
+	// ByteBuckets are the histogram bounds for line sizes.
+	ByteBuckets []float64
+	// LatencyBuckets are the histogram bounds for format+write latency, in
+	// seconds.
+	LatencyBuckets []float64
+}
+
+// Observer implements easyslog.Observer. It's safe for concurrent use.
+type Observer struct {
+	namespace string
+
+	mu      sync.Mutex
+	records map[string]uint64
+	drops   map[dropKey]uint64
+	bytes   *histogram
+	latency *histogram
+}
+
+var _ easyslog.Observer = (*Observer)(nil)
+var _ http.Handler = (*Observer)(nil)
+
+type dropKey struct {
+	level  string
+	reason string
+}
+
+// New returns an Observer. opts may be nil.
+func New(opts *Options) *Observer {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "easyslog"
+	}
+
+	byteBuckets := opts.ByteBuckets
+	if len(byteBuckets) == 0 {
+		byteBuckets = DefaultByteBuckets
+	}
+
+	latencyBuckets := opts.LatencyBuckets
+	if len(latencyBuckets) == 0 {
+		latencyBuckets = DefaultLatencyBuckets
+	}
+
+	return &Observer{
+		namespace: namespace,
+		records:   map[string]uint64{},
+		drops:     map[dropKey]uint64{},
+		bytes:     newHistogram(byteBuckets),
+		latency:   newHistogram(latencyBuckets),
+	}
+}
+
+// ObserveRecord implements easyslog.Observer.
+func (o *Observer) ObserveRecord(level slog.Level, bytes int, dur time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.records[level.String()]++
+	o.bytes.observe(float64(bytes))
+	o.latency.observe(dur.Seconds())
+}
+
+// ObserveDrop implements easyslog.Observer.
+func (o *Observer) ObserveDrop(level slog.Level, reason string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.drops[dropKey{level: level.String(), reason: reason}]++
+}
+
+// Records returns how many lines were written at the given level.
+func (o *Observer) Records(level slog.Level) uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.records[level.String()]
+}
+
+// Drops returns how many lines were dropped at the given level for reason.
+func (o *Observer) Drops(level slog.Level, reason string) uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.drops[dropKey{level: level.String(), reason: reason}]
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (o *Observer) WriteTo(w io.Writer) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var b strings.Builder
+
+	name := o.namespace + "_log_records_total"
+	fmt.Fprintf(&b, "# HELP %s Log lines written, by level.\n# TYPE %s counter\n", name, name)
+	for _, level := range sortedKeys(o.records) {
+		fmt.Fprintf(&b, "%s{level=%q} %d\n", name, level, o.records[level])
+	}
+
+	name = o.namespace + "_log_drops_total"
+	fmt.Fprintf(&b, "# HELP %s Log lines dropped, by level and reason.\n# TYPE %s counter\n", name, name)
+	drops := make([]dropKey, 0, len(o.drops))
+	for key := range o.drops {
+		drops = append(drops, key)
+	}
+	sort.Slice(drops, func(i, j int) bool {
+		if drops[i].level != drops[j].level {
+			return drops[i].level < drops[j].level
+		}
+		return drops[i].reason < drops[j].reason
+	})
+	for _, key := range drops {
+		fmt.Fprintf(&b, "%s{level=%q,reason=%q} %d\n", name, key.level, key.reason, o.drops[key])
+	}
+
+	o.bytes.write(&b, o.namespace+"_log_line_bytes", "Size of written log lines in bytes.")
+	o.latency.write(&b, o.namespace+"_log_write_seconds", "Time spent formatting and writing log lines.")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics so the Observer can be mounted as a scrape
+// endpoint, e.g. `http.Handle("/metrics", observer)`.
+func (o *Observer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = o.WriteTo(w)
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+
+	i := sort.SearchFloat64s(h.bounds, v)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+}
+
+func (h *histogram) write(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	// Buckets are cumulative in the exposition format.
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{le=%q} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(b, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count %d\n", name, h.count)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
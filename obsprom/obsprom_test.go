@@ -0,0 +1,61 @@
+package obsprom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserver(t *testing.T) {
+	observer := New(&Options{ByteBuckets: []float64{8, 32}, LatencyBuckets: []float64{1}})
+	format := easyslog.FormatterFunc(func(w io.Writer, r easyslog.Record) error {
+		if r.Message == "fail" {
+			return errors.New("fail")
+		}
+		_, err := io.WriteString(w, r.Message)
+		return err
+	})
+	l := slog.New(easyslog.New(io.Discard, format, &easyslog.Options{Observer: observer}))
+
+	l.Info("hi")
+	l.Info("a much longer line")
+	l.Error("oops")
+	l.Warn("fail")
+
+	require.Equal(t, uint64(2), observer.Records(slog.LevelInfo))
+	require.Equal(t, uint64(1), observer.Records(slog.LevelError))
+	require.Equal(t, uint64(1), observer.Drops(slog.LevelWarn, easyslog.DropFormatError))
+
+	var b bytes.Buffer
+	_, err := observer.WriteTo(&b)
+	require.NoError(t, err)
+
+	out := b.String()
+	require.Contains(t, out, "# TYPE easyslog_log_records_total counter\n")
+	require.Contains(t, out, `easyslog_log_records_total{level="INFO"} 2`+"\n")
+	require.Contains(t, out, `easyslog_log_records_total{level="ERROR"} 1`+"\n")
+	require.Contains(t, out, `easyslog_log_drops_total{level="WARN",reason="format_error"} 1`+"\n")
+	require.Contains(t, out, `easyslog_log_line_bytes_bucket{le="8"} 2`+"\n")
+	require.Contains(t, out, `easyslog_log_line_bytes_bucket{le="32"} 3`+"\n")
+	require.Contains(t, out, `easyslog_log_line_bytes_bucket{le="+Inf"} 3`+"\n")
+	require.Contains(t, out, "easyslog_log_line_bytes_sum 27\n")
+	require.Contains(t, out, "easyslog_log_write_seconds_count 3\n")
+}
+
+func TestObserverServeHTTP(t *testing.T) {
+	observer := New(&Options{Namespace: "app"})
+	observer.ObserveRecord(slog.LevelInfo, 10, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	observer.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	require.Equal(t, "text/plain; version=0.0.4", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), `app_log_records_total{level="INFO"} 1`)
+}
@@ -0,0 +1,180 @@
+// Package preset provides EasySlog handlers configured for development and
+// production, so programs don't each wire up the same formatter and options.
+// It lives outside the easyslog package because it builds on prettylog and
+// jsonlog, which import easyslog.
+package preset
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/jsonlog"
+	"github.com/blakewilliams/easyslog/prettylog"
+	"github.com/mattn/go-isatty"
+)
+
+// FormatEnv is the environment variable NewAuto reads to choose a preset:
+// `development` or `pretty` for NewDevelopment, and `production` or `json`
+// for NewProduction.
+const FormatEnv = "LOG_FORMAT"
+
+// SourceKey is the key of the attribute NewDevelopment adds with the file and
+// line that logged the record.
+const SourceKey = "source"
+
+const (
+	// DefaultSampleFirst is the number of Info and lower records
+	// NewProduction writes each second before sampling them.
+	DefaultSampleFirst = 100
+	// DefaultSampleThereafter is how often NewProduction writes an Info or
+	// lower record once DefaultSampleFirst is reached, e.g. every 100th.
+	DefaultSampleThereafter = 100
+)
+
+// NewDevelopment returns a handler for local development: Debug level, the
+// prettylog formatter with color if w supports it, a SourceKey attribute on
+// every record, and Error and above rendered in prettylog's MultiLine layout.
+// The non-zero fields of opts, which may be nil, replace the preset's.
+func NewDevelopment(w io.Writer, opts *easyslog.Options) *easyslog.EasySlog {
+	formatter := prettylog.New(w)
+	multiLine := formatter
+	multiLine.MultiLine = true
+
+	return easyslog.New(w, formatter, merge(easyslog.Options{
+		Level:           slog.LevelDebug,
+		TransformRecord: addSource,
+		FormatterFor: func(level slog.Level) easyslog.Formatter {
+			if level >= slog.LevelError {
+				return multiLine
+			}
+
+			return nil
+		},
+	}, opts))
+}
+
+// NewProduction returns a handler for production: Info level and the jsonlog
+// formatter, which writes times as RFC 3339 with nanoseconds. Records below
+// Warn are sampled with Sampler(DefaultSampleFirst, DefaultSampleThereafter).
+// The non-zero fields of opts, which may be nil, replace the preset's.
+func NewProduction(w io.Writer, opts *easyslog.Options) *easyslog.EasySlog {
+	return easyslog.New(w, jsonlog.Formatter{}, merge(easyslog.Options{
+		Level:   slog.LevelInfo,
+		Sampler: Sampler(DefaultSampleFirst, DefaultSampleThereafter),
+	}, opts))
+}
+
+// NewAuto returns NewDevelopment or NewProduction for w, as chosen by
+// FormatEnv. If it's unset or unknown, NewDevelopment is used when w is a
+// terminal and NewProduction otherwise.
+func NewAuto(w io.Writer) *easyslog.EasySlog {
+	switch strings.ToLower(os.Getenv(FormatEnv)) {
+	case "development", "pretty":
+		return NewDevelopment(w, nil)
+	case "production", "json":
+		return NewProduction(w, nil)
+	}
+
+	if isTerminal(w) {
+		return NewDevelopment(w, nil)
+	}
+
+	return NewProduction(w, nil)
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// merge returns preset with the non-zero fields of opts copied over it. Zero
+// values can't be told apart from unset ones, so a preset's true bool can't
+// be turned off.
+func merge(preset easyslog.Options, opts *easyslog.Options) *easyslog.Options {
+	if opts == nil {
+		return &preset
+	}
+
+	dst := reflect.ValueOf(&preset).Elem()
+	src := reflect.ValueOf(opts).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if field := src.Field(i); !field.IsZero() {
+			dst.Field(i).Set(field)
+		}
+	}
+
+	return &preset
+}
+
+// addSource adds the SourceKey attribute to r, e.g. `main.go:12`.
+func addSource(r *easyslog.Record) {
+	if r.PC == 0 {
+		return
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+	if frame.File == "" {
+		return
+	}
+
+	r.Add(slog.String(SourceKey, filepath.Base(frame.File)+":"+strconv.Itoa(frame.Line)))
+}
+
+// Sampler returns an easyslog.Options.Sampler that writes the first records
+// below Warn each second, and every thereafter-th one after that. Warn and
+// above are always written. A thereafter of zero or less drops every record
+// past the first.
+func Sampler(first int, thereafter int) func(ctx context.Context, r slog.Record) bool {
+	s := &sampler{first: first, thereafter: thereafter}
+
+	return s.sample
+}
+
+type sampler struct {
+	first      int
+	thereafter int
+
+	mu     sync.Mutex
+	second int64
+	count  int
+}
+
+func (s *sampler) sample(_ context.Context, r slog.Record) bool {
+	if r.Level >= slog.LevelWarn {
+		return true
+	}
+
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if second := now.Unix(); second != s.second {
+		s.second = second
+		s.count = 0
+	}
+
+	s.count++
+	if s.count <= s.first {
+		return true
+	}
+
+	return s.thereafter > 0 && (s.count-s.first)%s.thereafter == 0
+}
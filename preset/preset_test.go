@@ -0,0 +1,115 @@
+package preset
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDevelopment(t *testing.T) {
+	var b bytes.Buffer
+	handler := NewDevelopment(&b, nil)
+	require.True(t, handler.Enabled(context.Background(), slog.LevelDebug))
+
+	l := slog.New(handler)
+	l.Debug("loading", "id", 1)
+	require.Regexp(t, `^\[DBG\] loading id=1 source=preset_test\.go:\d+ \n$`, b.String())
+
+	b.Reset()
+	l.Error("failed", "id", 1)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	require.Equal(t, []string{"[ERR] failed", "  id: 1"}, lines[:2])
+	require.Regexp(t, `^  source: preset_test\.go:\d+$`, lines[2])
+}
+
+func TestNewProduction(t *testing.T) {
+	var b bytes.Buffer
+	handler := NewProduction(&b, nil)
+	require.False(t, handler.Enabled(context.Background(), slog.LevelDebug))
+	require.True(t, handler.Enabled(context.Background(), slog.LevelInfo))
+
+	logged := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(logged, slog.LevelInfo, "hello", 0)))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(b.Bytes(), &line))
+	require.Equal(t, map[string]any{"time": "2024-01-02T03:04:05.000000006Z", "level": "INFO", "msg": "hello"}, line)
+}
+
+func TestProductionSampling(t *testing.T) {
+	var b bytes.Buffer
+	l := slog.New(NewProduction(&b, nil))
+
+	for i := 0; i < DefaultSampleFirst+2*DefaultSampleThereafter; i++ {
+		l.Info("msg")
+	}
+	l.Warn("always")
+
+	// All records land in the same second unless the test straddles one
+	lines := strings.Count(b.String(), "\n")
+	require.GreaterOrEqual(t, lines, DefaultSampleFirst+3)
+	require.Less(t, lines, DefaultSampleFirst+2*DefaultSampleThereafter)
+	require.Contains(t, b.String(), `"msg":"always"`)
+}
+
+func TestOptionsOverridePreset(t *testing.T) {
+	var b bytes.Buffer
+	handler := NewProduction(&b, &easyslog.Options{Level: slog.LevelDebug, Sampler: func(context.Context, slog.Record) bool { return false }})
+	require.True(t, handler.Enabled(context.Background(), slog.LevelDebug))
+
+	slog.New(handler).Info("dropped")
+	require.Zero(t, b.Len())
+
+	b.Reset()
+	slog.New(NewDevelopment(&b, &easyslog.Options{TransformRecord: func(r *easyslog.Record) {}, Level: slog.LevelWarn})).Warn("no source")
+	require.Equal(t, "[WRN] no source \n", b.String())
+}
+
+func TestSampler(t *testing.T) {
+	sample := Sampler(2, 3)
+	now := time.Now()
+
+	var kept []int
+	for i := 1; i <= 9; i++ {
+		if sample(context.Background(), slog.NewRecord(now, slog.LevelInfo, "msg", 0)) {
+			kept = append(kept, i)
+		}
+	}
+	require.Equal(t, []int{1, 2, 5, 8}, kept)
+
+	require.True(t, sample(context.Background(), slog.NewRecord(now, slog.LevelError, "msg", 0)))
+	// The count restarts every second
+	require.True(t, sample(context.Background(), slog.NewRecord(now.Add(time.Second), slog.LevelInfo, "msg", 0)))
+
+	never := Sampler(0, 0)
+	require.False(t, never(context.Background(), slog.NewRecord(now, slog.LevelDebug, "msg", 0)))
+}
+
+func TestNewAuto(t *testing.T) {
+	for env, dev := range map[string]bool{
+		"":            false,
+		"unknown":     false,
+		"json":        false,
+		"production":  false,
+		"pretty":      true,
+		"Development": true,
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(FormatEnv, env)
+
+			var b bytes.Buffer
+			handler := NewAuto(&b)
+			require.Equal(t, dev, handler.Enabled(context.Background(), slog.LevelDebug))
+
+			slog.New(handler).Warn("hello")
+			require.Equal(t, !dev, json.Valid(b.Bytes()), b.String())
+		})
+	}
+}
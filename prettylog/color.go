@@ -0,0 +1,69 @@
+package prettylog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Color is a foreground color for a level, rendered as an SGR escape
+// sequence when color is enabled. It's implemented by Basic, Color256, and
+// RGB.
+type Color interface {
+	attributes() []color.Attribute
+}
+
+// Basic is one of the 16 standard terminal colors, e.g. Basic(color.FgRed).
+type Basic color.Attribute
+
+// Color256 is an index into the 256-color xterm palette.
+type Color256 uint8
+
+// RGB is a 24-bit truecolor value.
+type RGB struct {
+	R, G, B uint8
+}
+
+var (
+	_ Color = Basic(0)
+	_ Color = Color256(0)
+	_ Color = RGB{}
+)
+
+func (c Basic) attributes() []color.Attribute {
+	return []color.Attribute{color.Attribute(c)}
+}
+
+func (c Color256) attributes() []color.Attribute {
+	return []color.Attribute{38, 5, color.Attribute(c)}
+}
+
+func (c RGB) attributes() []color.Attribute {
+	return []color.Attribute{38, 2, color.Attribute(c.R), color.Attribute(c.G), color.Attribute(c.B)}
+}
+
+// ParseColor parses a hex truecolor spec like `#ff8700` into an RGB, or a
+// palette index from 0 to 255 like `208` into a Color256.
+func ParseColor(spec string) (Color, error) {
+	if hex, ok := strings.CutPrefix(spec, "#"); ok {
+		if len(hex) != 6 {
+			return nil, errors.New("prettylog: hex color must have 6 digits: " + spec)
+		}
+
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return nil, errors.New("prettylog: invalid hex color: " + spec)
+		}
+
+		return RGB{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+	}
+
+	v, err := strconv.ParseUint(spec, 10, 8)
+	if err != nil {
+		return nil, errors.New("prettylog: invalid color: " + spec)
+	}
+
+	return Color256(v), nil
+}
@@ -0,0 +1,66 @@
+package prettylog
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendedColors(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+	color.NoColor = false
+
+	var buf bytes.Buffer
+	formatter := Formatter{Colors: map[slog.Level]Color{
+		slog.LevelInfo:  Color256(208),
+		slog.LevelError: RGB{R: 255, G: 135, B: 0},
+	}}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("msg")
+	l.Error("msg")
+	l.Warn("msg")
+
+	require.Equal(t,
+		"\x1b[38;5;208;1m[INF]\x1b[0m msg \n"+
+			"\x1b[38;2;255;135;0;1m[ERR]\x1b[0m msg \n"+
+			"\x1b[33;1m[WRN]\x1b[0m msg \n",
+		buf.String(),
+	)
+}
+
+func TestExtendedColorsNoColor(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+	color.NoColor = false
+
+	var buf bytes.Buffer
+	formatter := Formatter{NoColor: true, Colors: map[slog.Level]Color{slog.LevelInfo: RGB{R: 1, G: 2, B: 3}}}
+	l := slog.New(easyslog.New(&buf, formatter, nil))
+
+	l.Info("msg", "k", "v")
+
+	require.Equal(t, "[INF] msg k=v \n", buf.String())
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#ff8700")
+	require.NoError(t, err)
+	require.Equal(t, RGB{R: 255, G: 135, B: 0}, c)
+
+	c, err = ParseColor("208")
+	require.NoError(t, err)
+	require.Equal(t, Color256(208), c)
+
+	for _, spec := range []string{"#fff", "#gggggg", "256", "red"} {
+		_, err = ParseColor(spec)
+		require.Error(t, err, spec)
+	}
+}
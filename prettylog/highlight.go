@@ -0,0 +1,96 @@
+package prettylog
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Rule colors the values of leaf attributes matching it, regardless of the
+// record's level, e.g. errors in red even on Info lines.
+type Rule struct {
+	// Key is a glob, as with path.Match, for the attributes the rule applies
+	// to. A pattern without a dot is matched against the attribute's own key
+	// at any depth, e.g. `err`. A dotted pattern is matched against the keys
+	// of its enclosing groups and its own, one element each, e.g. `*.err`
+	// for an `err` in any top-level group. An empty Key matches every
+	// attribute.
+	Key string
+	// Match, when set, must also return true for the attribute's value.
+	Match func(v slog.Value) bool
+	// Color is the color the value is rendered in.
+	Color color.Attribute
+}
+
+// DefaultHighlightRules are used when Formatter.HighlightRules is nil. They
+// render error values, and the values of `err` and `error` keys, in red.
+var DefaultHighlightRules = []Rule{
+	{Match: isError, Color: color.FgRed},
+	{Key: "err", Color: color.FgRed},
+	{Key: "error", Color: color.FgRed},
+}
+
+func isError(v slog.Value) bool {
+	if v.Kind() != slog.KindAny {
+		return false
+	}
+
+	_, ok := v.Any().(error)
+	return ok
+}
+
+// highlight returns the color of the first of f's rules matching the leaf
+// with key whose enclosing groups have the keys in groups.
+func (f Formatter) highlight(groups []string, key string, v slog.Value) (color.Attribute, bool) {
+	rules := f.HighlightRules
+	if rules == nil {
+		rules = DefaultHighlightRules
+	}
+
+	for _, rule := range rules {
+		if rule.matches(groups, key, v) {
+			return rule.Color, true
+		}
+	}
+
+	return 0, false
+}
+
+func (rule Rule) matches(groups []string, key string, v slog.Value) bool {
+	if rule.Key != "" && !matchKey(rule.Key, groups, key) {
+		return false
+	}
+
+	return rule.Match == nil || rule.Match(v)
+}
+
+// matchKey matches pattern against key, or element by element against groups
+// followed by key when pattern is dotted.
+func matchKey(pattern string, groups []string, key string) bool {
+	if !strings.Contains(pattern, ".") {
+		ok, _ := path.Match(pattern, key)
+		return ok
+	}
+
+	for i := 0; i <= len(groups); i++ {
+		element, rest, more := strings.Cut(pattern, ".")
+		name := key
+		if i < len(groups) {
+			name = groups[i]
+		}
+
+		// The pattern must run out exactly at key
+		if more != (i < len(groups)) {
+			return false
+		}
+
+		if ok, _ := path.Match(element, name); !ok {
+			return false
+		}
+		pattern = rest
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package prettylog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultHighlightRules(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{ForceColor: true}, nil)).Info("failed",
+		"cause", errors.New("boom"),
+		"err", "timeout",
+		slog.Group("db", "error", "gone"),
+		"ok", "fine",
+	)
+
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m failed "+
+		"\x1b[34;1mcause\x1b[0m=\x1b[31mboom\x1b[0m "+
+		"\x1b[34;1merr\x1b[0m=\x1b[31mtimeout\x1b[0m "+
+		"\x1b[34;1mdb.error\x1b[0m=\x1b[31mgone\x1b[0m "+
+		"\x1b[34;1mok\x1b[0m=fine \n", buf.String())
+
+	// An empty slice turns highlighting off
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{ForceColor: true, HighlightRules: []Rule{}}, nil)).Info("failed", "err", "timeout")
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m failed \x1b[34;1merr\x1b[0m=timeout \n", buf.String())
+}
+
+func TestHighlightRules(t *testing.T) {
+	isTrue := func(v slog.Value) bool { return v.Equal(slog.BoolValue(true)) }
+	f := Formatter{
+		ForceColor: true,
+		HighlightRules: []Rule{
+			{Key: "*.err", Color: color.FgMagenta},
+			{Key: "ret*", Match: isTrue, Color: color.FgYellow},
+			{Key: "timeout", Match: isTrue, Color: color.FgYellow},
+			{Key: "retry", Color: color.FgGreen},
+		},
+	}
+
+	for _, tc := range []struct {
+		attr  slog.Attr
+		color color.Attribute
+	}{
+		{slog.Group("db", "err", "x"), color.FgMagenta},
+		// Dotted patterns match one group per element
+		{slog.Group("a", slog.Group("b", "err", "x")), 0},
+		{slog.String("err", "x"), 0},
+		{slog.Bool("retry", true), color.FgYellow},
+		// Falls through to the next rule when the predicate doesn't match
+		{slog.Bool("retry", false), color.FgGreen},
+		{slog.Group("http", slog.Bool("timeout", true)), color.FgYellow},
+		{slog.Bool("timeout", false), 0},
+	} {
+		var buf bytes.Buffer
+		slog.New(easyslog.New(&buf, f, nil)).Info("msg", tc.attr)
+
+		leaf := tc.attr
+		for leaf.Value.Kind() == slog.KindGroup {
+			leaf = leaf.Value.Group()[0]
+		}
+		value := leaf.Value.String()
+
+		if tc.color == 0 {
+			require.Contains(t, buf.String(), "\x1b[0m="+value+" ", tc.attr)
+		} else {
+			require.Contains(t, buf.String(), fmt.Sprintf("=\x1b[%dm%s\x1b[0m", tc.color, value), tc.attr)
+		}
+	}
+}
+
+func TestHighlightNoColor(t *testing.T) {
+	for _, f := range []Formatter{{NoColor: true, ForceColor: true}, {ColorMode: Never}} {
+		var buf bytes.Buffer
+		slog.New(easyslog.New(&buf, f, nil)).Info("failed", "err", errors.New("boom"))
+		require.Equal(t, "[INF] failed err=boom \n", buf.String())
+	}
+}
+
+func TestHighlightAfterValueColorFunc(t *testing.T) {
+	var buf bytes.Buffer
+	f := Formatter{
+		ForceColor: true,
+		ValueColorFunc: func(path []string, a *easyslog.Attr) (color.Attribute, bool) {
+			return color.FgCyan, a.Key == "err"
+		},
+	}
+
+	slog.New(easyslog.New(&buf, f, nil)).Info("failed", "err", "x", "error", "y")
+	require.Contains(t, buf.String(), "=\x1b[36mx\x1b[0m")
+	require.Contains(t, buf.String(), "=\x1b[31my\x1b[0m")
+}
@@ -0,0 +1,118 @@
+package prettylog
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteUnits are the binary units sizes are rendered in by Humanize.
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// humanize returns v rendered as described by Formatter.Humanize, reporting
+// false for values it leaves to the default rendering. LogValuers are
+// humanized by the value they resolve to.
+func (f Formatter) humanize(key string, v slog.Value) (string, bool) {
+	if !f.Humanize {
+		return "", false
+	}
+
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindDuration:
+		return humanizeDuration(v.Duration()), true
+	case slog.KindInt64:
+		n := v.Int64()
+		if isSizeKey(key) {
+			return humanizeBytes(n < 0, uint64Abs(n)), true
+		}
+
+		return f.groupThousands(n < 0, uint64Abs(n))
+	case slog.KindUint64:
+		if isSizeKey(key) {
+			return humanizeBytes(false, v.Uint64()), true
+		}
+
+		return f.groupThousands(false, v.Uint64())
+	}
+
+	return "", false
+}
+
+// humanizeDuration rounds d to three significant digits, e.g. `1.23ms` or
+// `2m10s`.
+func humanizeDuration(d time.Duration) string {
+	digits := len(strconv.FormatUint(uint64Abs(int64(d)), 10))
+	if digits > 3 {
+		d = d.Round(time.Duration(math.Pow10(digits - 3)))
+	}
+
+	return d.String()
+}
+
+func isSizeKey(key string) bool {
+	return key == "bytes" || key == "size" || strings.HasSuffix(key, "_bytes") || strings.HasSuffix(key, "_size")
+}
+
+// humanizeBytes renders n bytes in the largest binary unit it reaches, with
+// one decimal, e.g. `1.4MiB`, and in bytes below 1KiB, e.g. `512B`.
+func humanizeBytes(negative bool, n uint64) string {
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+
+	if n < 1024 {
+		return sign + strconv.FormatUint(n, 10) + byteUnits[0]
+	}
+
+	v := float64(n)
+	unit := 0
+	for v >= 1024 && unit < len(byteUnits)-1 {
+		v /= 1024
+		unit++
+	}
+
+	// Values just under the next unit would otherwise round up to 1024.0
+	if math.Round(v*10) >= 1024*10 && unit < len(byteUnits)-1 {
+		v /= 1024
+		unit++
+	}
+
+	return sign + strconv.FormatFloat(v, 'f', 1, 64) + byteUnits[unit]
+}
+
+// groupThousands renders n with f.ThousandsSeparator between every three
+// digits, reporting false when there's no separator or n is below 10000.
+func (f Formatter) groupThousands(negative bool, n uint64) (string, bool) {
+	if f.ThousandsSeparator == "" || n < 10000 {
+		return "", false
+	}
+
+	digits := strconv.FormatUint(n, 10)
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(f.ThousandsSeparator)
+		}
+		b.WriteRune(digit)
+	}
+
+	return b.String(), true
+}
+
+// uint64Abs returns the magnitude of n, including for math.MinInt64.
+func uint64Abs(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+
+	return uint64(n)
+}
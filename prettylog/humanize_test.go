@@ -0,0 +1,126 @@
+package prettylog
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHumanizeDurations(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                                      "0s",
+		999 * time.Nanosecond:                  "999ns",
+		1234567 * time.Nanosecond:              "1.23ms",
+		-1234567 * time.Nanosecond:             "-1.23ms",
+		4500 * time.Millisecond:                "4.5s",
+		2*time.Minute + 10*time.Second:         "2m10s",
+		2*time.Minute + 10600*time.Millisecond: "2m11s",
+		// Just under a unit rounds up into it
+		999600 * time.Nanosecond:                  "1ms",
+		59999 * time.Millisecond:                  "1m0s",
+		time.Hour + 2*time.Minute + 3*time.Second: "1h2m0s",
+	} {
+		require.Equal(t, want, humanizeDuration(d), d.String())
+	}
+}
+
+func TestHumanizeBytes(t *testing.T) {
+	for _, tc := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{512, "512B"},
+		{1023, "1023B"},
+		{1024, "1.0KiB"},
+		{1483930, "1.4MiB"},
+		{-1483930, "-1.4MiB"},
+		// Just under a unit boundary
+		{1048575, "1.0MiB"},
+		{1048524, "1023.9KiB"},
+		{5 << 30, "5.0GiB"},
+		{math.MaxInt64, "8.0EiB"},
+		{math.MinInt64, "-8.0EiB"},
+	} {
+		require.Equal(t, tc.want, humanizeBytes(tc.n < 0, uint64Abs(tc.n)), tc.n)
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{Humanize: true, ThousandsSeparator: ","}, nil)
+
+	slog.New(handler).Info("done",
+		"took", 1234567*time.Nanosecond,
+		"bytes", 1483930,
+		"body_size", uint64(2048),
+		"resp_bytes", -4096,
+		"count", 1483930,
+		"negative", -1000000,
+		"port", 8080,
+		// Size keys holding other kinds are left untouched
+		"page_size", "large",
+		"cache_bytes", 1.5,
+		slog.Group("db", "rows", 10000, "query_size", 999),
+	)
+
+	require.Equal(t, "[INF] done took=1.23ms bytes=1.4MiB body_size=2.0KiB resp_bytes=-4.0KiB count=1,483,930 "+
+		"negative=-1,000,000 port=8080 page_size=large cache_bytes=1.5 db.rows=10,000 db.query_size=999B \n", buf.String())
+}
+
+func TestHumanizeDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{ThousandsSeparator: ","}, nil)
+	slog.New(handler).Info("done", "took", 1234567*time.Nanosecond, "bytes", 1483930, "count", 1483930)
+
+	require.Equal(t, "[INF] done took=1.234567ms bytes=1483930 count=1483930 \n", buf.String())
+
+	// Without a separator counts are left as-is
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{Humanize: true}, nil)).Info("done", "count", 1483930)
+	require.Equal(t, "[INF] done count=1483930 \n", buf.String())
+}
+
+// sizeValuer resolves to a size in bytes.
+type sizeValuer int64
+
+func (v sizeValuer) LogValue() slog.Value { return slog.Int64Value(int64(v)) }
+
+func TestHumanizeLogValuer(t *testing.T) {
+	var buf bytes.Buffer
+	record := easyslog.Record{Level: slog.LevelInfo, Message: "done", Attrs: []*easyslog.Attr{
+		{Key: "bytes", Value: slog.AnyValue(sizeValuer(2048))},
+	}}
+	require.NoError(t, Formatter{Humanize: true}.Format(&buf, record))
+
+	require.Equal(t, "[INF] done bytes=2.0KiB ", buf.String())
+}
+
+func TestHumanizeStyling(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{Humanize: true, ThousandsSeparator: "\x1b"}, nil)).Info("done", "count", 10000)
+	require.Equal(t, `[INF] done count=10\x1b000 `+"\n", buf.String())
+
+	buf.Reset()
+	f := Formatter{Humanize: true, ForceColor: true, HighlightRules: []Rule{{Key: "*_bytes", Color: color.FgYellow}}}
+	slog.New(easyslog.New(&buf, f, nil)).Info("done", "resp_bytes", 2048)
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m done \x1b[34;1mresp_bytes\x1b[0m=\x1b[33m2.0KiB\x1b[0m \n", buf.String())
+}
+
+func TestHumanizeKeepsRecordValues(t *testing.T) {
+	var pretty, raw bytes.Buffer
+	var record easyslog.Record
+	handler := easyslog.New(&pretty, Formatter{Humanize: true}, &easyslog.Options{Tap: func(r easyslog.Record) { record = r.Clone() }})
+
+	slog.New(handler).Info("done", "bytes", 2048)
+	require.NoError(t, Formatter{}.Format(&raw, record))
+
+	require.Equal(t, "[INF] done bytes=2.0KiB \n", pretty.String())
+	require.Equal(t, "[INF] done bytes=2048 ", raw.String())
+}
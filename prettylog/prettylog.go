@@ -1,25 +1,124 @@
 package prettylog
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/blakewilliams/easyslog"
 	"github.com/fatih/color"
 )
 
 // Formatter implements easyslog.Formatter and can be used to render "pretty"
-// slog logs.
+// slog logs. A Record.HandlerName is rendered dimmed in brackets after the
+// level, e.g. `[INF] [server.http] started`.
 type Formatter struct {
-	// Determines if color is used or not
+	// Color is decided in order of precedence: NoColor disables it, then
+	// ForceColor enables it, then ColorMode applies, which by default leaves
+	// it to fatih/color's detection of a terminal. ColorActive reports the
+	// result.
+	//
+	// NoColor disables color regardless of the other fields.
 	NoColor bool
+	// ForceColor enables color even when writing to a file or pipe, e.g. for
+	// CI log viewers that render escape sequences.
+	ForceColor bool
+	// ColorMode determines when color is used if neither NoColor nor
+	// ForceColor is set. Defaults to Auto.
+	ColorMode ColorMode
+	// GroupTag renders the groups opened via WithGroup as a `[http.db]` tag
+	// after the level instead of prefixing every key with them.
+	GroupTag bool
+	// LevelNames, when set, overrides Levels for this formatter. Levels are
+	// rendered in brackets and fall back to Record.LevelString(), e.g.
+	// `[info]` or `[INFO+2]`. Without it, Record.LevelName is used when set
+	// via easyslog.Options.LevelNames.
+	LevelNames easyslog.LevelNamer
+	// RawValues writes values exactly as they are. By default control
+	// characters in values are escaped (e.g. `\n`) so input can't corrupt the
+	// terminal or inject fake log lines. Only use it for trusted input.
+	RawValues bool
+	// StripANSI removes ANSI escape sequences from values instead of escaping
+	// them. It has no effect when RawValues is set.
+	StripANSI bool
+	// PrefixKey is the dot-separated path of an attribute, e.g. `request_id`,
+	// whose value is rendered right after the level instead of in the
+	// attribute list.
+	PrefixKey string
+	// Colors, when set, overrides LevelColors for this formatter and accepts
+	// 256-color and truecolor values, e.g. `Color256(208)` or
+	// `RGB{255, 135, 0}`. Levels not in it fall back to LevelColors.
+	Colors map[slog.Level]Color
+	// MultiLine renders the level and message on the first line and each
+	// attribute on its own indented line as `  key: value`, with groups
+	// nested one level deeper under a `  group:` line.
+	MultiLine bool
+	// GroupStyle determines how groups are rendered on a single line.
+	// Defaults to Dotted. It has no effect with MultiLine.
+	GroupStyle GroupStyle
+	// ValueColorFunc, when set, is called for each leaf attribute with the
+	// keys of its enclosing groups, including those opened via WithGroup, and
+	// returning true renders its value in the returned color, e.g. red for
+	// `status` values of 500 and above. path must not be retained. It's not
+	// called when color is disabled.
+	ValueColorFunc func(path []string, a *easyslog.Attr) (color.Attribute, bool)
+	// HighlightRules color the values of matching leaf attributes regardless
+	// of the record's level, the first matching rule winning. They're used
+	// when ValueColorFunc is unset or returns false, and not at all when
+	// color is disabled. Defaults to DefaultHighlightRules when nil; set it
+	// to an empty slice to turn highlighting off, or extend
+	// DefaultHighlightRules, e.g. to render `retry` in yellow when it's true.
+	HighlightRules []Rule
+	// MessageLast writes the message after the attributes instead of before
+	// them, e.g. `[INF] status=200 took=3ms request done`. It has no effect
+	// with MultiLine.
+	MessageLast bool
+	// Humanize renders values for people rather than machines: durations
+	// rounded to three significant digits, e.g. `1.23ms`, and integer
+	// values of `bytes` and `size` keys, or keys ending in `_bytes` or
+	// `_size`, in binary units, e.g. `1.4MiB`. Records keep their values,
+	// so other formatters on the same handler are unaffected.
+	Humanize bool
+	// ThousandsSeparator, when set with Humanize, groups the digits of other
+	// integer values of 10000 and above, e.g. `1,483,930` with ",".
+	ThousandsSeparator string
 }
 
+// GroupStyle determines how a Formatter renders the attributes of groups.
+type GroupStyle int
+
+const (
+	// Dotted prefixes every key with the names of its groups, e.g.
+	// `request.method=GET request.path=/`.
+	Dotted GroupStyle = iota
+	// Braced wraps the attributes of each group in braces after its name,
+	// e.g. `request={method=GET path=/}`. Braces are dimmed when color is
+	// used.
+	Braced
+)
+
 var _ easyslog.Formatter = (*Formatter)(nil)
 
-// Levels maps a level to a specific prefix to log. Levels not in this list will
-// render as unknown `[UNK]`
+func init() {
+	easyslog.RegisterFormatter("pretty", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+// Levels maps a level to a specific prefix to log. Levels not in this list
+// render relative to the nearest standard level below them, following slog's
+// convention, e.g. `[INF+2]` or `[DBG-4]`. If that standard level isn't in the
+// list either the level renders as unknown `[UNK]`.
 var Levels = map[slog.Level]string{
 	slog.LevelDebug: "[DBG]",
 	slog.LevelInfo:  "[INF]",
@@ -42,39 +141,359 @@ func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
 		colorAttr = attr
 	}
 	c := color.New(colorAttr)
+	if levelColor, ok := f.Colors[record.Level]; ok {
+		c = color.New(levelColor.attributes()...)
+	}
 
-	if f.NoColor {
+	if f.ColorActive() {
+		c.EnableColor()
+	} else {
 		c.DisableColor()
 	}
 
-	level := "[UNK]"
-	if definedLevel, ok := Levels[record.Level]; ok {
-		level = definedLevel
+	level := levelLabel(record.Level)
+	switch {
+	case f.LevelNames != nil:
+		level = "[" + f.LevelNames.RecordName(record) + "]"
+	case record.LevelName != "":
+		level = "[" + record.LevelName + "]"
 	}
 
-	c.Add(color.Bold).Fprint(w, level)
-	_, _ = w.Write([]byte(" "))
-	_, _ = w.Write([]byte(record.Message))
+	// Sprint rather than Fprint, which checks the global color.NoColor before
+	// writing the reset sequence even when color was enabled explicitly.
+	_, _ = io.WriteString(w, c.Add(color.Bold).Sprint(level))
 	_, _ = w.Write([]byte(" "))
 
-	for _, attr := range record.Attrs {
-		f.formatAttr(w, c, attr, []string{})
+	dim := color.New(color.Faint)
+	if f.ColorActive() {
+		dim.EnableColor()
+	} else {
+		dim.DisableColor()
+	}
+
+	if record.HandlerName != "" {
+		_, _ = io.WriteString(w, dim.Sprint("["+record.HandlerName+"]"))
+		_, _ = w.Write([]byte(" "))
+	}
+
+	attrs := record.Attrs
+	if f.PrefixKey != "" {
+		path := strings.Split(f.PrefixKey, ".")
+		if value, ok := record.Get(path...); ok {
+			_, _ = io.WriteString(w, c.Sprint(f.value(value)))
+			_, _ = w.Write([]byte(" "))
+			attrs = record.Without(path...).Attrs
+		}
+	}
+
+	var openGroups []string
+	if f.GroupTag && len(record.Groups) > 0 {
+		openGroups = record.Groups
+		_, _ = io.WriteString(w, c.Sprint("["+strings.Join(openGroups, ".")+"]"))
+		_, _ = w.Write([]byte(" "))
+	}
+
+	if f.MultiLine {
+		_, _ = w.Write([]byte(record.Message))
+		for _, attr := range attrs {
+			f.formatAttrLine(w, c, attr, nil, 1, openGroups)
+		}
+		return nil
+	}
+
+	// With MessageLast the attributes are buffered so the space following
+	// the last one can be dropped when there's no message after it.
+	out := w
+	var attrBuf bytes.Buffer
+	if f.MessageLast {
+		out = &attrBuf
+	} else {
+		_, _ = w.Write([]byte(record.Message))
+		_, _ = w.Write([]byte(" "))
+	}
+
+	if f.GroupStyle == Braced {
+		for _, attr := range attrs {
+			f.formatBracedAttr(out, c, dim, attr, nil, openGroups)
+			_, _ = out.Write([]byte(" "))
+		}
+	} else {
+		// Keys are built in a buffer shared by every attribute
+		key := make([]byte, 0, 64)
+		for _, attr := range attrs {
+			f.formatAttr(out, c, attr, &key, nil, openGroups)
+		}
+	}
+
+	if f.MessageLast {
+		b := attrBuf.Bytes()
+		if record.Message == "" {
+			b = bytes.TrimSuffix(b, []byte(" "))
+		}
+		_, _ = w.Write(b)
+		_, _ = w.Write([]byte(record.Message))
 	}
 
 	return nil
 }
 
-func (f Formatter) formatAttr(w io.Writer, c *color.Color, attr *easyslog.Attr, parentKeys []string) {
+// ColorActive reports whether Format writes color, following the precedence
+// of NoColor, ForceColor and ColorMode. With Auto it reflects fatih/color's
+// detection at the time of the call.
+func (f Formatter) ColorActive() bool {
+	switch {
+	case f.NoColor:
+		return false
+	case f.ForceColor:
+		return true
+	case f.ColorMode == Always:
+		return true
+	case f.ColorMode == Never:
+		return false
+	}
+
+	return !color.NoColor
+}
+
+// levelLabel returns the prefix from Levels for level, computing an offset from
+// the nearest standard level for levels that aren't defined.
+func levelLabel(level slog.Level) string {
+	if label, ok := Levels[level]; ok {
+		return label
+	}
+
+	base := slog.LevelError
+	switch {
+	case level < slog.LevelInfo:
+		base = slog.LevelDebug
+	case level < slog.LevelWarn:
+		base = slog.LevelInfo
+	case level < slog.LevelError:
+		base = slog.LevelWarn
+	}
+
+	label, ok := Levels[base]
+	if !ok {
+		return "[UNK]"
+	}
+
+	offset := strconv.Itoa(int(level - base))
+	if level > base {
+		offset = "+" + offset
+	}
+
+	if strings.HasSuffix(label, "]") {
+		return label[:len(label)-1] + offset + "]"
+	}
+
+	return label + offset
+}
+
+// formatAttr writes attr and its children. key holds the dotted keys of the
+// enclosing groups, each followed by a dot, and is extended in place for attr
+// and restored before returning. openGroups holds the remaining WithGroup
+// names rendered in the tag, which are left out of the keys but not out of
+// path, the keys of every group enclosing attr. Unlike key, path is clipped
+// before it's extended, since ValueColorFunc may keep or append to it.
+func (f Formatter) formatAttr(w io.Writer, c *color.Color, attr *easyslog.Attr, key *[]byte, path []string, openGroups []string) {
+	prefixLen := len(*key)
+	defer func() { *key = (*key)[:prefixLen] }()
+
 	if attr.IsGroup() {
+		var childOpenGroups []string
+		if len(openGroups) > 0 && attr.Key == openGroups[0] {
+			childOpenGroups = openGroups[1:]
+		} else {
+			*key = append(append(*key, attr.Key...), '.')
+		}
+
 		for _, child := range attr.Children {
-			f.formatAttr(w, c, child, append(parentKeys, attr.Key))
+			f.formatAttr(w, c, child, key, append(slices.Clip(path), attr.Key), childOpenGroups)
+		}
+		return
+	}
+
+	*key = append(*key, attr.Key...)
+	if f.ColorActive() {
+		_, _ = io.WriteString(w, c.Sprint(string(*key)))
+	} else {
+		_, _ = w.Write(*key)
+	}
+	_, _ = io.WriteString(w, "=")
+	_, _ = io.WriteString(w, f.attrValue(attr, path))
+	_, _ = io.WriteString(w, " ")
+}
+
+// formatBracedAttr writes attr, or a group as `key={...}` with its children
+// separated by spaces. Groups in openGroups are already rendered in the tag, so
+// their children are written in place without braces.
+func (f Formatter) formatBracedAttr(w io.Writer, c, dim *color.Color, attr *easyslog.Attr, path []string, openGroups []string) {
+	if !attr.IsGroup() {
+		_, _ = io.WriteString(w, c.Sprint(attr.Key))
+		_, _ = w.Write([]byte("="))
+		_, _ = w.Write([]byte(f.attrValue(attr, path)))
+		return
+	}
+
+	path = append(slices.Clip(path), attr.Key)
+	if len(openGroups) > 0 && attr.Key == openGroups[0] {
+		for i, child := range attr.Children {
+			if i > 0 {
+				_, _ = w.Write([]byte(" "))
+			}
+			f.formatBracedAttr(w, c, dim, child, path, openGroups[1:])
 		}
 		return
 	}
 
-	key := strings.Join(append(parentKeys, attr.Key), ".")
-	c.Fprint(w, key)
+	_, _ = io.WriteString(w, c.Sprint(attr.Key))
 	_, _ = w.Write([]byte("="))
-	_, _ = w.Write([]byte(attr.Value.String()))
-	_, _ = w.Write([]byte(" "))
+	_, _ = io.WriteString(w, dim.Sprint("{"))
+	for i, child := range attr.Children {
+		if i > 0 {
+			_, _ = w.Write([]byte(" "))
+		}
+		f.formatBracedAttr(w, c, dim, child, path, nil)
+	}
+	_, _ = io.WriteString(w, dim.Sprint("}"))
+}
+
+// formatAttrLine writes attr on its own line indented by depth, followed by
+// its children one level deeper. Groups in openGroups are already rendered in
+// the tag, so their children are written at the group's own depth.
+func (f Formatter) formatAttrLine(w io.Writer, c *color.Color, attr *easyslog.Attr, path []string, depth int, openGroups []string) {
+	indent := strings.Repeat("  ", depth)
+
+	if attr.IsGroup() {
+		var childOpenGroups []string
+		childDepth := depth + 1
+		if len(openGroups) > 0 && attr.Key == openGroups[0] {
+			childOpenGroups = openGroups[1:]
+			childDepth = depth
+		} else {
+			_, _ = io.WriteString(w, "\n"+indent)
+			_, _ = io.WriteString(w, c.Sprint(attr.Key))
+			_, _ = w.Write([]byte(":"))
+		}
+
+		for _, child := range attr.Children {
+			f.formatAttrLine(w, c, child, append(slices.Clip(path), attr.Key), childDepth, childOpenGroups)
+		}
+		return
+	}
+
+	_, _ = io.WriteString(w, "\n"+indent)
+	_, _ = io.WriteString(w, c.Sprint(attr.Key))
+	_, _ = w.Write([]byte(": "))
+	_, _ = w.Write([]byte(f.attrValue(attr, path)))
+}
+
+// attrValue returns the rendered value of a leaf attribute whose enclosing
+// groups have the keys in path, colored by ValueColorFunc or HighlightRules.
+func (f Formatter) attrValue(attr *easyslog.Attr, path []string) string {
+	var value string
+	if data, ok := attr.RawJSON(); ok {
+		value = f.value(slog.StringValue(compactJSON(data)))
+	} else if humanized, ok := f.humanize(attr.Key, attr.Value); ok {
+		// Escaped like any other value, since ThousandsSeparator may hold
+		// control characters
+		value = f.value(slog.StringValue(humanized))
+	} else {
+		value = f.value(attr.Value)
+	}
+
+	if !f.ColorActive() {
+		return value
+	}
+
+	colorAttr, ok := color.Attribute(0), false
+	if f.ValueColorFunc != nil {
+		colorAttr, ok = f.ValueColorFunc(path, attr)
+	}
+	if !ok {
+		colorAttr, ok = f.highlight(path, attr.Key, attr.Value)
+	}
+
+	if ok {
+		c := color.New(colorAttr)
+		c.EnableColor()
+		return c.Sprint(value)
+	}
+
+	return value
+}
+
+// compactJSON returns data with insignificant whitespace removed, or as-is if
+// it isn't valid JSON.
+func compactJSON(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return string(data)
+	}
+
+	return buf.String()
+}
+
+func (f Formatter) value(v slog.Value) string {
+	if f.RawValues {
+		return v.String()
+	}
+
+	return escapeControl(v.String(), f.StripANSI)
+}
+
+// escapeControl escapes control characters in s using Go escape syntax. If
+// stripANSI is true, ANSI CSI sequences are removed instead of escaped.
+func escapeControl(s string, stripANSI bool) string {
+	clean := true
+	for _, r := range s {
+		if isControl(r) {
+			clean = false
+			break
+		}
+	}
+
+	if clean {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+
+	for i := 0; i < len(s); {
+		if stripANSI && strings.HasPrefix(s[i:], "\x1b[") {
+			end := i + 2
+			for end < len(s) && (s[end] < 0x40 || s[end] > 0x7e) {
+				end++
+			}
+			i = min(end+1, len(s))
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		if !isControl(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		switch r {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			quoted := strconv.QuoteRuneToASCII(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		}
+	}
+
+	return b.String()
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0)
 }
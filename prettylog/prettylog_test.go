@@ -3,7 +3,9 @@ package prettylog
 import (
 	"bytes"
 	"context"
+	"io"
 	"log/slog"
+	"strings"
 	"testing"
 
 	"github.com/blakewilliams/easyslog"
@@ -37,6 +39,11 @@ func TestColorDisabled(t *testing.T) {
 }
 
 func TestUnknownLogLevels(t *testing.T) {
+	defer func() {
+		Levels[slog.LevelWarn] = "[WRN]"
+	}()
+	delete(Levels, slog.LevelWarn)
+
 	var buf bytes.Buffer
 	handler := easyslog.New(&buf, Formatter{}, nil)
 	l := slog.New(handler)
@@ -46,6 +53,20 @@ func TestUnknownLogLevels(t *testing.T) {
 	require.Equal(t, "[UNK] omg foo=bar baz=quux \n", buf.String())
 }
 
+func TestIntermediateLogLevels(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{}, &easyslog.Options{Level: slog.Level(-10)})
+	l := slog.New(handler)
+	ctx := context.Background()
+
+	l.Log(ctx, slog.LevelInfo+2, "notice")
+	l.Log(ctx, slog.LevelDebug-4, "trace")
+	l.Log(ctx, slog.LevelWarn+1, "warnish")
+	l.Log(ctx, slog.LevelError+4, "fatal")
+
+	require.Equal(t, "[INF+2] notice \n[DBG-4] trace \n[WRN+1] warnish \n[ERR+4] fatal \n", buf.String())
+}
+
 func TestGroups(t *testing.T) {
 	var buf bytes.Buffer
 	handler := easyslog.New(&buf, Formatter{}, nil)
@@ -55,3 +76,401 @@ func TestGroups(t *testing.T) {
 
 	require.Equal(t, "[INF] msg request.method=get request.path=/ \n", buf.String())
 }
+
+func TestFlattenGroups(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{}, &easyslog.Options{FlattenGroups: "."})
+	l := slog.New(handler)
+
+	l.WithGroup("request").Info("msg", "method", "get", slog.Group("headers", "accept", "json"))
+
+	require.Equal(t, "[INF] msg request.method=get request.headers.accept=json \n", buf.String())
+}
+
+func TestGroupTag(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupTag: true}, nil)
+	l := slog.New(handler).With("app", "web").WithGroup("http").WithGroup("").With("id", 1).WithGroup("db")
+
+	l.Info("query", "table", "users", slog.Group("stats", "rows", 2))
+
+	require.Equal(t, "[INF] [http.db] query app=web id=1 table=users stats.rows=2 \n", buf.String())
+}
+
+func TestGroupTagWithoutGroups(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupTag: true}, nil)
+
+	slog.New(handler).Info("msg", slog.Group("request", "method", "get"))
+
+	require.Equal(t, "[INF] msg request.method=get \n", buf.String())
+}
+
+func TestLevelNames(t *testing.T) {
+	var buf bytes.Buffer
+	names := easyslog.LevelNamer{slog.LevelInfo: "info"}
+	l := slog.New(easyslog.New(&buf, Formatter{LevelNames: names}, nil))
+
+	l.Info("a")
+	l.Error("b")
+
+	require.Equal(t, "[info] a \n[ERROR] b \n", buf.String())
+}
+
+func TestEscapesControlCharacters(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("msg", "injected", "ok\n[ERR] fake line", "ansi", "\x1b[31mred\x1b[0m", "tab", "a\tb", "bell", "\a")
+
+	require.Equal(t, `[INF] msg injected=ok\n[ERR] fake line ansi=\x1b[31mred\x1b[0m tab=a\tb bell=\a `+"\n", buf.String())
+}
+
+func TestStripANSI(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{StripANSI: true}, nil))
+
+	l.Info("msg", "ansi", "\x1b[31mred\x1b[0m\n", "unterminated", "x\x1b[31")
+
+	require.Equal(t, `[INF] msg ansi=red\n unterminated=x `+"\n", buf.String())
+}
+
+func TestRawValues(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{RawValues: true, StripANSI: true}, nil))
+
+	l.Info("msg", "raw", "a\nb\x1b[0m")
+
+	require.Equal(t, "[INF] msg raw=a\nb\x1b[0m \n", buf.String())
+}
+
+func TestEscapeLeavesUnicode(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("msg", "name", "日本語 café")
+
+	require.Equal(t, "[INF] msg name=日本語 café \n", buf.String())
+}
+
+func TestPrefixKey(t *testing.T) {
+	var buf bytes.Buffer
+	var tapped easyslog.Record
+	handler := easyslog.New(&buf, Formatter{PrefixKey: "request.id"}, &easyslog.Options{
+		Tap: func(r easyslog.Record) { tapped = r },
+	})
+	l := slog.New(handler)
+
+	l.Info("handled", slog.Group("request", "id", "abc123", "path", "/"), "status", 200)
+	l.Info("no request", "status", 200)
+	l.Info("only id", slog.Group("request", "id", "def456"))
+
+	require.Equal(t, ""+
+		"[INF] abc123 handled request.path=/ status=200 \n"+
+		"[INF] no request status=200 \n"+
+		"[INF] def456 only id \n",
+		buf.String(),
+	)
+
+	// The record's tree is left untouched
+	_, ok := tapped.Get("request", "id")
+	require.True(t, ok)
+}
+
+type inlineValuer struct{}
+
+func (inlineValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("v", 4))
+}
+
+func TestInlineGroupsAtDepth(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("depth2", slog.Group("outer", slog.Group("", slog.Int("a", 1))))
+	l.Info("depth3", slog.Group("x", slog.Group("y", slog.Group("", slog.Int("b", 2)), "c", 3)))
+	l.Info("empty", slog.Group("x", slog.Group("", slog.Any("nil", nil))), "k", "v")
+	l.Info("valuer", slog.Group("x", slog.Any("", inlineValuer{})))
+
+	require.Equal(t, ""+
+		"[INF] depth2 outer.a=1 \n"+
+		"[INF] depth3 x.y.b=2 x.y.c=3 \n"+
+		"[INF] empty k=v \n"+
+		"[INF] valuer x.v=4 \n",
+		buf.String(),
+	)
+}
+
+func TestRawJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{}, nil))
+
+	l.Info("raw", easyslog.RawJSON("body", []byte("{\n  \"a\": 1\n}")), easyslog.RawJSON("bad", []byte("{\n")))
+
+	require.Equal(t, `[INF] raw body={"a":1} bad={\n `+"\n", buf.String())
+}
+
+func TestHandlerLevelNames(t *testing.T) {
+	var buf bytes.Buffer
+	names := easyslog.LevelNamer{easyslog.LevelTrace: "TRC", easyslog.LevelFatal: "FTL"}
+	l := slog.New(easyslog.New(&buf, Formatter{}, &easyslog.Options{Level: easyslog.LevelTrace, LevelNames: names}))
+
+	l.Log(context.Background(), easyslog.LevelTrace, "trace")
+	l.Log(context.Background(), easyslog.LevelFatal, "fatal")
+	l.Log(context.Background(), slog.LevelInfo+2, "unnamed")
+
+	require.Equal(t, "[TRC] trace \n[FTL] fatal \n[INFO+2] unnamed \n", buf.String())
+
+	// The formatter's own names win over the handler's
+	buf.Reset()
+	l = slog.New(easyslog.New(&buf, Formatter{LevelNames: easyslog.LevelNamer{easyslog.LevelFatal: "fatal"}}, &easyslog.Options{LevelNames: names}))
+	l.Log(context.Background(), easyslog.LevelFatal, "fatal")
+	l.Log(context.Background(), slog.LevelError+8, "unnamed")
+
+	require.Equal(t, "[fatal] fatal \n[ERROR+8] unnamed \n", buf.String())
+}
+
+func TestMultiLine(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{MultiLine: true}, nil)
+	l := slog.New(handler).With("app", "web")
+
+	l.Error("failed", slog.Group("request", "method", "get", slog.Group("headers", "accept", "json")), "err", "line1\nline2")
+
+	want := "[ERR] failed\n" +
+		"  app: web\n" +
+		"  request:\n" +
+		"    method: get\n" +
+		"    headers:\n" +
+		"      accept: json\n" +
+		"  err: line1\\nline2\n"
+	require.Equal(t, want, buf.String())
+}
+
+func TestMultiLineWithoutAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{MultiLine: true}, nil)).Info("msg")
+
+	require.Equal(t, "[INF] msg\n", buf.String())
+}
+
+func TestMultiLineGroupTag(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{MultiLine: true, GroupTag: true}, nil)
+	l := slog.New(handler).WithGroup("http")
+
+	l.Info("query", "table", "users", slog.Group("stats", "rows", 2))
+
+	require.Equal(t, "[INF] [http] query\n  table: users\n  stats:\n    rows: 2\n", buf.String())
+}
+
+func TestMultiLineColor(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+	color.NoColor = false
+
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{MultiLine: true, ColorMode: Always}, nil)).Info("msg", "k", "v")
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m msg\n  \x1b[34;1mk\x1b[0m: v\n", buf.String())
+
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{MultiLine: true, NoColor: true}, nil)).Info("msg", "k", "v")
+	require.Equal(t, "[INF] msg\n  k: v\n", buf.String())
+}
+
+func TestBracedGroups(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupStyle: Braced}, nil)
+
+	slog.New(handler).Info("msg", "a", 1, slog.Group("request", "method", "GET", "path", "/", slog.Group("headers", "accept", "json")))
+
+	require.Equal(t, "[INF] msg a=1 request={method=GET path=/ headers={accept=json}} \n", buf.String())
+}
+
+func TestBracedGroupsEmptyValue(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupStyle: Braced}, nil)
+
+	slog.New(handler).Info("msg", slog.Group("user", "name", "", "id", 1))
+
+	require.Equal(t, "[INF] msg user={name= id=1} \n", buf.String())
+}
+
+func TestBracedGroupsWithGroup(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupStyle: Braced}, nil)
+	l := slog.New(handler).With("app", "web").WithGroup("http").With("id", 1).WithGroup("db")
+
+	l.Info("query", "table", "users", slog.Group("stats", "rows", 2))
+
+	require.Equal(t, "[INF] query app=web http={id=1 db={table=users stats={rows=2}}} \n", buf.String())
+
+	buf.Reset()
+	handler = easyslog.New(&buf, Formatter{GroupStyle: Braced, GroupTag: true}, nil)
+	l = slog.New(handler).With("app", "web").WithGroup("http").With("id", 1).WithGroup("db")
+
+	l.Info("query", "table", "users", slog.Group("stats", "rows", 2))
+
+	require.Equal(t, "[INF] [http.db] query app=web id=1 table=users stats={rows=2} \n", buf.String())
+}
+
+func TestBracedGroupsEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupStyle: Braced}, nil)
+
+	slog.New(handler).Info("msg", slog.Group("g", "k", "a\nb"))
+	require.Equal(t, "[INF] msg g={k=a\\nb} \n", buf.String())
+
+	buf.Reset()
+	handler = easyslog.New(&buf, Formatter{GroupStyle: Braced, RawValues: true}, nil)
+
+	slog.New(handler).Info("msg", slog.Group("g", "k", "a\nb"))
+	require.Equal(t, "[INF] msg g={k=a\nb} \n", buf.String())
+}
+
+func TestBracedGroupsColor(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupStyle: Braced, ForceColor: true}, nil)
+
+	slog.New(handler).Info("msg", slog.Group("g", "k", "v"))
+
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m msg \x1b[34;1mg\x1b[0m=\x1b[2m{\x1b[0m\x1b[34;1mk\x1b[0m=v\x1b[2m}\x1b[0m \n", buf.String())
+}
+
+func TestNewFormatter(t *testing.T) {
+	formatter, err := easyslog.NewFormatter("pretty", map[string]any{"no_color": true, "group_style": Braced})
+	require.NoError(t, err)
+	require.Equal(t, Formatter{NoColor: true, GroupStyle: Braced}, formatter)
+}
+
+func TestValueColorFunc(t *testing.T) {
+	var paths []string
+	formatter := Formatter{
+		ForceColor: true,
+		GroupTag:   true,
+		ValueColorFunc: func(path []string, a *easyslog.Attr) (color.Attribute, bool) {
+			paths = append(paths, strings.Join(append(path, a.Key), "."))
+			if a.Key == "status" && a.Value.Int64() >= 500 {
+				return color.FgRed, true
+			}
+			return 0, false
+		},
+	}
+
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, formatter, nil)).WithGroup("http")
+	l.Info("done", "status", 503, slog.Group("req", "path", "/"))
+	l.Info("done", "status", 200)
+
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m \x1b[34;1m[http]\x1b[0m done \x1b[34;1mstatus\x1b[0m=\x1b[31m503\x1b[0m \x1b[34;1mreq.path\x1b[0m=/ \n"+
+		"\x1b[34;1m[INF]\x1b[0m \x1b[34;1m[http]\x1b[0m done \x1b[34;1mstatus\x1b[0m=200 \n", buf.String())
+	require.Equal(t, []string{"http.status", "http.req.path", "http.status"}, paths)
+
+	for _, f := range []Formatter{{GroupStyle: Braced}, {MultiLine: true}} {
+		f.ForceColor = true
+		f.ValueColorFunc = formatter.ValueColorFunc
+		paths = nil
+
+		buf.Reset()
+		slog.New(easyslog.New(&buf, f, nil)).WithGroup("http").Info("done", "status", 500, slog.Group("req", "path", "/"))
+		require.Contains(t, buf.String(), "\x1b[31m500\x1b[0m")
+		require.Equal(t, []string{"http.status", "http.req.path"}, paths)
+	}
+
+	formatter.ForceColor = false
+	formatter.NoColor = true
+	paths = nil
+
+	buf.Reset()
+	slog.New(easyslog.New(&buf, formatter, nil)).Info("done", "status", 500)
+	require.Equal(t, "[INF] done status=500 \n", buf.String())
+	require.Empty(t, paths)
+}
+
+func TestValueColorFuncSiblingGroups(t *testing.T) {
+	for _, f := range []Formatter{{}, {GroupStyle: Braced}, {MultiLine: true}} {
+		f.ForceColor = true
+		// ValueColorFunc may keep the paths it's passed
+		var paths [][]string
+		f.ValueColorFunc = func(path []string, a *easyslog.Attr) (color.Attribute, bool) {
+			paths = append(paths, path)
+			return 0, false
+		}
+
+		// At this depth an appended path has spare capacity
+		slog.New(easyslog.New(io.Discard, f, nil)).Info("done",
+			slog.Group("a", slog.Group("b", slog.Group("c", slog.Group("d", "x", 1), slog.Group("e", "y", 2)))),
+		)
+
+		require.Equal(t, [][]string{{"a", "b", "c", "d"}, {"a", "b", "c", "e"}}, paths)
+	}
+}
+
+func TestMessageLast(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(easyslog.New(&buf, Formatter{MessageLast: true}, nil))
+
+	l.Info("request done", "status", 200, slog.Group("req", "path", "/"))
+	l.Info("no attrs")
+	l.Info("", "status", 200)
+	require.Equal(t, "[INF] status=200 req.path=/ request done\n[INF] no attrs\n[INF] status=200\n", buf.String())
+
+	buf.Reset()
+	l = slog.New(easyslog.New(&buf, Formatter{MessageLast: true, GroupTag: true, PrefixKey: "request_id"}, nil))
+	l.With("request_id", "abc").WithGroup("http").Info("tagged", "status", 500)
+	require.Equal(t, "[INF] abc [http] status=500 tagged\n", buf.String())
+
+	buf.Reset()
+	l = slog.New(easyslog.New(&buf, Formatter{MessageLast: true, GroupStyle: Braced}, nil))
+	l.Info("braced", "a", 1, slog.Group("req", "path", "/"))
+	l.Info("", "a", 1)
+	require.Equal(t, "[INF] a=1 req={path=/} braced\n[INF] a=1\n", buf.String())
+}
+
+func TestSiblingGroupKeys(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{}, nil)).WithGroup("req").Info("msg",
+		slog.Group("a", slog.Group("b", "x", 1), slog.Group("c", "y", 2), "z", 3),
+		slog.Group("d", "w", 4),
+	)
+
+	require.Equal(t, "[INF] msg req.a.b.x=1 req.a.c.y=2 req.a.z=3 req.d.w=4 \n", buf.String())
+}
+
+func BenchmarkFormatNestedGroups(b *testing.B) {
+	var record easyslog.Record
+	handler := easyslog.New(io.Discard, Formatter{}, &easyslog.Options{Tap: func(r easyslog.Record) { record = r.Clone() }})
+	slog.New(handler).With("service", "api").WithGroup("request").With("method", "GET", "path", "/").Info("served",
+		slog.Group("user", "id", 1, "name", "fox", slog.Group("org", "id", 2, "plan", "pro")),
+		slog.Group("response", "status", 200, slog.Group("timing", "db", 3, "total", 12)),
+	)
+
+	f := Formatter{}
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		_ = f.Format(&buf, record)
+	}
+}
+
+func TestHandlerName(t *testing.T) {
+	var buf bytes.Buffer
+	handler := easyslog.New(&buf, Formatter{GroupTag: true}, &easyslog.Options{Name: "server"})
+
+	slog.New(handler.WithName("http")).WithGroup("req").Info("served", "status", 200)
+	require.Equal(t, "[INF] [server.http] [req] served status=200 \n", buf.String())
+
+	// The name is dimmed like braced groups
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{ForceColor: true}, &easyslog.Options{Name: "db"})).Info("query")
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m \x1b[2m[db]\x1b[0m query \n", buf.String())
+
+	// Nothing is rendered without a name
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{}, nil)).Info("plain")
+	require.Equal(t, "[INF] plain \n", buf.String())
+}
@@ -0,0 +1,64 @@
+package prettylog
+
+import (
+	"io"
+	"os"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ColorMode determines when a Formatter uses color.
+type ColorMode int
+
+const (
+	// Auto leaves the decision to fatih/color, which enables color when
+	// stdout is a terminal and NO_COLOR isn't set. Use New to detect color
+	// support for a specific writer instead.
+	Auto ColorMode = iota
+	// Always uses color, even when writing to a file or pipe.
+	Always
+	// Never disables color unless ForceColor is set.
+	Never
+)
+
+// New returns a Formatter whose ColorMode is Always if w supports color, see
+// ColorSupported, and Never otherwise.
+func New(w io.Writer) Formatter {
+	mode := Never
+	if ColorSupported(w) {
+		mode = Always
+	}
+
+	return Formatter{ColorMode: mode}
+}
+
+// ColorSupported reports whether w should be written to with color. NO_COLOR
+// disables color and FORCE_COLOR (other than `0`) enables it regardless of
+// w. Otherwise w must be a terminal, e.g. os.Stderr, and TERM must not be
+// `dumb`. On Windows, virtual terminal processing is enabled on the console
+// so escape sequences are rendered.
+func ColorSupported(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	if force := os.Getenv("FORCE_COLOR"); force != "" {
+		return force != "0"
+	}
+
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+
+	fd := f.Fd()
+	if !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd) {
+		return false
+	}
+
+	return enableVirtualTerminal(fd)
+}
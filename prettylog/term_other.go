@@ -0,0 +1,9 @@
+//go:build !windows
+
+package prettylog
+
+// enableVirtualTerminal is only needed on Windows; other terminals always
+// interpret escape sequences.
+func enableVirtualTerminal(fd uintptr) bool {
+	return true
+}
@@ -0,0 +1,103 @@
+package prettylog
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func pipe(t *testing.T) *os.File {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+
+	return w
+}
+
+func TestColorSupportedPipe(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+
+	require.False(t, ColorSupported(pipe(t)))
+	require.False(t, ColorSupported(&bytes.Buffer{}))
+	require.Equal(t, Never, New(pipe(t)).ColorMode)
+}
+
+func TestColorSupportedEnv(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "1")
+	require.True(t, ColorSupported(pipe(t)))
+	require.Equal(t, Always, New(&bytes.Buffer{}).ColorMode)
+
+	t.Setenv("FORCE_COLOR", "0")
+	require.False(t, ColorSupported(pipe(t)))
+
+	t.Setenv("FORCE_COLOR", "1")
+	t.Setenv("NO_COLOR", "1")
+	require.False(t, ColorSupported(pipe(t)))
+}
+
+func TestColorMode(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+	color.NoColor = true
+
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{ColorMode: Always}, nil)).Info("msg")
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m msg \n", buf.String())
+
+	color.NoColor = false
+
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{ColorMode: Never}, nil)).Info("msg")
+	require.Equal(t, "[INF] msg \n", buf.String())
+
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{ColorMode: Always, NoColor: true}, nil)).Info("msg")
+	require.Equal(t, "[INF] msg \n", buf.String())
+}
+
+func TestForceColor(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+	color.NoColor = true
+
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{ForceColor: true, ColorMode: Never}, nil)).Info("msg")
+	require.Equal(t, "\x1b[34;1m[INF]\x1b[0m msg \n", buf.String())
+
+	color.NoColor = false
+
+	buf.Reset()
+	slog.New(easyslog.New(&buf, Formatter{ForceColor: true, NoColor: true}, nil)).Info("msg")
+	require.Equal(t, "[INF] msg \n", buf.String())
+}
+
+func TestColorActive(t *testing.T) {
+	defer func() {
+		color.NoColor = true
+	}()
+
+	color.NoColor = true
+	require.False(t, Formatter{}.ColorActive())
+	require.True(t, Formatter{ForceColor: true}.ColorActive())
+	require.True(t, Formatter{ColorMode: Always}.ColorActive())
+	require.False(t, Formatter{NoColor: true, ForceColor: true}.ColorActive())
+
+	color.NoColor = false
+	require.True(t, Formatter{}.ColorActive())
+	require.False(t, Formatter{ColorMode: Never}.ColorActive())
+	require.True(t, Formatter{ColorMode: Never, ForceColor: true}.ColorActive())
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package prettylog
+
+import "golang.org/x/sys/windows"
+
+// enableVirtualTerminal turns on escape sequence processing for the console
+// behind fd, reporting whether the console supports it. Cygwin and MSYS
+// terminals aren't consoles and handle escape sequences on their own.
+func enableVirtualTerminal(fd uintptr) bool {
+	handle := windows.Handle(fd)
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return true
+	}
+
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,62 @@
+package easyslog
+
+import (
+	"log/slog"
+	"os"
+	"runtime"
+)
+
+const (
+	// PIDKey is the key of the attribute added by Options.IncludePID.
+	PIDKey = "pid"
+	// GoroutineKey is the key of the attribute added by
+	// Options.IncludeGoroutineID.
+	GoroutineKey = "goroutine"
+)
+
+// pid is looked up once since it can't change for the life of the process.
+var pid = os.Getpid()
+
+// appendProcessAttrs appends the Options.IncludePID and
+// Options.IncludeGoroutineID attributes to attrs, allocated from arena.
+func (handler *EasySlog) appendProcessAttrs(attrs []*Attr, arena *attrArena) []*Attr {
+	if handler.opts.IncludePID {
+		attr := arena.newAttr()
+		attr.Key = PIDKey
+		attr.Value = slog.IntValue(pid)
+		attrs = append(attrs, attr)
+	}
+
+	if handler.opts.IncludeGoroutineID {
+		attr := arena.newAttr()
+		attr.Key = GoroutineKey
+		attr.Value = slog.Uint64Value(goroutineID())
+		attrs = append(attrs, attr)
+	}
+
+	return attrs
+}
+
+// goroutineID returns the id of the calling goroutine, parsed from the
+// `goroutine 123 [running]:` header of its stack trace, or zero if it can't
+// be parsed. The header always fits in a single 64-byte buffer, so nothing
+// past it is kept, but walking the stack still costs a few microseconds.
+func goroutineID() uint64 {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+
+	const prefix = "goroutine "
+	if len(header) < len(prefix) || string(header[:len(prefix)]) != prefix {
+		return 0
+	}
+
+	var id uint64
+	for _, c := range header[len(prefix):] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + uint64(c-'0')
+	}
+
+	return id
+}
@@ -0,0 +1,53 @@
+package easyslog
+
+import "sync"
+
+// recentRecords holds the last Records handled when Options.Ring is set. It's
+// shared by every handler derived from the same call to New.
+type recentRecords struct {
+	mu      sync.Mutex
+	records []Record
+	start   int
+	count   int
+}
+
+func newRecentRecords(size int) *recentRecords {
+	return &recentRecords{records: make([]Record, size)}
+}
+
+// add stores r, replacing the oldest record once the buffer is full.
+func (recent *recentRecords) add(r Record) {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+
+	size := len(recent.records)
+	if recent.count < size {
+		recent.records[(recent.start+recent.count)%size] = r
+		recent.count++
+		return
+	}
+
+	recent.records[recent.start] = r
+	recent.start = (recent.start + 1) % size
+}
+
+// Recent returns the last Records handled by this handler and every handler
+// sharing its writer, oldest first, up to Options.Ring of them. It returns nil
+// if Options.Ring isn't set. Records are kept whether or not they were written
+// successfully, and their attributes must not be modified.
+func (handler *EasySlog) Recent() []Record {
+	recent := handler.out.recent
+	if recent == nil {
+		return nil
+	}
+
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+
+	records := make([]Record, recent.count)
+	for i := range records {
+		records[i] = recent.records[(recent.start+i)%len(recent.records)]
+	}
+
+	return records
+}
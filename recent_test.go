@@ -0,0 +1,70 @@
+package easyslog
+
+import (
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecent(t *testing.T) {
+	handler := New(io.Discard, JSONFormatter{}, &Options{Ring: 3})
+	l := slog.New(handler)
+
+	l.Info("one")
+	require.Len(t, handler.Recent(), 1)
+
+	for i := 2; i <= 5; i++ {
+		l.With("n", i).Info(strconv.Itoa(i))
+	}
+
+	recent := handler.Recent()
+	require.Len(t, recent, 3)
+	for i, r := range recent {
+		require.Equal(t, strconv.Itoa(i+3), r.Message)
+		v, ok := r.Get("n")
+		require.True(t, ok)
+		require.Equal(t, int64(i+3), v.Int64())
+	}
+}
+
+func TestRecentSharedByDerivedHandlers(t *testing.T) {
+	handler := New(io.Discard, JSONFormatter{}, &Options{Ring: 10})
+
+	slog.New(handler.WithGroup("g")).Info("derived", "a", 1)
+
+	recent := handler.Recent()
+	require.Len(t, recent, 1)
+	require.Equal(t, "derived", recent[0].Message)
+	require.Equal(t, []string{"g"}, recent[0].Groups)
+}
+
+func TestRecentWithoutRing(t *testing.T) {
+	handler := New(io.Discard, JSONFormatter{}, nil)
+	slog.New(handler).Info("msg")
+
+	require.Nil(t, handler.Recent())
+}
+
+func TestRecentConcurrent(t *testing.T) {
+	handler := New(io.Discard, JSONFormatter{}, &Options{Ring: 16})
+	l := slog.New(handler)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Info("msg", "worker", i)
+				_ = handler.Recent()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, handler.Recent(), 16)
+}
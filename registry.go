@@ -0,0 +1,138 @@
+package easyslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FormatterFactory builds a Formatter from options, e.g. decoded from a
+// config file. See DecodeOptions.
+type FormatterFactory func(opts map[string]any) (Formatter, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]FormatterFactory{}
+)
+
+// RegisterFormatter makes a formatter available to NewFormatter by name. The
+// formatter packages in this module register themselves when imported:
+// `json` by jsonlog, `pretty` by prettylog, `logfmt` and `text` by logfmt,
+// `aligned` by alignedlog, `canonical` by canonicalformat, `csv` by
+// csvformat, `ecs` by ecsformat, `emf` by emfformat, `gelf` by gelf, `html` by
+// htmlformat, `journald` by journaldlog, `loki` by lokiformat and `syslog` by
+// syslog. It panics if name is empty, factory is nil, or name is already
+// registered.
+func RegisterFormatter(name string, factory FormatterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" {
+		panic("easyslog: RegisterFormatter name is empty")
+	}
+	if factory == nil {
+		panic("easyslog: RegisterFormatter factory is nil for " + name)
+	}
+	if _, ok := registry[name]; ok {
+		panic("easyslog: RegisterFormatter called twice for " + name)
+	}
+
+	registry[name] = factory
+}
+
+// NewFormatter returns the formatter registered as name built with opts,
+// which may be nil.
+func NewFormatter(name string, opts map[string]any) (Formatter, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("easyslog: unknown formatter %q (forgotten import?)", name)
+	}
+
+	return factory(opts)
+}
+
+// Formatters returns the sorted names of the registered formatters.
+func Formatters() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// DecodeOptions sets the exported fields of the struct dst points to from
+// opts, for use by a FormatterFactory. Keys match field names ignoring case,
+// `_` and `-`, so `no_color`, `no-color` and `NoColor` all set NoColor.
+// Values are converted like encoding/json would decode them, and strings such
+// as `1.5s` are also accepted for time.Duration fields. Unknown keys are an
+// error.
+func DecodeOptions(opts map[string]any, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("easyslog: DecodeOptions needs a pointer to a struct")
+	}
+	v = v.Elem()
+
+	fields := make(map[string]int, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Type().Field(i); field.IsExported() {
+			fields[optionName(field.Name)] = i
+		}
+	}
+
+	// Decode in key order so the error for a bad config is always the same
+	keys := make([]string, 0, len(opts))
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		i, ok := fields[optionName(key)]
+		if !ok {
+			return fmt.Errorf("easyslog: unknown option %q", key)
+		}
+
+		if err := decodeOption(v.Field(i), opts[key]); err != nil {
+			return fmt.Errorf("easyslog: option %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func decodeOption(field reflect.Value, value any) error {
+	if s, ok := value.(string); ok && field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, field.Addr().Interface())
+}
+
+func optionName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
@@ -0,0 +1,99 @@
+package easyslog
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type configFormatter struct {
+	Prefix   string
+	NoColor  bool
+	Width    int
+	Keys     []string
+	Interval time.Duration
+	Levels   LevelNamer
+
+	internal string
+}
+
+func (f configFormatter) Format(w io.Writer, r Record) error {
+	_, err := io.WriteString(w, f.Prefix+r.Message)
+	return err
+}
+
+// unregisterFormatter removes name from the registry, so tests registering
+// formatters can be run more than once.
+func unregisterFormatter(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	t.Cleanup(func() { unregisterFormatter("test-prefix") })
+	RegisterFormatter("test-prefix", func(opts map[string]any) (Formatter, error) {
+		var f configFormatter
+		if err := DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+
+	require.Contains(t, Formatters(), "test-prefix")
+
+	formatter, err := NewFormatter("test-prefix", map[string]any{"prefix": "> "})
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	slog.New(New(&b, formatter, nil)).Info("hello")
+	require.Equal(t, "> hello\n", b.String())
+
+	_, err = NewFormatter("test-prefix", map[string]any{"colour": true})
+	require.EqualError(t, err, `easyslog: unknown option "colour"`)
+
+	require.PanicsWithValue(t, "easyslog: RegisterFormatter called twice for test-prefix", func() {
+		RegisterFormatter("test-prefix", func(map[string]any) (Formatter, error) { return nil, nil })
+	})
+	require.Panics(t, func() { RegisterFormatter("test-nil", nil) })
+}
+
+func TestNewFormatterUnknown(t *testing.T) {
+	_, err := NewFormatter("missing", nil)
+	require.EqualError(t, err, `easyslog: unknown formatter "missing" (forgotten import?)`)
+}
+
+func TestDecodeOptions(t *testing.T) {
+	var f configFormatter
+	err := DecodeOptions(map[string]any{
+		"no_color": true,
+		"Width":    80.0,
+		"keys":     []any{"a", "b"},
+		"interval": "1.5s",
+		"levels":   map[string]any{"INFO": "info", "WARN+2": "notice"},
+	}, &f)
+	require.NoError(t, err)
+
+	require.Equal(t, configFormatter{
+		NoColor:  true,
+		Width:    80,
+		Keys:     []string{"a", "b"},
+		Interval: 1500 * time.Millisecond,
+		Levels:   LevelNamer{slog.LevelInfo: "info", slog.LevelWarn + 2: "notice"},
+	}, f)
+
+	require.NoError(t, DecodeOptions(map[string]any{"interval": 5}, &f))
+	require.Equal(t, time.Duration(5), f.Interval)
+
+	require.EqualError(t, DecodeOptions(map[string]any{"internal": "x"}, &f), `easyslog: unknown option "internal"`)
+	require.ErrorContains(t, DecodeOptions(map[string]any{"width": "wide"}, &f), `easyslog: option "width": json: cannot unmarshal string`)
+	require.ErrorContains(t, DecodeOptions(map[string]any{"interval": "soon"}, &f), `easyslog: option "interval": time: invalid duration`)
+	require.Error(t, DecodeOptions(nil, f))
+	require.NoError(t, DecodeOptions(nil, &f))
+}
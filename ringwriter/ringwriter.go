@@ -0,0 +1,114 @@
+// Package ringwriter provides an io.Writer that keeps the most recent log
+// lines in memory, e.g. for a debug endpoint or a crash report.
+package ringwriter
+
+import (
+	"io"
+	"sync"
+)
+
+// Ring is an io.Writer that stores each Write as one line, evicting the oldest
+// lines once either the line or byte limit is exceeded. EasySlog writes each
+// log line with a single Write, so lines are never split. It's safe for
+// concurrent use.
+type Ring struct {
+	maxLines int
+	maxBytes int
+
+	mu    sync.Mutex
+	lines [][]byte
+	start int
+	count int
+	size  int
+}
+
+var _ io.Writer = (*Ring)(nil)
+var _ io.WriterTo = (*Ring)(nil)
+
+// New returns a Ring holding at most capacityLines lines and, when maxBytes is
+// positive, at most maxBytes bytes. capacityLines must be positive.
+func New(capacityLines int, maxBytes int) *Ring {
+	if capacityLines <= 0 {
+		panic("ringwriter: capacityLines must be positive")
+	}
+
+	return &Ring{
+		maxLines: capacityLines,
+		maxBytes: maxBytes,
+		lines:    make([][]byte, capacityLines),
+	}
+}
+
+// Write stores a copy of p as a line. A line larger than maxBytes on its own
+// is discarded rather than split. It never returns an error.
+func (r *Ring) Write(p []byte) (int, error) {
+	if r.maxBytes > 0 && len(p) > r.maxBytes {
+		return len(p), nil
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for r.count == r.maxLines || (r.maxBytes > 0 && r.size+len(line) > r.maxBytes) {
+		r.evict()
+	}
+
+	r.lines[(r.start+r.count)%r.maxLines] = line
+	r.count++
+	r.size += len(line)
+
+	return len(p), nil
+}
+
+// evict drops the oldest line.
+func (r *Ring) evict() {
+	r.size -= len(r.lines[r.start])
+	r.lines[r.start] = nil
+	r.start = (r.start + 1) % r.maxLines
+	r.count--
+}
+
+// Lines returns a copy of the stored lines, oldest first.
+func (r *Ring) Lines() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lines := make([][]byte, r.count)
+	for i := range lines {
+		lines[i] = append([]byte(nil), r.lines[(r.start+i)%r.maxLines]...)
+	}
+
+	return lines
+}
+
+// WriteTo writes the stored lines to w, oldest first. The ring stays locked
+// while writing, so w shouldn't log to it.
+func (r *Ring) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total int64
+	for i := 0; i < r.count; i++ {
+		n, err := w.Write(r.lines[(r.start+i)%r.maxLines])
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Reset discards all stored lines.
+func (r *Ring) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clear(r.lines)
+	r.start = 0
+	r.count = 0
+	r.size = 0
+}
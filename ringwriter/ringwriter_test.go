@@ -0,0 +1,125 @@
+package ringwriter
+
+import (
+	"bytes"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/blakewilliams/easyslog/jsonlog"
+	"github.com/blakewilliams/easyslog/slogtesting"
+	"github.com/stretchr/testify/require"
+)
+
+func lineStrings(r *Ring) []string {
+	var lines []string
+	for _, line := range r.Lines() {
+		lines = append(lines, string(line))
+	}
+
+	return lines
+}
+
+func TestEvictsByLines(t *testing.T) {
+	r := New(3, 0)
+	for i := 0; i < 5; i++ {
+		_, _ = r.Write([]byte(strconv.Itoa(i) + "\n"))
+	}
+
+	require.Equal(t, []string{"2\n", "3\n", "4\n"}, lineStrings(r))
+}
+
+func TestEvictsByBytes(t *testing.T) {
+	r := New(10, 10)
+	_, _ = r.Write([]byte("aaaa\n"))
+	_, _ = r.Write([]byte("bbbb\n"))
+	_, _ = r.Write([]byte("cc\n"))
+
+	require.Equal(t, []string{"bbbb\n", "cc\n"}, lineStrings(r))
+
+	n, err := r.Write([]byte("this line is too long\n"))
+	require.NoError(t, err)
+	require.Equal(t, 22, n)
+	require.Equal(t, []string{"bbbb\n", "cc\n"}, lineStrings(r))
+}
+
+func TestLinesAreCopies(t *testing.T) {
+	r := New(2, 0)
+	p := []byte("line\n")
+	_, _ = r.Write(p)
+	p[0] = 'X'
+
+	lines := r.Lines()
+	lines[0][1] = 'X'
+
+	require.Equal(t, []string{"line\n"}, lineStrings(r))
+}
+
+func TestWriteToAndReset(t *testing.T) {
+	r := New(2, 0)
+	_, _ = r.Write([]byte("a\n"))
+	_, _ = r.Write([]byte("b\n"))
+	_, _ = r.Write([]byte("c\n"))
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+	require.Equal(t, "b\nc\n", buf.String())
+
+	r.Reset()
+	require.Empty(t, r.Lines())
+
+	_, _ = r.Write([]byte("d\n"))
+	require.Equal(t, []string{"d\n"}, lineStrings(r))
+}
+
+func TestTee(t *testing.T) {
+	var stdout bytes.Buffer
+	r := New(100, 0)
+	l := slog.New(slogtesting.Tee(
+		easyslog.New(&stdout, jsonlog.Formatter{}, nil),
+		easyslog.New(r, jsonlog.Formatter{}, nil),
+	))
+
+	l.Info("one", "a", 1)
+	l.WithGroup("g").Warn("two", "b", 2)
+
+	var ring bytes.Buffer
+	_, err := r.WriteTo(&ring)
+	require.NoError(t, err)
+	require.Equal(t, stdout.String(), ring.String())
+	require.Len(t, r.Lines(), 2)
+}
+
+func TestConcurrent(t *testing.T) {
+	r := New(50, 4096)
+	l := slog.New(easyslog.New(r, jsonlog.Formatter{}, nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				l.Info("msg", "worker", i, "n", j)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for _, line := range r.Lines() {
+					require.Equal(t, byte('\n'), line[len(line)-1])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := r.Lines()
+	require.LessOrEqual(t, len(lines), 50)
+	require.LessOrEqual(t, len(bytes.Join(lines, nil)), 4096)
+	require.Contains(t, string(lines[len(lines)-1]), `"n":199`)
+}
@@ -0,0 +1,151 @@
+// Package slogtesting captures easyslog Records so tests can assert on
+// structured log data without parsing formatted output.
+package slogtesting
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// Recorder stores every Record passed to Tap. It's safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	records []easyslog.Record
+}
+
+// New returns a Recorder and an EasySlog handler that taps every record into
+// it. Formatted output is discarded. Any Tap already set on opts is still
+// called. With Options.ReuseAttrs, records are cloned before they're stored.
+func New(opts *easyslog.Options) (*Recorder, *easyslog.EasySlog) {
+	var options easyslog.Options
+	if opts != nil {
+		options = *opts
+	}
+
+	recorder := &Recorder{}
+	tap := options.Tap
+	reuse := options.ReuseAttrs
+	options.Tap = func(r easyslog.Record) {
+		if reuse {
+			// The attributes are reused once the record is written
+			recorder.Tap(r.Clone())
+		} else {
+			recorder.Tap(r)
+		}
+
+		if tap != nil {
+			tap(r)
+		}
+	}
+
+	return recorder, easyslog.New(io.Discard, discardFormatter{}, &options)
+}
+
+// Tap records r. It can be used directly as easyslog.Options.Tap, unless
+// Options.ReuseAttrs is set, since r is retained as-is. New clones records in
+// that case.
+func (rec *Recorder) Tap(r easyslog.Record) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.records = append(rec.records, r)
+}
+
+// Records returns the records captured so far, in the order they were logged.
+func (rec *Recorder) Records() []easyslog.Record {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	records := make([]easyslog.Record, len(rec.records))
+	copy(records, rec.records)
+
+	return records
+}
+
+// AssertRecords reports a test error unless the captured records equal want,
+// in order, as compared by easyslog.Record.Equal. Each mismatching record is
+// reported with easyslog.DiffRecords, so the failure names the attribute
+// paths that differ.
+func (rec *Recorder) AssertRecords(t testing.TB, want ...easyslog.Record) {
+	t.Helper()
+
+	got := rec.Records()
+	if len(got) != len(want) {
+		t.Errorf("slogtesting: got %d records, want %d", len(got), len(want))
+	}
+
+	for i := 0; i < len(got) && i < len(want); i++ {
+		if !want[i].Equal(got[i]) {
+			t.Errorf("slogtesting: record %d differs from want:\n%s", i, easyslog.DiffRecords(want[i], got[i]))
+		}
+	}
+}
+
+// Reset discards all captured records.
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.records = nil
+}
+
+// Tee returns a handler passing each record to every one of handlers that's
+// enabled for its level, e.g. to check how several handlers split records
+// between them. Each handler gets its own clone of the record, and the errors
+// of every handler are joined.
+func Tee(handlers ...slog.Handler) slog.Handler {
+	return tee(handlers)
+}
+
+type tee []slog.Handler
+
+func (t tee) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t tee) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t tee) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(tee, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+
+	return handlers
+}
+
+func (t tee) WithGroup(name string) slog.Handler {
+	handlers := make(tee, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+
+	return handlers
+}
+
+type discardFormatter struct{}
+
+func (discardFormatter) Format(w io.Writer, r easyslog.Record) error {
+	return nil
+}
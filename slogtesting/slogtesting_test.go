@@ -0,0 +1,152 @@
+package slogtesting
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder, handler := New(nil)
+	l := slog.New(handler)
+
+	l.WithGroup("user").Info("login", "id", 42, "name", "fox")
+	l.Debug("ignored")
+
+	records := recorder.Records()
+	require.Len(t, records, 1)
+	require.Equal(t, "login", records[0].Message)
+
+	id, ok := records[0].Get("user", "id")
+	require.True(t, ok)
+	require.Equal(t, int64(42), id.Int64())
+
+	_, ok = records[0].Get("user", "missing")
+	require.False(t, ok)
+
+	_, ok = records[0].Get("user")
+	require.False(t, ok)
+
+	recorder.Reset()
+	require.Empty(t, recorder.Records())
+}
+
+func TestRecorderAsTap(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := &Recorder{}
+	handler := easyslog.New(&buf, lineFormatter{}, &easyslog.Options{Tap: recorder.Tap})
+
+	slog.New(handler).Info("hello", "a", "b")
+
+	require.Equal(t, "hello\n", buf.String())
+	require.Len(t, recorder.Records(), 1)
+
+	value, ok := recorder.Records()[0].Get("a")
+	require.True(t, ok)
+	require.Equal(t, "b", value.String())
+}
+
+func TestNewKeepsExistingTap(t *testing.T) {
+	var tapped []easyslog.Record
+	recorder, handler := New(&easyslog.Options{
+		Level: slog.LevelDebug,
+		Tap:   func(r easyslog.Record) { tapped = append(tapped, r) },
+	})
+
+	slog.New(handler).Debug("hi")
+
+	require.Len(t, recorder.Records(), 1)
+	require.Len(t, tapped, 1)
+}
+
+type lineFormatter struct{}
+
+func (lineFormatter) Format(w io.Writer, r easyslog.Record) error {
+	_, err := w.Write([]byte(r.Message))
+	return err
+}
+
+func TestRecorderReuseAttrs(t *testing.T) {
+	recorder, handler := New(&easyslog.Options{ReuseAttrs: true})
+	l := slog.New(handler)
+
+	for i := 0; i < 3; i++ {
+		l.Info("msg", slog.Group("g", "i", i))
+	}
+
+	records := recorder.Records()
+	require.Len(t, records, 3)
+	for i, record := range records {
+		v, ok := record.Get("g", "i")
+		require.True(t, ok)
+		require.Equal(t, int64(i), v.Int64())
+	}
+}
+
+// fakeT records the errors reported to it.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertRecords(t *testing.T) {
+	recorder, handler := New(&easyslog.Options{OmitTime: true})
+	slog.New(handler).Info("login", "id", 42, slog.Group("user", "name", "fox"))
+
+	want := easyslog.Record{Level: slog.LevelInfo, Message: "login"}
+	want.Add(slog.Int("id", 42))
+	want.Add(slog.Group("user", "name", "fox"))
+
+	passing := &fakeT{}
+	recorder.AssertRecords(passing, want)
+	require.Empty(t, passing.errors)
+
+	want.Delete("user", "name")
+	want.Add(slog.Group("user", "name", "dana"))
+
+	failing := &fakeT{}
+	recorder.AssertRecords(failing, want, want)
+	require.Equal(t, []string{
+		"slogtesting: got 1 records, want 2",
+		"slogtesting: record 0 differs from want:\n~ user.name: \"dana\" (String) → \"fox\" (String)",
+	}, failing.errors)
+}
+
+func TestTee(t *testing.T) {
+	info, infoHandler := New(nil)
+	debug, debugHandler := New(&easyslog.Options{Level: slog.LevelDebug})
+	l := slog.New(Tee(infoHandler, debugHandler)).With("a", 1).WithGroup("g")
+
+	l.Info("both", "b", 2)
+	l.Debug("debug only")
+
+	require.Len(t, info.Records(), 1)
+	require.Len(t, debug.Records(), 2)
+	for _, r := range append(info.Records(), debug.Records()[0]) {
+		require.Equal(t, "both", r.Message)
+		value, ok := r.Get("g", "b")
+		require.True(t, ok)
+		require.Equal(t, int64(2), value.Int64())
+	}
+
+	// Every handler's error is returned
+	a, b := easyslog.New(io.Discard, discardFormatter{}, nil), easyslog.New(io.Discard, discardFormatter{}, nil)
+	require.NoError(t, a.Close())
+	require.NoError(t, b.Close())
+	err := Tee(a, b).Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "closed", 0))
+	require.ErrorIs(t, err, easyslog.ErrClosed)
+	require.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 2)
+}
@@ -0,0 +1,91 @@
+package easyslog
+
+import (
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DefaultStackTraceDepth is the number of frames captured when
+// Options.StackTraceDepth is zero.
+const DefaultStackTraceDepth = 32
+
+// StackKey is the key of the attribute added for Options.StackTraceLevel.
+const StackKey = "stack"
+
+// callerPrefixes are the packages whose leading frames are skipped when the
+// record has no PC to start the stack from.
+var callerPrefixes = []string{"log/slog.", "github.com/blakewilliams/easyslog."}
+
+// stackAttrs captures the stack of the code that logged the record. It starts
+// at the frame for pc, which slog sets to the caller of the Logger method, so
+// it's correct for Info, Log, LogAttrs, and wrappers that set the PC
+// themselves. Without a pc, or when pc isn't on the stack, e.g. for a wrapper
+// calling Handle directly, leading slog and easyslog frames are skipped
+// instead.
+func (handler *EasySlog) stackAttrs(pc uintptr) []*Attr {
+	depth := handler.opts.StackTraceDepth
+	if depth <= 0 {
+		depth = DefaultStackTraceDepth
+	}
+
+	// Capture a few extra frames to cover slog and easyslog themselves.
+	pcs := make([]uintptr, depth+16)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+
+	leading := true
+	if pc != 0 {
+		for i, framePC := range pcs {
+			// Callers returns return addresses, which is how slog records PC too.
+			if framePC == pc {
+				pcs = pcs[i:]
+				leading = false
+				break
+			}
+		}
+	}
+
+	var lines []string
+	frames := runtime.CallersFrames(pcs)
+	for len(lines) < depth {
+		frame, more := frames.Next()
+		if !leading || !hasCallerPrefix(frame.Function) {
+			leading = false
+			lines = append(lines, frame.Function+" "+filepath.Base(frame.File)+":"+strconv.Itoa(frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+
+	if handler.opts.StackTraceString {
+		return []*Attr{{Key: StackKey, Value: slog.StringValue(strings.Join(lines, "\n"))}}
+	}
+
+	frameAttrs := make([]*Attr, len(lines))
+	for i, line := range lines {
+		key := "frame." + strconv.Itoa(i)
+		if handler.opts.FlattenGroups != "" {
+			key = joinKey(StackKey, key, handler.opts.FlattenGroups)
+		}
+		frameAttrs[i] = &Attr{Key: key, Value: slog.StringValue(line)}
+	}
+
+	if handler.opts.FlattenGroups != "" {
+		return frameAttrs
+	}
+
+	return []*Attr{{Key: StackKey, Value: slog.AnyValue(nil), Children: frameAttrs, group: true}}
+}
+
+func hasCallerPrefix(function string) bool {
+	for _, prefix := range callerPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,129 @@
+package easyslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func stackLines(t *testing.T, record Record) []string {
+	t.Helper()
+
+	var lines []string
+	for i := 0; ; i++ {
+		value, ok := record.Get(StackKey, "frame."+strconv.Itoa(i))
+		if !ok {
+			return lines
+		}
+		lines = append(lines, value.String())
+	}
+}
+
+func logError(l *slog.Logger, msg string) {
+	l.Error(msg)
+}
+
+func TestStackTrace(t *testing.T) {
+	level := slog.LevelError
+	formatter := &recordingFormatter{}
+	l := slog.New(New(io.Discard, formatter, &Options{StackTraceLevel: &level}))
+
+	l.Error("error")
+	l.Log(context.Background(), slog.LevelError, "log")
+	l.LogAttrs(context.Background(), slog.LevelError+4, "attrs")
+	logError(l, "helper")
+	l.Warn("warn")
+
+	require.Len(t, formatter.records, 5)
+	for _, record := range formatter.records[:3] {
+		lines := stackLines(t, record)
+		require.NotEmpty(t, lines, record.Message)
+		require.True(t, strings.HasPrefix(lines[0], "github.com/blakewilliams/easyslog.TestStackTrace stack_test.go:"), lines[0])
+	}
+
+	lines := stackLines(t, formatter.records[3])
+	require.True(t, strings.HasPrefix(lines[0], "github.com/blakewilliams/easyslog.logError stack_test.go:"), lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "github.com/blakewilliams/easyslog.TestStackTrace stack_test.go:"), lines[1])
+
+	_, ok := formatter.records[4].Get(StackKey, "frame.0")
+	require.False(t, ok)
+}
+
+func TestStackTraceDepthAndString(t *testing.T) {
+	level := slog.LevelInfo
+	formatter := &recordingFormatter{}
+	l := slog.New(New(io.Discard, formatter, &Options{StackTraceLevel: &level, StackTraceDepth: 2, StackTraceString: true}))
+
+	logError(l, "helper")
+
+	value, ok := formatter.records[0].Get(StackKey)
+	require.True(t, ok)
+
+	lines := strings.Split(value.String(), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], "easyslog.logError")
+	require.Contains(t, lines[1], "easyslog.TestStackTraceDepthAndString")
+}
+
+func TestStackTraceFlatten(t *testing.T) {
+	level := slog.LevelInfo
+	formatter := &recordingFormatter{}
+	l := slog.New(New(io.Discard, formatter, &Options{StackTraceLevel: &level, StackTraceDepth: 1, FlattenGroups: "."}))
+
+	l.Info("msg")
+
+	value, ok := formatter.records[0].Get("stack.frame.0")
+	require.True(t, ok)
+	require.Contains(t, value.String(), "easyslog.TestStackTraceFlatten")
+}
+
+func TestStackTraceWithoutPC(t *testing.T) {
+	level := slog.LevelInfo
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{StackTraceLevel: &level})
+
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "no pc", 0)))
+
+	// Frames in this package are skipped too, so the stack starts in testing
+	lines := stackLines(t, formatter.records[0])
+	require.NotEmpty(t, lines)
+	require.True(t, strings.HasPrefix(lines[0], "testing.tRunner"), lines[0])
+}
+
+// returnedPC returns a PC from a function that has returned, so it isn't on
+// the stack of the caller.
+func returnedPC() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	return pcs[0]
+}
+
+func TestStackTracePCNotOnStack(t *testing.T) {
+	level := slog.LevelInfo
+	formatter := &recordingFormatter{}
+	handler := New(io.Discard, formatter, &Options{StackTraceLevel: &level})
+
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "wrapped", returnedPC())))
+
+	// Leading slog and easyslog frames are skipped like without a PC
+	lines := stackLines(t, formatter.records[0])
+	require.NotEmpty(t, lines)
+	require.True(t, strings.HasPrefix(lines[0], "testing.tRunner"), lines[0])
+}
+
+func BenchmarkStackTraceBelowLevel(b *testing.B) {
+	level := slog.LevelError
+	l := slog.New(New(io.Discard, FastJSONFormatter{}, &Options{StackTraceLevel: &level}))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Info("no stack")
+	}
+}
@@ -0,0 +1,125 @@
+package easyslog
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the lines handled by an EasySlog, returned by
+// (*EasySlog).Stats. Levels between the standard ones are counted with the
+// nearest standard level below them, and levels below Debug as Debug.
+type Stats struct {
+	// Debug, Info, Warn and Error are the number of lines written at each
+	// level, including records passed to a RecordWriter.
+	Debug uint64
+	Info  uint64
+	Warn  uint64
+	Error uint64
+	// Bytes is the number of bytes written, including trailing newlines.
+	// Records passed to a RecordWriter don't count towards it.
+	Bytes uint64
+	// FormatErrors, WriteErrors, Sampled, InvalidLines, Closed and
+	// Suppressed are the number of lines dropped for each of the Drop
+	// reasons.
+	FormatErrors uint64
+	WriteErrors  uint64
+	Sampled      uint64
+	InvalidLines uint64
+	Closed       uint64
+	Suppressed   uint64
+}
+
+// Written returns the number of lines written at any level.
+func (s Stats) Written() uint64 {
+	return s.Debug + s.Info + s.Warn + s.Error
+}
+
+// Dropped returns the number of lines dropped for any reason.
+func (s Stats) Dropped() uint64 {
+	return s.FormatErrors + s.WriteErrors + s.Sampled + s.InvalidLines + s.Closed + s.Suppressed
+}
+
+// handlerStats holds the counters behind Stats. It's shared by every handler
+// derived from the same call to New and only updated with atomics, so it adds
+// no lock contention.
+type handlerStats struct {
+	debug        atomic.Uint64
+	info         atomic.Uint64
+	warn         atomic.Uint64
+	error        atomic.Uint64
+	bytes        atomic.Uint64
+	formatErrors atomic.Uint64
+	writeErrors  atomic.Uint64
+	sampled      atomic.Uint64
+	invalidLines atomic.Uint64
+	closed       atomic.Uint64
+	suppressed   atomic.Uint64
+}
+
+// Stats returns a snapshot of the number of lines written and dropped by
+// handler and every handler derived from the same call to New, including
+// copies made with WithWriter and WithFormatter. The counters are read one
+// at a time, so a snapshot taken while lines are being handled may be off by
+// the lines in flight.
+func (handler *EasySlog) Stats() Stats {
+	s := handler.stats
+
+	return Stats{
+		Debug:        s.debug.Load(),
+		Info:         s.info.Load(),
+		Warn:         s.warn.Load(),
+		Error:        s.error.Load(),
+		Bytes:        s.bytes.Load(),
+		FormatErrors: s.formatErrors.Load(),
+		WriteErrors:  s.writeErrors.Load(),
+		Sampled:      s.sampled.Load(),
+		InvalidLines: s.invalidLines.Load(),
+		Closed:       s.closed.Load(),
+		Suppressed:   s.suppressed.Load(),
+	}
+}
+
+// observeRecord counts a written line of n bytes and notifies
+// Options.Observer, if set, with the time since start.
+func (handler *EasySlog) observeRecord(level slog.Level, n int, start time.Time) {
+	s := handler.stats
+	switch standardLevel(level) {
+	case slog.LevelError:
+		s.error.Add(1)
+	case slog.LevelWarn:
+		s.warn.Add(1)
+	case slog.LevelInfo:
+		s.info.Add(1)
+	default:
+		s.debug.Add(1)
+	}
+	s.bytes.Add(uint64(n))
+
+	if handler.opts.Observer != nil {
+		handler.opts.Observer.ObserveRecord(level, n, time.Since(start))
+	}
+}
+
+// observeDrop counts a dropped line and notifies Options.Observer, if set.
+func (handler *EasySlog) observeDrop(level slog.Level, reason string) {
+	s := handler.stats
+	switch reason {
+	case DropFormatError:
+		s.formatErrors.Add(1)
+	case DropWriteError:
+		s.writeErrors.Add(1)
+	case DropSampled:
+		s.sampled.Add(1)
+	case DropInvalidLine:
+		s.invalidLines.Add(1)
+	case DropClosed:
+		s.closed.Add(1)
+	case DropSuppressed:
+		s.suppressed.Add(1)
+	}
+
+	if handler.opts.Observer != nil {
+		handler.opts.Observer.ObserveDrop(level, reason)
+	}
+}
@@ -0,0 +1,83 @@
+package easyslog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	var b bytes.Buffer
+	formatter := FormatterFunc(func(w io.Writer, r Record) error {
+		if r.Message == "fail" {
+			return errors.New("nope")
+		}
+
+		_, err := io.WriteString(w, r.Message)
+		return err
+	})
+
+	handler := New(&b, formatter, &Options{Level: slog.LevelDebug})
+	l := slog.New(handler)
+
+	l.Debug("d")
+	l.Debug("d")
+	l.Info("i")
+	l.Log(context.Background(), slog.LevelInfo+2, "i2")
+	l.Warn("w")
+	l.Error("e")
+	l.Log(context.Background(), slog.LevelError+4, "e4")
+	l.Error("fail")
+	l.Info("fail")
+
+	want := Stats{Debug: 2, Info: 2, Warn: 1, Error: 2, Bytes: uint64(b.Len()), FormatErrors: 2}
+	require.Equal(t, want, handler.Stats())
+	require.Equal(t, uint64(7), handler.Stats().Written())
+	require.Equal(t, uint64(2), handler.Stats().Dropped())
+
+	// Derived handlers, including ones writing elsewhere, share the counters
+	slog.New(handler.WithWriter(failingWriter{})).With("a", 1).Info("lost")
+	require.NoError(t, handler.Close())
+	require.ErrorIs(t, handler.WithGroup("g").Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0)), ErrClosed)
+
+	want.WriteErrors, want.Closed = 1, 1
+	require.Equal(t, want, handler.Stats())
+}
+
+func TestStatsDrops(t *testing.T) {
+	handler := New(io.Discard, FastJSONFormatter{}, &Options{
+		Sampler:      func(ctx context.Context, r slog.Record) bool { return r.Message != "sampled" },
+		ValidateLine: func(line []byte) error { return errors.New("invalid") },
+	})
+	l := slog.New(handler)
+
+	l.Info("sampled")
+	l.Info("invalid")
+	l.Debug("disabled")
+
+	require.Equal(t, Stats{Sampled: 1, InvalidLines: 1}, handler.Stats())
+}
+
+func TestStatsRecordWriter(t *testing.T) {
+	handler := New(&recordWriter{}, FastJSONFormatter{}, nil)
+	slog.New(handler).Warn("hi")
+
+	require.Equal(t, Stats{Warn: 1}, handler.Stats())
+}
+
+func BenchmarkStats(b *testing.B) {
+	l := slog.New(New(io.Discard, FastJSONFormatter{}, nil))
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Info("hello", "foo", "bar")
+		}
+	})
+}
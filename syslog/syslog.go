@@ -0,0 +1,248 @@
+// Package syslog implements an easyslog.Formatter that renders records as RFC
+// 5424 syslog messages, e.g. for an on-host collector.
+package syslog
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blakewilliams/easyslog"
+)
+
+// Facility is a syslog facility code.
+type Facility int
+
+// Facilities that applications commonly log to.
+const (
+	User   Facility = 1
+	Mail   Facility = 2
+	Daemon Facility = 3
+	Auth   Facility = 4
+	Local0 Facility = 16
+	Local1 Facility = 17
+	Local2 Facility = 18
+	Local3 Facility = 19
+	Local4 Facility = 20
+	Local5 Facility = 21
+	Local6 Facility = 22
+	Local7 Facility = 23
+)
+
+// DefaultEnterpriseID is the private enterprise number used in structured data
+// IDs when Formatter.EnterpriseID is empty. It's the number reserved for
+// documentation by RFC 5612.
+const DefaultEnterpriseID = "32473"
+
+// TimeFormat is the RFC 3339 layout used for the TIMESTAMP field, which RFC 5424
+// limits to microsecond precision.
+const TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Formatter implements easyslog.Formatter and renders each record as
+// `<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG`.
+//
+// Top-level attributes become parameters of an `attrs@<EnterpriseID>`
+// structured data element and each top-level group becomes an element of its
+// own, e.g. `[request@32473 method="GET" header.host="example.com"]`.
+type Formatter struct {
+	// Hostname is the HOSTNAME field. Defaults to os.Hostname.
+	Hostname string
+	// AppName is the APP-NAME field. Defaults to the executable's name.
+	AppName string
+	// Facility is combined with the level's severity into PRI. Defaults to
+	// User, since the kernel facility (0) isn't meant for applications.
+	Facility Facility
+	// MsgID is the MSGID field. Defaults to the nil value `-`.
+	MsgID string
+	// EnterpriseID is appended to structured data IDs after an `@`, as RFC
+	// 5424 requires for IDs that aren't registered with IANA. Defaults to
+	// DefaultEnterpriseID.
+	EnterpriseID string
+}
+
+var _ easyslog.Formatter = (*Formatter)(nil)
+
+func init() {
+	easyslog.RegisterFormatter("syslog", func(opts map[string]any) (easyslog.Formatter, error) {
+		var f Formatter
+		if err := easyslog.DecodeOptions(opts, &f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	})
+}
+
+var defaults = sync.OnceValues(func() (string, string) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "-"
+	}
+
+	return host, filepath.Base(os.Args[0])
+})
+
+var pid = strconv.Itoa(os.Getpid())
+
+func (f Formatter) Format(w io.Writer, record easyslog.Record) error {
+	defaultHost, defaultApp := defaults()
+
+	hostname := f.Hostname
+	if hostname == "" {
+		hostname = defaultHost
+	}
+	appName := f.AppName
+	if appName == "" {
+		appName = defaultApp
+	}
+	facility := f.Facility
+	if facility == 0 {
+		facility = User
+	}
+	enterpriseID := f.EnterpriseID
+	if enterpriseID == "" {
+		enterpriseID = DefaultEnterpriseID
+	}
+
+	var b strings.Builder
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(int(facility)*8 + Severity(record.Level)))
+	b.WriteString(">1 ")
+
+	if record.Time.IsZero() {
+		b.WriteByte('-')
+	} else {
+		b.WriteString(record.Time.Format(TimeFormat))
+	}
+
+	for _, field := range []struct {
+		value  string
+		maxLen int
+	}{{hostname, 255}, {appName, 48}, {pid, 128}, {f.MsgID, 32}} {
+		b.WriteByte(' ')
+		b.WriteString(headerField(field.value, field.maxLen))
+	}
+
+	b.WriteByte(' ')
+	f.writeStructuredData(&b, record.Attrs, enterpriseID)
+
+	if record.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(record.Message)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f Formatter) writeStructuredData(b *strings.Builder, attrs []*easyslog.Attr, enterpriseID string) {
+	var leaves []*easyslog.Attr
+	var groups []*easyslog.Attr
+	for _, attr := range attrs {
+		if attr.IsGroup() {
+			groups = append(groups, attr)
+		} else {
+			leaves = append(leaves, attr)
+		}
+	}
+
+	if len(leaves) == 0 && len(groups) == 0 {
+		b.WriteByte('-')
+		return
+	}
+
+	if len(leaves) > 0 {
+		writeElement(b, "attrs@"+enterpriseID, leaves)
+	}
+
+	for _, group := range groups {
+		writeElement(b, sdName(group.Key, 32-len(enterpriseID)-1)+"@"+enterpriseID, group.Children)
+	}
+}
+
+func writeElement(b *strings.Builder, id string, attrs []*easyslog.Attr) {
+	b.WriteByte('[')
+	b.WriteString(id)
+	for _, attr := range attrs {
+		writeParam(b, attr, "")
+	}
+	b.WriteByte(']')
+}
+
+func writeParam(b *strings.Builder, attr *easyslog.Attr, prefix string) {
+	name := attr.Key
+	if prefix != "" {
+		name = prefix + "." + name
+	}
+
+	if attr.IsGroup() {
+		for _, child := range attr.Children {
+			writeParam(b, child, name)
+		}
+		return
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(sdName(name, 32))
+	b.WriteString(`="`)
+	writeParamValue(b, attr.Value.String())
+	b.WriteByte('"')
+}
+
+// writeParamValue escapes the characters RFC 5424 requires in PARAM-VALUE.
+func writeParamValue(b *strings.Builder, s string) {
+	for _, r := range s {
+		if r == '"' || r == '\\' || r == ']' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+}
+
+// Severity maps a slog level to a syslog severity, see
+// easyslog.SyslogSeverity.
+func Severity(level slog.Level) int {
+	return easyslog.SyslogSeverity(level)
+}
+
+// headerField returns s limited to printable ASCII and maxLen bytes, or the nil
+// value `-` if it's empty.
+func headerField(s string, maxLen int) string {
+	if s == "" {
+		return "-"
+	}
+
+	return sanitize(s, maxLen, func(r rune) bool { return r > ' ' && r < 0x7f })
+}
+
+// sdName returns s as a valid SD-NAME: printable ASCII other than `=`, space,
+// `]`, and `"`, at most maxLen bytes. Invalid characters become underscores.
+func sdName(s string, maxLen int) string {
+	if s == "" {
+		return "_"
+	}
+
+	return sanitize(s, maxLen, func(r rune) bool {
+		return r > ' ' && r < 0x7f && r != '=' && r != ']' && r != '"' && r != '@'
+	})
+}
+
+func sanitize(s string, maxLen int, valid func(rune) bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		if b.Len() == maxLen {
+			break
+		}
+		if valid(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blakewilliams/easyslog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := Formatter{Hostname: "web-1", AppName: "api", Facility: Local0, MsgID: "REQ"}
+	handler := easyslog.New(&buf, formatter, nil)
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	r := slog.NewRecord(ts, slog.LevelWarn, "slow request", 0)
+	r.AddAttrs(
+		slog.Int("ms", 250),
+		slog.Group("request", slog.String("method", "GET"), slog.Group("header", slog.String("host", "example.com"))),
+		slog.String("note", `say "hi" [x] \o/`),
+	)
+	require.NoError(t, handler.Handle(context.Background(), r))
+
+	want := "<132>1 2024-01-02T03:04:05.123456Z web-1 api " + strconv.Itoa(os.Getpid()) + " REQ " +
+		`[attrs@32473 ms="250" note="say \"hi\" [x\] \\o/"]` +
+		`[request@32473 method="GET" header.host="example.com"] slow request` + "\n"
+	require.Equal(t, want, buf.String())
+}
+
+func TestDefaults(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(easyslog.New(&buf, Formatter{}, nil)).Info("hello")
+
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	fields := strings.SplitN(buf.String(), " ", 8)
+	require.Equal(t, "<14>1", fields[0])
+	require.Equal(t, host, fields[2])
+	require.Equal(t, filepath.Base(os.Args[0]), fields[3])
+	require.Equal(t, "-", fields[5])
+	require.Equal(t, "-", fields[6])
+	require.Equal(t, "hello\n", fields[7])
+}
+
+func TestSanitize(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := Formatter{Hostname: "my host", AppName: "app", EnterpriseID: "1"}
+	slog.New(easyslog.New(&buf, formatter, nil)).Error("", "a=b c", 1, slog.Group("g@x", "k", 2))
+
+	line := buf.String()
+	require.Contains(t, line, " my_host app ")
+	require.True(t, strings.HasSuffix(line, `[attrs@1 a_b_c="1"][g_x@1 k="2"]`+"\n"), line)
+}
+
+func TestSeverity(t *testing.T) {
+	require.Equal(t, 7, Severity(slog.LevelDebug))
+	require.Equal(t, 6, Severity(slog.LevelInfo))
+	require.Equal(t, 4, Severity(slog.LevelWarn+1))
+	require.Equal(t, 3, Severity(slog.LevelError))
+}
@@ -0,0 +1,71 @@
+package easyslog
+
+import (
+	"strings"
+)
+
+// Interpolate returns an Options.MessageTemplate that replaces each `{path}`
+// placeholder in the message with the value of the leaf attribute at the
+// dot-separated path, e.g. `{user.id}`, so messages from printf-style loggers
+// can keep their shape while the values are also logged as attributes. Paths
+// are also looked up as a single key, which matches attributes flattened with
+// a `.` FlattenGroups separator. Placeholders without a matching attribute are
+// left as-is when missing is empty, and replaced with missing otherwise.
+func Interpolate(missing string) func(msg string, r Record) string {
+	return func(msg string, r Record) string {
+		if !strings.Contains(msg, "{") {
+			return msg
+		}
+
+		var b strings.Builder
+		b.Grow(len(msg))
+
+		for {
+			start := strings.IndexByte(msg, '{')
+			if start < 0 {
+				break
+			}
+
+			end := strings.IndexByte(msg[start+1:], '}')
+			if end < 0 {
+				break
+			}
+			end += start + 1
+
+			path := msg[start+1 : end]
+			b.WriteString(msg[:start])
+
+			if value, ok := lookupPath(r, path); ok {
+				b.WriteString(value)
+			} else if missing != "" && path != "" {
+				b.WriteString(missing)
+			} else {
+				b.WriteString(msg[start : end+1])
+			}
+
+			msg = msg[end+1:]
+		}
+
+		b.WriteString(msg)
+
+		return b.String()
+	}
+}
+
+// lookupPath returns the rendered value of the leaf attribute at the
+// dot-separated path in r.
+func lookupPath(r Record, path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+
+	if value, ok := r.Get(strings.Split(path, ".")...); ok {
+		return value.String(), true
+	}
+
+	if value, ok := r.Get(path); ok {
+		return value.String(), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,42 @@
+package easyslog
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	r := record(slog.Int("id", 42), slog.Group("user", slog.String("name", "fox")), slog.Group("empty"))
+
+	for msg, want := range map[string]string{
+		"no placeholders":              "no placeholders",
+		"user {user.name} logged in":   "user fox logged in",
+		"{id}/{id}":                    "42/42",
+		"missing {user.id} and {nope}": "missing {user.id} and {nope}",
+		"group {user} and {}":          "group {user} and {}",
+		"unclosed {id":                 "unclosed {id",
+		"}{id}{":                       "}42{",
+	} {
+		require.Equal(t, want, Interpolate("")(msg, r), msg)
+	}
+
+	require.Equal(t, "missing ? and {}", Interpolate("?")("missing {nope} and {}", r))
+}
+
+func TestMessageTemplate(t *testing.T) {
+	for name, opts := range map[string]Options{"tree": {}, "flat": {FlattenGroups: "."}} {
+		t.Run(name, func(t *testing.T) {
+			formatter := &recordingFormatter{}
+			opts.ReplaceMessage = func(msg string) string { return msg + " in {ms}ms" }
+			opts.MessageTemplate = Interpolate("<missing>")
+
+			slog.New(New(io.Discard, formatter, &opts)).With("ms", 12).WithGroup("user").
+				Info("{user.id} logged in from {user.ip}", "id", 7)
+
+			require.Equal(t, "7 logged in from <missing> in 12ms", formatter.records[0].Message)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package easyslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	// ErrInteriorNewline is returned by the NoInteriorNewlines validator.
+	ErrInteriorNewline = errors.New("easyslog: formatted line contains a newline")
+	// ErrInvalidJSON is returned by the ValidJSON validator.
+	ErrInvalidJSON = errors.New("easyslog: formatted line is not valid JSON")
+)
+
+var newline = []byte{'\n'}
+
+// NoInteriorNewlines returns an Options.ValidateLine that rejects lines
+// containing a newline or carriage return, which would split the line for
+// consumers of newline-delimited formats like NDJSON. A single newline ending
+// the line is allowed, since Handle doesn't add another.
+func NoInteriorNewlines() func(line []byte) error {
+	return func(line []byte) error {
+		line = bytes.TrimSuffix(line, newline)
+		if bytes.IndexByte(line, '\n') >= 0 || bytes.IndexByte(line, '\r') >= 0 {
+			return ErrInteriorNewline
+		}
+
+		return nil
+	}
+}
+
+// ValidJSON returns an Options.ValidateLine that rejects lines that aren't a
+// single valid JSON value. Valid JSON can still contain newlines between
+// tokens, so combine it with NoInteriorNewlines for NDJSON.
+func ValidJSON() func(line []byte) error {
+	return func(line []byte) error {
+		if !json.Valid(line) {
+			return ErrInvalidJSON
+		}
+
+		return nil
+	}
+}
+
+// sanitizeLine replaces the newlines and carriage returns in line with `\n`
+// and `\r` escapes, dropping a newline that ends it for Handle to add back.
+func sanitizeLine(line []byte) []byte {
+	line = bytes.TrimSuffix(line, newline)
+	if bytes.IndexByte(line, '\n') < 0 && bytes.IndexByte(line, '\r') < 0 {
+		return line
+	}
+
+	sanitized := make([]byte, 0, len(line)+8)
+	for _, c := range line {
+		switch c {
+		case '\n':
+			sanitized = append(sanitized, '\\', 'n')
+		case '\r':
+			sanitized = append(sanitized, '\\', 'r')
+		default:
+			sanitized = append(sanitized, c)
+		}
+	}
+
+	return sanitized
+}
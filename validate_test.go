@@ -0,0 +1,57 @@
+package easyslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rawErrorFormatter writes the message unescaped, like a sloppy formatter
+// writing a multi-line error string.
+type rawErrorFormatter struct{}
+
+func (rawErrorFormatter) Format(w io.Writer, r Record) error {
+	_, err := io.WriteString(w, `{"msg":"`+r.Message+`"}`)
+	return err
+}
+
+func TestNoInteriorNewlines(t *testing.T) {
+	observer := newFakeObserver()
+	var b bytes.Buffer
+	handler := New(&b, rawErrorFormatter{}, &Options{ValidateLine: NoInteriorNewlines(), Observer: observer})
+
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "fine", 0)))
+	err := handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "query failed\n\tat db.go:12", 0))
+	require.ErrorIs(t, err, ErrInteriorNewline)
+	require.ErrorIs(t, handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "a\rb", 0)), ErrInteriorNewline)
+
+	require.Equal(t, "{\"msg\":\"fine\"}\n", b.String())
+	require.Equal(t, 2, observer.drops[DropInvalidLine])
+}
+
+func TestSanitizeInvalidLines(t *testing.T) {
+	var b bytes.Buffer
+	handler := New(&b, rawErrorFormatter{}, &Options{ValidateLine: NoInteriorNewlines(), SanitizeInvalidLines: true})
+
+	err := handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "query failed\r\nat db.go:12", 0))
+	require.ErrorIs(t, err, ErrInteriorNewline)
+	require.Equal(t, "{\"msg\":\"query failed\\r\\nat db.go:12\"}\n", b.String())
+	require.True(t, json.Valid(bytes.TrimSuffix(b.Bytes(), []byte("\n"))))
+}
+
+func TestValidJSON(t *testing.T) {
+	var b bytes.Buffer
+	handler := New(&b, rawErrorFormatter{}, &Options{ValidateLine: ValidJSON()})
+
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "fine", 0)))
+	err := handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, `say "hi"`, 0))
+	require.ErrorIs(t, err, ErrInvalidJSON)
+
+	require.Equal(t, "{\"msg\":\"fine\"}\n", b.String())
+}
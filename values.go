@@ -0,0 +1,107 @@
+package easyslog
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+)
+
+// DefaultValueStringer is an Options.ValueStringer for well-known types that
+// render poorly or unsafely by default:
+//
+//   - URLs render with URL.Redacted, so a password in the userinfo becomes
+//     `xxxxx` instead of leaking into logs.
+//   - net.IP, *net.IPNet and netip types render in their usual notation, with
+//     IPv4-mapped IPv6 addresses shortened to IPv4, e.g. `10.0.0.1`.
+//
+// Other values, including nil pointers, are left alone.
+func DefaultValueStringer(v any) (string, bool) {
+	switch v := v.(type) {
+	case *url.URL:
+		if v != nil {
+			return v.Redacted(), true
+		}
+	case url.URL:
+		return v.Redacted(), true
+	case net.IP:
+		if len(v) > 0 {
+			return v.String(), true
+		}
+	case *net.IPNet:
+		if v != nil {
+			return v.String(), true
+		}
+	case netip.Addr:
+		if v.IsValid() {
+			return v.Unmap().String(), true
+		}
+	case netip.AddrPort:
+		if v.IsValid() {
+			return netip.AddrPortFrom(v.Addr().Unmap(), v.Port()).String(), true
+		}
+	case netip.Prefix:
+		if v.IsValid() {
+			return v.String(), true
+		}
+	}
+
+	return "", false
+}
+
+// StringerFor returns an Options.ValueStringer that renders values of type T
+// with fn and leaves any other value alone.
+func StringerFor[T any](fn func(T) string) func(v any) (string, bool) {
+	return func(v any) (string, bool) {
+		if t, ok := v.(T); ok {
+			return fn(t), true
+		}
+
+		return "", false
+	}
+}
+
+// ChainStringers returns an Options.ValueStringer that tries each stringer in
+// order and uses the first that handles the value.
+func ChainStringers(stringers ...func(v any) (string, bool)) func(v any) (string, bool) {
+	return func(v any) (string, bool) {
+		for _, stringer := range stringers {
+			if str, ok := stringer(v); ok {
+				return str, true
+			}
+		}
+
+		return "", false
+	}
+}
+
+// ByteSize is a number of bytes that renders in binary units, e.g. `1.5 KiB`,
+// for formatters that render values through fmt.Stringer, like prettylog.
+// Formatters that keep numbers, like JSON ones, remain free to write the
+// plain count. Opt in by logging slog.Any("size", easyslog.ByteSize(n)).
+type ByteSize int64
+
+// String returns the size with one decimal in the largest unit it's at least
+// one of, e.g. `512 B`, `1.5 KiB` or `2.0 GiB`.
+func (b ByteSize) String() string {
+	const units = "KMGTPE"
+
+	// Negate as a uint64 so math.MinInt64 doesn't overflow
+	sign, abs := "", uint64(b)
+	if b < 0 {
+		sign, abs = "-", -abs
+	}
+
+	if abs < 1024 {
+		return sign + strconv.FormatUint(abs, 10) + " B"
+	}
+
+	unit := 0
+	div := uint64(1024)
+	for abs/div >= 1024 && unit < len(units)-1 {
+		div *= 1024
+		unit++
+	}
+
+	return sign + strconv.FormatFloat(float64(abs)/float64(div), 'f', 1, 64) + " " + units[unit:unit+1] + "iB"
+}
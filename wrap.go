@@ -0,0 +1,164 @@
+package easyslog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// WrapOptions configures a handler returned by Wrap. The fields behave like
+// their counterparts in Options.
+type WrapOptions struct {
+	// Level, when set, is a minimum level records must reach in addition to
+	// being enabled by the wrapped handler. By default only the wrapped
+	// handler decides.
+	Level               slog.Leveler
+	MaxLevel            slog.Leveler
+	MinLevelFromContext func(ctx context.Context) (slog.Level, bool)
+	BaseAttrs           []slog.Attr
+	Prelude             []slog.Attr
+	MaxValueBytes       int
+	MaxAttrs            int
+	MaxDepth            int
+	ReplaceMessage      func(msg string) string
+	MessageTemplate     func(msg string, r Record) string
+	TransformRecord     func(r *Record)
+	Tap                 func(Record)
+	KeyTransformer      func(path []string, key string) string
+	KeySanitizer        func(key string) string
+	ValueStringer       func(v any) (string, bool)
+	OmitTime            bool
+	TimeZone            *time.Location
+	Sampler             func(ctx context.Context, r slog.Record) bool
+	SortAttrs           bool
+	EmptyKeys           EmptyKeyMode
+	// Observer is notified of every record passed to the wrapped handler, and
+	// of those it returns an error for as DropWriteError. The number of bytes
+	// written is always reported as zero.
+	Observer Observer
+}
+
+// wrapHandler builds Records with an EasySlog and hands them to next.
+type wrapHandler struct {
+	handler *EasySlog
+	next    slog.Handler
+}
+
+var _ slog.Handler = (*wrapHandler)(nil)
+
+// Wrap returns a slog.Handler that builds each record's attribute tree like
+// EasySlog, runs the WrapOptions hooks over it, and then converts it back into
+// a slog.Record for next instead of formatting it. Attributes and groups added
+// via WithAttrs and WithGroup are kept in the tree and passed to next as
+// slog.Group attributes, so hooks see every attribute of the line. next's own
+// attributes, groups and Enabled are still honored.
+func Wrap(next slog.Handler, opts *WrapOptions) slog.Handler {
+	if opts == nil {
+		opts = &WrapOptions{}
+	}
+
+	level := opts.Level
+	if level == nil {
+		level = slog.Level(math.MinInt)
+	}
+
+	handler := New(io.Discard, nil, &Options{
+		Level:               level,
+		MaxLevel:            opts.MaxLevel,
+		MinLevelFromContext: opts.MinLevelFromContext,
+		BaseAttrs:           opts.BaseAttrs,
+		Prelude:             opts.Prelude,
+		MaxValueBytes:       opts.MaxValueBytes,
+		MaxAttrs:            opts.MaxAttrs,
+		MaxDepth:            opts.MaxDepth,
+		ReplaceMessage:      opts.ReplaceMessage,
+		MessageTemplate:     opts.MessageTemplate,
+		TransformRecord:     opts.TransformRecord,
+		Tap:                 opts.Tap,
+		KeyTransformer:      opts.KeyTransformer,
+		KeySanitizer:        opts.KeySanitizer,
+		ValueStringer:       opts.ValueStringer,
+		OmitTime:            opts.OmitTime,
+		TimeZone:            opts.TimeZone,
+		Sampler:             opts.Sampler,
+		SortAttrs:           opts.SortAttrs,
+		EmptyKeys:           opts.EmptyKeys,
+		Observer:            opts.Observer,
+	})
+
+	return &wrapHandler{handler: handler, next: next}
+}
+
+// Enabled reports whether both the wrapper's level and next allow level.
+func (w *wrapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return w.handler.Enabled(ctx, level) && w.next.Enabled(ctx, level)
+}
+
+// WithAttrs returns a new handler whose attributes are always logged.
+func (w *wrapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &wrapHandler{handler: w.handler.WithAttrs(attrs).(*EasySlog), next: w.next}
+}
+
+// WithGroup returns a new handler that nests all attributes in the provided
+// group.
+func (w *wrapHandler) WithGroup(name string) slog.Handler {
+	return &wrapHandler{handler: w.handler.WithGroup(name).(*EasySlog), next: w.next}
+}
+
+// Handle builds the Record and passes it to next as a slog.Record.
+func (w *wrapHandler) Handle(ctx context.Context, r slog.Record) error {
+	record, ok := w.handler.buildRecord(ctx, r, nil)
+	if !ok {
+		return nil
+	}
+
+	observer := w.handler.opts.Observer
+	var start time.Time
+	if observer != nil {
+		start = time.Now()
+	}
+
+	out := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	for _, attr := range record.Attrs {
+		out.AddAttrs(attr.ToSlogAttr())
+	}
+
+	err := w.next.Handle(ctx, out)
+	if observer != nil {
+		if err != nil {
+			observer.ObserveDrop(record.Level, DropWriteError)
+		} else {
+			observer.ObserveRecord(record.Level, 0, time.Since(start))
+		}
+	}
+
+	return err
+}
+
+// ToSlogAttr converts a and its children back into a slog.Attr, with groups
+// as slog.Group values. Values logged via RawJSON become json.RawMessage when
+// they're valid JSON so slog's JSON handler embeds them, and strings
+// otherwise.
+func (a *Attr) ToSlogAttr() slog.Attr {
+	if a.IsGroup() {
+		attrs := make([]slog.Attr, len(a.Children))
+		for i, child := range a.Children {
+			attrs[i] = child.ToSlogAttr()
+		}
+
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	}
+
+	if data, ok := a.RawJSON(); ok {
+		if json.Valid(data) {
+			return slog.Any(a.Key, json.RawMessage(data))
+		}
+
+		return slog.String(a.Key, string(data))
+	}
+
+	return slog.Attr{Key: a.Key, Value: a.Value}
+}
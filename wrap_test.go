@@ -0,0 +1,138 @@
+package easyslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapSlogtest(t *testing.T) {
+	var buf bytes.Buffer
+	handler := Wrap(slog.NewJSONHandler(&buf, nil), nil)
+
+	err := slogtest.TestHandler(handler, func() []map[string]any {
+		var results []map[string]any
+		for _, line := range bytes.Split(buf.Bytes(), []byte{'\n'}) {
+			if len(line) == 0 {
+				continue
+			}
+
+			var result map[string]any
+			require.NoError(t, json.Unmarshal(line, &result))
+			results = append(results, result)
+		}
+
+		return results
+	})
+
+	require.NoError(t, err)
+}
+
+func TestWrapHooks(t *testing.T) {
+	var buf bytes.Buffer
+	next := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	handler := Wrap(next, &WrapOptions{
+		BaseAttrs:      []slog.Attr{slog.String("app", "web")},
+		MaxValueBytes:  3,
+		ReplaceMessage: func(msg string) string { return msg + "!" },
+		TransformRecord: func(r *Record) {
+			if _, ok := r.Get("request", "token"); ok {
+				r.Delete("request", "token")
+				r.Add(slog.Bool("redacted", true), "request")
+			}
+		},
+	})
+
+	l := slog.New(handler).WithGroup("request").With("token", "secret")
+	l.Info("msg", "path", "/users")
+
+	require.Equal(t, "level=INFO msg=msg! app=web request.path=\"/us…(truncated 3 bytes)\" request.redacted=true\n", buf.String())
+}
+
+func TestWrapKeepsNextState(t *testing.T) {
+	var buf bytes.Buffer
+	next := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}).
+		WithAttrs([]slog.Attr{slog.String("service", "api")}).
+		WithGroup("payload")
+
+	l := slog.New(Wrap(next, nil)).With("a", 1)
+	require.False(t, l.Enabled(context.Background(), slog.LevelInfo))
+	require.True(t, l.Enabled(context.Background(), slog.LevelWarn))
+
+	l.Warn("msg", slog.Group("g", "b", 2))
+
+	var result map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &result))
+	require.Equal(t, "api", result["service"])
+	require.Equal(t, map[string]any{"a": float64(1), "g": map[string]any{"b": float64(2)}}, result["payload"])
+}
+
+func TestWrapLevel(t *testing.T) {
+	next := slog.NewJSONHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelDebug})
+	handler := Wrap(next, &WrapOptions{Level: slog.LevelWarn})
+
+	require.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+	require.True(t, handler.Enabled(context.Background(), slog.LevelError))
+}
+
+func TestWrapRawJSON(t *testing.T) {
+	var buf bytes.Buffer
+	handler := Wrap(slog.NewJSONHandler(&buf, nil), nil)
+
+	slog.New(handler).Info("msg", RawJSON("body", []byte(`{"id": 1}`)), RawJSON("bad", []byte(`{`)))
+
+	var result map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &result))
+	require.Equal(t, map[string]any{"id": float64(1)}, result["body"])
+	require.Equal(t, "{", result["bad"])
+}
+
+type failingHandler struct {
+	slog.Handler
+}
+
+func (failingHandler) Handle(context.Context, slog.Record) error {
+	return errors.New("boom")
+}
+
+func TestWrapObserver(t *testing.T) {
+	observer := newFakeObserver()
+	l := slog.New(Wrap(slog.NewJSONHandler(&bytes.Buffer{}, nil), &WrapOptions{Observer: observer}))
+	l.Info("msg")
+	l.Error("msg")
+
+	require.Equal(t, 1, observer.records[slog.LevelInfo])
+	require.Equal(t, 1, observer.records[slog.LevelError])
+
+	observer = newFakeObserver()
+	failing := failingHandler{slog.NewJSONHandler(&bytes.Buffer{}, nil)}
+	err := Wrap(failing, &WrapOptions{Observer: observer}).Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "msg", 0))
+
+	require.EqualError(t, err, "boom")
+	require.Equal(t, 1, observer.drops[DropWriteError])
+}
+
+func TestToSlogAttr(t *testing.T) {
+	attr := &Attr{Key: "g", Children: []*Attr{
+		{Key: "a", Value: slog.IntValue(1)},
+		{Key: "h", Children: []*Attr{{Key: "b", Value: slog.StringValue("c")}}},
+	}}
+
+	want := slog.Group("g", slog.Int("a", 1), slog.Group("h", slog.String("b", "c")))
+	require.True(t, attr.ToSlogAttr().Equal(want))
+}